@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 
 	"paperspace-stable-diffusion-station/internal/config"
@@ -11,14 +12,13 @@ import (
 )
 
 func main() {
-	// コマンドライン引数の定義
+	// コマンドライン引数の定義（設定ごとのフラグは環境変数名から生成）
+	settingFlags := handlers.RegisterSettingFlags(flag.CommandLine)
 	var (
-		port     = flag.String("port", "", "Port to run the server on (default: 8080 or PORT env var)")
-		logLevel = flag.String("log-level", "", "Log level (debug, info, warn, error) (default: info or LOG_LEVEL env var)")
-		dbPath   = flag.String("db-path", "", "Database file path (default: ./data.db or DB_PATH env var)")
-		baseURL  = flag.String("base-url", "", "Base URL for the server (default: empty or BASE_URL env var)")
-		help     = flag.Bool("help", false, "Show this help message")
-		version  = flag.Bool("version", false, "Show version information")
+		configFile  = flag.String("config", "", "Config file path (default: STATION_CONFIG env var)")
+		printConfig = flag.Bool("print-config", false, "Show the effective configuration and where each value comes from")
+		help        = flag.Bool("help", false, "Show this help message")
+		version     = flag.Bool("version", false, "Show version information")
 	)
 	flag.Parse()
 
@@ -34,11 +34,23 @@ func main() {
 		os.Exit(0)
 	}
 
-	// 設定の読み込み
-	cfg := config.Load()
+	// 設定の読み込み（フラグ > 環境変数 > 設定ファイル > デフォルト）
+	cfg, err := config.Load(config.LoadOptions{File: *configFile, Flags: settingFlags()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	// 有効な設定の表示
+	if *printConfig {
+		handlers.PrintConfig(cfg)
+		os.Exit(0)
+	}
 
-	// コマンドライン引数で上書き
-	handlers.ProcessConfig(cfg, port, logLevel, dbPath, baseURL)
+	// サブコマンドの実行
+	if flag.Arg(0) == "catalog" {
+		os.Exit(handlers.RunCatalogCommand(cfg, flag.Args()[1:]))
+	}
 
 	// ロガーの初期化
 	logger.Init(cfg.LogLevel)
@@ -48,7 +60,7 @@ func main() {
 	srv.SetupRoutes()
 
 	// サーバーの起動
-	err := srv.Start()
+	err = srv.Start()
 	if err != nil {
 		logger.Fatal(err, "Failed to start server.")
 	}
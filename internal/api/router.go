@@ -20,12 +20,45 @@ func NewRouter() http.Handler {
 	router.HandleFunc("GET /installer/status", handler.GetInstallStatusHandler)
 	router.HandleFunc("POST /installer/cancel", handler.CancelInstallHandler)
 	router.HandleFunc("GET /installer/tasks", handler.GetAllInstallTasksHandler)
+	router.HandleFunc("GET /installer/summary", handler.GetInstallSummaryHandler)
+	router.HandleFunc("POST /installer/tasks/{id}/move", handler.MoveInstallTaskHandler)
+	router.HandleFunc("POST /installer/tasks/{id}/pause", handler.PauseInstallTaskHandler)
+	router.HandleFunc("POST /installer/tasks/{id}/resume", handler.ResumeInstallTaskHandler)
+	router.HandleFunc("DELETE /installer/tasks/{id}", handler.DeleteInstallTaskHandler)
+	router.HandleFunc("GET /installer/tasks/{id}/logs", handler.GetInstallTaskLogsHandler)
+	router.HandleFunc("POST /installer/tasks/clear", handler.ClearInstallTasksHandler)
+	router.HandleFunc("GET /installer/installed", handler.GetInstalledResourcesHandler)
+	router.HandleFunc("GET /installer/installed/{id}", handler.GetInstalledResourceHandler)
+	router.HandleFunc("DELETE /installer/installed/{id}", handler.UninstallHandler)
+	router.HandleFunc("POST /installer/installed/check-updates", handler.CheckUpdatesHandler)
+	router.HandleFunc("GET /installer/installed/check-updates", handler.GetUpdateCheckHandler)
+	router.HandleFunc("POST /installer/installed/{id}/update", handler.UpdateInstalledHandler)
+	router.HandleFunc("POST /installer/installed/{id}/rollback", handler.RollbackInstalledHandler)
 
 	// Preset resources
 	router.HandleFunc("GET /preset-resources", handler.GetPresetResourcesHandler)
+	router.HandleFunc("POST /preset-resources", handler.CreatePresetResourceHandler)
+	router.HandleFunc("PUT /preset-resources/{id}", handler.UpdatePresetResourceHandler)
+	router.HandleFunc("DELETE /preset-resources/{id}", handler.DeletePresetResourceHandler)
+	router.HandleFunc("GET /preset-resources/{id}/install-plan", handler.GetPresetInstallPlanHandler)
+	router.HandleFunc("POST /preset-resources/{id}/install", handler.InstallPresetHandler)
+
+	// Remote catalog subscriptions
+	router.HandleFunc("GET /catalog-subscriptions", handler.GetCatalogSubscriptionsHandler)
+	router.HandleFunc("POST /catalog-subscriptions/refresh", handler.RefreshCatalogSubscriptionsHandler)
+
+	// Preset bundles
+	router.HandleFunc("GET /preset-bundles", handler.GetPresetBundlesHandler)
+	router.HandleFunc("POST /preset-bundles/{id}/install", handler.InstallPresetBundleHandler)
+
+	// Configuration reload
+	router.HandleFunc("GET /admin/reload", handler.GetReloadStatusHandler)
+	router.HandleFunc("POST /admin/reload", handler.ReloadConfigHandler)
 
 	// Installation destinations
 	router.HandleFunc("GET /installation-destinations", handler.GetInstallationDestinationsHandler)
+	router.HandleFunc("GET /destination-profiles", handler.GetDestinationProfilesHandler)
+	router.HandleFunc("GET /resource-types", handler.GetResourceTypesHandler)
 
 	return router
 }
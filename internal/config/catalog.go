@@ -0,0 +1,279 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EmbeddedCatalogSource is the source of presets built into the binary
+const EmbeddedCatalogSource = "embedded"
+
+// catalogDir holds extra catalog files merged over the embedded catalog
+var catalogDir string
+
+// SetCatalogDir sets the directory extra catalog files are loaded from
+func SetCatalogDir(dir string) {
+	catalogDir = dir
+}
+
+// Catalog is the merged list of presets and bundles
+type Catalog struct {
+	Resources []PresetResource
+	Bundles   []PresetBundle
+}
+
+// catalogFile is the layout of a catalog file. Entries are kept as YAML nodes
+// so that an override only replaces the fields it sets.
+type catalogFile struct {
+	Resources []yaml.Node `yaml:"resources"`
+	Bundles   []yaml.Node `yaml:"bundles"`
+}
+
+// catalogEntry holds the fields that decide how a catalog entry is merged
+type catalogEntry struct {
+	ID       string `yaml:"id"`
+	Disabled bool   `yaml:"disabled"`
+}
+
+// CatalogFiles returns the catalog files of a directory in the order they are merged
+func CatalogFiles(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	files := make([]string, 0)
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// subscriptionSource is the source of the entries of a subscription
+func subscriptionSource(name string) string {
+	return "subscription:" + name
+}
+
+// isRemoteSource reports whether entries of a source come from outside the
+// local files: a subscription or the presets saved through the API
+func isRemoteSource(source string) bool {
+	return source == CustomCatalogSource || strings.HasPrefix(source, "subscription:")
+}
+
+// FromRemoteSource reports whether a subscription or a preset saved through
+// the API defined the preset or changed it
+func (r PresetResource) FromRemoteSource() bool {
+	return isRemoteSource(r.Source) || isRemoteSource(r.Override)
+}
+
+// loadCatalog merges the embedded catalog with the catalog files on disk and
+// the custom presets. Files are applied in name order; an entry whose id
+// already exists overrides the fields it sets, and an entry with
+// `disabled: true` removes the preset. Entries in skip, by source and id,
+// are left out; an empty id leaves out the whole source.
+func loadCatalog(custom [][]byte, skip map[string]map[string]bool) (*Catalog, []CatalogIssue, error) {
+	merger := newCatalogMerger()
+	merger.skip = skip
+	if err := merger.merge(EmbeddedCatalogSource, presetResourcesYAML); err != nil {
+		return nil, nil, err
+	}
+
+	// Subscriptions come before local files so that local files can override them
+	subs, contents := cachedSubscriptions()
+	for i, sub := range subs {
+		source := subscriptionSource(sub.Name)
+		if err := merger.mergeNamespaced(sub.Name, source, contents[i]); err != nil {
+			merger.issues = append(merger.issues, CatalogIssue{File: source, Message: err.Error()})
+		}
+	}
+
+	files, err := CatalogFiles(catalogDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list catalog files: %v", err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read catalog %s: %v", file, err)
+		}
+		if err := merger.merge(file, content); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := merger.mergeEntries(CustomCatalogSource, custom); err != nil {
+		return nil, nil, err
+	}
+
+	catalog := merger.catalog()
+	issues := append(merger.issues, expandCatalogPaths(catalog)...)
+	if len(issues) > 0 {
+		return nil, issues, nil
+	}
+	return catalog, nil, nil
+}
+
+// catalogMerger accumulates catalog files and the issues found in them
+type catalogMerger struct {
+	resources *mergedEntries[PresetResource]
+	bundles   *mergedEntries[PresetBundle]
+	issues    []CatalogIssue
+	// skip lists the entries left out by source and id
+	skip map[string]map[string]bool
+}
+
+func newCatalogMerger() *catalogMerger {
+	return &catalogMerger{
+		resources: newMergedEntries(func(resource *PresetResource, source string, override bool) {
+			if override {
+				resource.Override = source
+			} else {
+				resource.Source = source
+			}
+		}),
+		bundles: newMergedEntries(func(bundle *PresetBundle, source string, override bool) {
+			if override {
+				bundle.Override = source
+			} else {
+				bundle.Source = source
+			}
+		}),
+	}
+}
+
+// merge applies a catalog file
+func (m *catalogMerger) merge(source string, content []byte) error {
+	var file catalogFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("failed to parse catalog %s: %v", source, err)
+	}
+	m.apply(source, file)
+	return nil
+}
+
+// mergeNamespaced applies a remote catalog with its IDs prefixed by "namespace/"
+func (m *catalogMerger) mergeNamespaced(namespace, source string, content []byte) error {
+	if m.skip[source][""] {
+		return nil
+	}
+	var file catalogFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("failed to parse catalog %s: %v", source, err)
+	}
+	if err := namespaceCatalog(namespace, &file); err != nil {
+		return fmt.Errorf("failed to parse catalog %s: %v", source, err)
+	}
+	m.removeSkipped(source, &file)
+	m.issues = append(m.issues, lintLocalOnlySteps(source, file)...)
+	m.apply(source, file)
+	return nil
+}
+
+// removeSkipped drops the entries of a catalog file that are left out
+func (m *catalogMerger) removeSkipped(source string, file *catalogFile) {
+	if len(m.skip[source]) == 0 {
+		return
+	}
+	keep := func(nodes []yaml.Node) []yaml.Node {
+		kept := make([]yaml.Node, 0, len(nodes))
+		for _, node := range nodes {
+			var head catalogEntry
+			if err := node.Decode(&head); err == nil && m.skip[source][head.ID] {
+				continue
+			}
+			kept = append(kept, node)
+		}
+		return kept
+	}
+	file.Resources = keep(file.Resources)
+	file.Bundles = keep(file.Bundles)
+}
+
+// apply checks the entries of a parsed catalog file and merges the valid ones
+func (m *catalogMerger) apply(source string, file catalogFile) {
+	m.issues = append(m.issues, lintCatalogNodes(source, file)...)
+	m.resources.merge(source, file.Resources)
+	m.bundles.merge(source, file.Bundles)
+}
+
+func (m *catalogMerger) catalog() *Catalog {
+	resources := m.resources.list()
+	// Presets without destination install to the destination of their type
+	for i := range resources {
+		resources[i].Destination = resources[i].DestinationType()
+	}
+	return &Catalog{Resources: resources, Bundles: m.bundles.list()}
+}
+
+// mergedEntries is an ordered set of catalog entries by id
+type mergedEntries[T any] struct {
+	order     []string
+	entries   map[string]*T
+	setSource func(entry *T, source string, override bool)
+}
+
+func newMergedEntries[T any](setSource func(entry *T, source string, override bool)) *mergedEntries[T] {
+	return &mergedEntries[T]{entries: make(map[string]*T), setSource: setSource}
+}
+
+// merge applies the entries of a catalog file. Entries that cannot be
+// decoded are skipped; lintCatalogNodes reports them.
+func (m *mergedEntries[T]) merge(source string, nodes []yaml.Node) {
+	for i := range nodes {
+		var head catalogEntry
+		if err := nodes[i].Decode(&head); err != nil || head.ID == "" {
+			continue
+		}
+
+		existing, exists := m.entries[head.ID]
+		if head.Disabled {
+			delete(m.entries, head.ID)
+			continue
+		}
+
+		var entry T
+		if exists {
+			entry = *existing
+		}
+		if err := nodes[i].Decode(&entry); err != nil {
+			continue
+		}
+		m.setSource(&entry, source, exists)
+
+		if !m.contains(head.ID) {
+			m.order = append(m.order, head.ID)
+		}
+		m.entries[head.ID] = &entry
+	}
+}
+
+func (m *mergedEntries[T]) contains(id string) bool {
+	for _, existing := range m.order {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mergedEntries[T]) list() []T {
+	list := make([]T, 0, len(m.entries))
+	for _, id := range m.order {
+		if entry, exists := m.entries[id]; exists {
+			list = append(list, *entry)
+		}
+	}
+	return list
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// catalogLayer is a catalog file applied by a merge test
+type catalogLayer struct {
+	source    string
+	namespace string // merged as a subscription when set
+	content   string
+}
+
+func TestCatalogMergeAndOverride(t *testing.T) {
+	base := catalogLayer{source: EmbeddedCatalogSource, content: `
+resources:
+  - id: base
+    name: Base model
+    type: checkpoint
+    url: https://example.com/base.safetensors
+    tags: [sd15]
+  - id: lora
+    name: Detail LoRA
+    type: lora
+    depends_on: [base]
+`}
+
+	tests := []struct {
+		name   string
+		layers []catalogLayer
+		want   []string // id=name source override, in catalog order
+	}{
+		{
+			name:   "embedded catalog only",
+			layers: []catalogLayer{base},
+			want:   []string{"base=Base model embedded", "lora=Detail LoRA embedded"},
+		},
+		{
+			name: "override changes only the fields it sets",
+			layers: []catalogLayer{base, {source: "local.yaml", content: `
+resources:
+  - id: base
+    name: Renamed base
+`}},
+			want: []string{"base=Renamed base embedded local.yaml", "lora=Detail LoRA embedded"},
+		},
+		{
+			name: "new entries are appended",
+			layers: []catalogLayer{base, {source: "local.yaml", content: `
+resources:
+  - id: extra
+    name: Extra
+`}},
+			want: []string{"base=Base model embedded", "lora=Detail LoRA embedded", "extra=Extra local.yaml"},
+		},
+		{
+			name: "disabled removes the preset",
+			layers: []catalogLayer{base, {source: "local.yaml", content: `
+resources:
+  - id: lora
+    disabled: true
+`}},
+			want: []string{"base=Base model embedded"},
+		},
+		{
+			name: "later files win and a re-added preset keeps its place",
+			layers: []catalogLayer{base,
+				{source: "a.yaml", content: "resources:\n  - id: base\n    disabled: true\n"},
+				{source: "b.yaml", content: "resources:\n  - id: base\n    name: Back again\n"},
+				{source: "c.yaml", content: "resources:\n  - id: lora\n    name: First\n"},
+				{source: "d.yaml", content: "resources:\n  - id: lora\n    name: Second\n"},
+			},
+			want: []string{"base=Back again b.yaml", "lora=Second embedded d.yaml"},
+		},
+		{
+			name: "subscription IDs are namespaced",
+			layers: []catalogLayer{base, {source: subscriptionSource("team"), namespace: "team", content: `
+resources:
+  - id: base
+    name: Team base
+`}},
+			want: []string{"base=Base model embedded", "lora=Detail LoRA embedded", "team/base=Team base subscription:team"},
+		},
+		{
+			name: "local files override subscription entries by namespaced ID",
+			layers: []catalogLayer{base,
+				{source: subscriptionSource("team"), namespace: "team", content: "resources:\n  - id: style\n    name: Team style\n"},
+				{source: "local.yaml", content: "resources:\n  - id: team/style\n    name: My style\n"},
+			},
+			want: []string{"base=Base model embedded", "lora=Detail LoRA embedded", "team/style=My style subscription:team local.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merger := newCatalogMerger()
+			for _, layer := range tt.layers {
+				var err error
+				if layer.namespace != "" {
+					err = merger.mergeNamespaced(layer.namespace, layer.source, []byte(layer.content))
+				} else {
+					err = merger.merge(layer.source, []byte(layer.content))
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := make([]string, 0)
+			for _, resource := range merger.catalog().Resources {
+				got = append(got, strings.TrimSpace(fmt.Sprintf("%s=%s %s %s", resource.ID, resource.Name, resource.Source, resource.Override)))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("presets =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCatalogOverrideKeepsUnsetFields(t *testing.T) {
+	merger := newCatalogMerger()
+	if err := merger.merge(EmbeddedCatalogSource, []byte(`
+resources:
+  - id: lora
+    name: Detail LoRA
+    type: lora
+    url: https://example.com/lora.safetensors
+    tags: [detail]
+`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := merger.merge("local.yaml", []byte("resources:\n  - id: lora\n    url: https://mirror.example.com/lora.safetensors\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	resources := merger.catalog().Resources
+	if len(resources) != 1 {
+		t.Fatalf("resources = %+v", resources)
+	}
+	lora := resources[0]
+	if lora.URL != "https://mirror.example.com/lora.safetensors" || lora.Name != "Detail LoRA" || lora.Type != "lora" || len(lora.Tags) != 1 {
+		t.Fatalf("override lost fields: %+v", lora)
+	}
+	if lora.Destination != "loras" {
+		t.Fatalf("destination = %q, want loras", lora.Destination)
+	}
+}
@@ -2,10 +2,8 @@ package config
 
 import (
 	_ "embed"
-	"fmt"
-	"os"
-
-	"gopkg.in/yaml.v3"
+	"strings"
+	"time"
 )
 
 //go:embed preset_resources.yaml
@@ -14,11 +12,65 @@ var presetResourcesYAML []byte
 //go:embed install_destinations.yaml
 var installDestinationsYAML []byte
 
+// DefaultMaxConcurrentInstalls is the number of install tasks run at the
+// same time unless configured otherwise
+const DefaultMaxConcurrentInstalls = 2
+
+// Config holds the server settings; Load fills it from flags, the
+// environment, the config file and defaults
 type Config struct {
+	// Host and Port are the address the server listens on
+	Host     string
 	Port     string
 	LogLevel string
 	DBPath   string
 	BaseURL  string
+	// MaxConcurrentInstalls limits how many install tasks run at the same time
+	MaxConcurrentInstalls int
+	// Finished tasks older than TaskRetentionMaxAge, or beyond the newest
+	// TaskRetentionMaxCount, are pruned in the background (0 disables each limit)
+	TaskRetentionMaxAge   time.Duration
+	TaskRetentionMaxCount int
+	// InterruptedTaskPolicy decides what happens on startup to tasks that were
+	// running when the server stopped: "resume" queues them again, "hold"
+	// keeps them interrupted until resumed by the user
+	InterruptedTaskPolicy string
+	// UpdateCheckInterval is how often installed resources are compared
+	// against their source (0 disables the background check)
+	UpdateCheckInterval time.Duration
+	// CatalogDir holds extra preset catalog files (*.yaml) merged over the embedded catalog
+	CatalogDir string
+	// CatalogSubscriptions lists remote catalogs as comma separated name=url pairs;
+	// they are cached in CatalogCacheDir and refreshed every CatalogRefreshInterval
+	CatalogSubscriptions   string
+	CatalogCacheDir        string
+	CatalogRefreshInterval time.Duration
+	// DestinationsFile overrides embedded install destinations by type
+	DestinationsFile string
+	// DestinationProfile selects the profile installs go to by default
+	// (empty uses the destinations file, then comfyui); ProfileRoots
+	// overrides profile roots as comma separated profile=path pairs
+	DestinationProfile string
+	ProfileRoots       string
+	// ConfigWatchInterval is how often catalog and destination files are
+	// checked for changes (0 disables watching; POST /admin/reload still works)
+	ConfigWatchInterval time.Duration
+	// DownloadBackend downloads files other than git repositories: wget or http
+	DownloadBackend string
+	// AllowCommandSteps enables post-install steps running shell commands
+	AllowCommandSteps bool
+	// HuggingFaceToken and CivitaiToken reference where the API tokens are
+	// read from, as env:NAME or file:PATH
+	HuggingFaceToken string
+	CivitaiToken     string
+	// PathVariables are the ${NAME} variables set in the config file
+	PathVariables map[string]string
+
+	// File is the config file the settings were read from, if any
+	File string
+	// Sources tells where each setting came from, by setting key
+	Sources map[string]string
+	values  map[string]string
 }
 
 // Size information structure
@@ -29,23 +81,74 @@ type SizeInfo struct {
 
 // Preset resource data structures
 type PresetResource struct {
-	ID              string   `json:"id" yaml:"id"`
-	Name            string   `json:"name" yaml:"name"`
-	Type            string   `json:"type" yaml:"type"` // checkpoint, extension, script
-	Filename        string   `json:"filename,omitempty" yaml:"filename,omitempty"`
-	Size            SizeInfo `json:"size" yaml:"size"`
-	Description     string   `json:"description" yaml:"description"`
-	Tags            []string `json:"tags,omitempty" yaml:"tags,omitempty"`
-	Version         string   `json:"version,omitempty" yaml:"version,omitempty"`
-	Author          string   `json:"author,omitempty" yaml:"author,omitempty"`
-	License         string   `json:"license,omitempty" yaml:"license,omitempty"`
-	Requirements    []string `json:"requirements,omitempty" yaml:"requirements,omitempty"`
-	DestinationPath string   `json:"destination_path,omitempty" yaml:"destination_path,omitempty"`
-	URL             string   `json:"url,omitempty" yaml:"url,omitempty"`
+	ID           string   `json:"id" yaml:"id"`
+	Name         string   `json:"name" yaml:"name"`
+	Type         string   `json:"type" yaml:"type"` // one of ResourceTypes
+	Filename     string   `json:"filename,omitempty" yaml:"filename,omitempty"`
+	Size         SizeInfo `json:"size" yaml:"size"`
+	Description  string   `json:"description" yaml:"description"`
+	Tags         []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Version      string   `json:"version,omitempty" yaml:"version,omitempty"`
+	Author       string   `json:"author,omitempty" yaml:"author,omitempty"`
+	License      string   `json:"license,omitempty" yaml:"license,omitempty"`
+	Requirements []string `json:"requirements,omitempty" yaml:"requirements,omitempty"`
+	// Destination is the logical destination type (checkpoints, loras, ...)
+	// resolved through the destination profile and defaults to the destination
+	// of Type; DestinationPath is an explicit directory used by every profile
+	Destination     string `json:"destination,omitempty" yaml:"destination,omitempty"`
+	DestinationPath string `json:"destination_path,omitempty" yaml:"destination_path,omitempty"`
+	// AllowTypeMismatch permits a destination of another type, e.g. a LoRA in checkpoints
+	AllowTypeMismatch bool   `json:"allow_type_mismatch,omitempty" yaml:"allow_type_mismatch,omitempty"`
+	URL               string `json:"url,omitempty" yaml:"url,omitempty"`
+	SHA256            string `json:"sha256,omitempty" yaml:"sha256,omitempty"` // expected file hash, verified after download
+	// PostInstall overrides the post-install steps of the resource type
+	PostInstall []PostInstallStep `json:"post_install,omitempty" yaml:"post_install,omitempty"`
+	// DependsOn lists presets that must be installed before this one
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	// Source is the catalog that defined the preset, Override the catalog that last changed it
+	Source   string `json:"source" yaml:"-"`
+	Override string `json:"override,omitempty" yaml:"-"`
+	// destinationTemplate is DestinationPath before its variables were expanded
+	destinationTemplate string
+}
+
+// DestinationPathTemplate returns DestinationPath as written in the catalog
+func (p PresetResource) DestinationPathTemplate() string {
+	if p.destinationTemplate != "" {
+		return p.destinationTemplate
+	}
+	return p.DestinationPath
+}
+
+// Bytes returns the size in bytes, or 0 if the unit is unknown
+func (s SizeInfo) Bytes() int64 {
+	switch strings.ToUpper(strings.TrimSpace(s.Unit)) {
+	case "B":
+		return int64(s.Value)
+	case "KB":
+		return int64(s.Value * 1024)
+	case "MB":
+		return int64(s.Value * 1024 * 1024)
+	case "GB":
+		return int64(s.Value * 1024 * 1024 * 1024)
+	}
+	return 0
+}
+
+// Preset bundle: a curated list of presets installed together
+type PresetBundle struct {
+	ID          string   `json:"id" yaml:"id"`
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Resources   []string `json:"resources" yaml:"resources"` // preset IDs
+	Source      string   `json:"source" yaml:"-"`
+	Override    string   `json:"override,omitempty" yaml:"-"`
 }
 
 type PresetResourcesConfig struct {
 	Resources []PresetResource `yaml:"resources"`
+	Bundles   []PresetBundle   `yaml:"bundles,omitempty"`
 }
 
 // Installation destination data structure
@@ -53,46 +156,47 @@ type InstallDestinationConfig struct {
 	Type        string `json:"type" yaml:"type"`
 	Path        string `json:"path" yaml:"path"`
 	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Path as configured, set when it used ${NAME} variables or a profile root
+	Template string `json:"template,omitempty" yaml:"-"`
 }
 
-type InstallDestinationsConfig struct {
-	Destinations []InstallDestinationConfig `yaml:"destinations"`
-}
-
-func Load() *Config {
-	return &Config{
-		Port:     getEnv("PORT", "8080"),
-		LogLevel: getEnv("LOG_LEVEL", "info"),
-		DBPath:   getEnv("DB_PATH", "./data.db"),
-		BaseURL:  getEnv("BASE_URL", ""),
+// GetPresetResources returns the preset resources of the active configuration:
+// the embedded YAML config merged with the catalogs on disk
+func GetPresetResources() ([]PresetResource, error) {
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		return nil, err
 	}
+
+	return snapshot.catalog.Resources, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// GetPresetBundles returns the preset bundles of the active configuration
+func GetPresetBundles() ([]PresetBundle, error) {
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		return nil, err
 	}
-	return defaultValue
+
+	return snapshot.catalog.Bundles, nil
 }
 
-// GetPresetResources loads preset resources from embedded YAML config
-func GetPresetResources() ([]PresetResource, error) {
-	// Parse embedded YAML
-	var config PresetResourcesConfig
-	if err := yaml.Unmarshal(presetResourcesYAML, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse embedded config: %v", err)
+// GetInstallDestinations returns the installation destinations of the active profile
+func GetInstallDestinations() ([]InstallDestinationConfig, error) {
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		return nil, err
 	}
 
-	return config.Resources, nil
+	return snapshot.destinations.ActiveProfile().Destinations, nil
 }
 
-// GetInstallDestinations loads installation destinations from embedded YAML config
-func GetInstallDestinations() ([]InstallDestinationConfig, error) {
-	// Parse embedded YAML
-	var config InstallDestinationsConfig
-	if err := yaml.Unmarshal(installDestinationsYAML, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse embedded install destinations config: %v", err)
+// GetDestinations returns every destination profile and the active one
+func GetDestinations() (*Destinations, error) {
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		return nil, err
 	}
 
-	return config.Destinations, nil
+	return snapshot.destinations, nil
 }
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CustomCatalogSource is the source of presets created through the API
+const CustomCatalogSource = "custom"
+
+var (
+	customPresetsMutex sync.RWMutex
+	customPresets      [][]byte
+)
+
+// SetCustomPresets sets the user-defined presets and overrides, as JSON
+// entries in catalog format. They are merged after every catalog file.
+func SetCustomPresets(entries [][]byte) {
+	customPresetsMutex.Lock()
+	defer customPresetsMutex.Unlock()
+
+	customPresets = entries
+}
+
+func currentCustomPresets() [][]byte {
+	customPresetsMutex.RLock()
+	defer customPresetsMutex.RUnlock()
+
+	return customPresets
+}
+
+// CheckCustomPresets validates the configuration the given custom presets
+// would produce without applying it. Unlike a reload, which leaves invalid
+// custom presets out, any issue of a custom preset is an error.
+func CheckCustomPresets(entries [][]byte) error {
+	_, skipped, err := loadSnapshot(entries)
+	if err != nil {
+		return err
+	}
+	issues := make([]CatalogIssue, 0)
+	for _, issue := range skipped {
+		if issue.File == CustomCatalogSource {
+			issues = append(issues, issue)
+		}
+	}
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// mergeEntries applies JSON catalog entries as if they came from one catalog file
+func (m *catalogMerger) mergeEntries(source string, entries [][]byte) error {
+	if m.skip[source][""] {
+		return nil
+	}
+	var file catalogFile
+	for _, entry := range entries {
+		var document yaml.Node
+		if err := yaml.Unmarshal(entry, &document); err != nil {
+			return fmt.Errorf("failed to parse %s preset: %v", source, err)
+		}
+		if len(document.Content) == 0 {
+			continue
+		}
+		// Line numbers of stored entries mean nothing to the user
+		node := document.Content[0]
+		node.Line = 0
+		file.Resources = append(file.Resources, *node)
+	}
+	m.removeSkipped(source, &file)
+	m.issues = append(m.issues, lintLocalOnlySteps(source, file)...)
+	m.apply(source, file)
+	return nil
+}
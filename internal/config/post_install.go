@@ -0,0 +1,62 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed post_install_hooks.yaml
+var postInstallHooksYAML []byte
+
+// Post-install step data structure
+type PostInstallStep struct {
+	Name            string `json:"name" yaml:"name"`
+	Action          string `json:"action" yaml:"action"` // extract, pip_requirements, python_script, chmod, command, http
+	File            string `json:"file,omitempty" yaml:"file,omitempty"`
+	Command         string `json:"command,omitempty" yaml:"command,omitempty"`
+	Python          string `json:"python,omitempty" yaml:"python,omitempty"`
+	Mode            string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	URL             string `json:"url,omitempty" yaml:"url,omitempty"`
+	Method          string `json:"method,omitempty" yaml:"method,omitempty"`
+	RemoveArchive   bool   `json:"remove_archive,omitempty" yaml:"remove_archive,omitempty"`
+	Timeout         string `json:"timeout,omitempty" yaml:"timeout,omitempty"` // Go duration, default 10m
+	ContinueOnError bool   `json:"continue_on_error,omitempty" yaml:"continue_on_error,omitempty"`
+}
+
+// Post-install steps for one resource type
+type PostInstallHook struct {
+	Type  string            `json:"type" yaml:"type"`
+	Steps []PostInstallStep `json:"steps" yaml:"steps"`
+}
+
+type PostInstallHooksConfig struct {
+	Hooks []PostInstallHook `yaml:"hooks"`
+}
+
+// GetPostInstallHooks loads post-install hooks from embedded YAML config
+func GetPostInstallHooks() ([]PostInstallHook, error) {
+	// Parse embedded YAML
+	var config PostInstallHooksConfig
+	if err := yaml.Unmarshal(postInstallHooksYAML, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded post-install hooks config: %v", err)
+	}
+
+	return config.Hooks, nil
+}
+
+// GetPostInstallSteps returns the post-install steps of a resource type
+func GetPostInstallSteps(resourceType string) ([]PostInstallStep, error) {
+	hooks, err := GetPostInstallHooks()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hook := range hooks {
+		if hook.Type == resourceType {
+			return hook.Steps, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is the destination profile used when none is configured
+const DefaultProfile = "comfyui"
+
+// DestinationProfile is the directory layout of one UI. Relative destination
+// paths are resolved against Root.
+type DestinationProfile struct {
+	Name         string                     `json:"name" yaml:"name"`
+	Description  string                     `json:"description,omitempty" yaml:"description,omitempty"`
+	Root         string                     `json:"root" yaml:"root"`
+	Destinations []InstallDestinationConfig `json:"destinations" yaml:"destinations"`
+}
+
+// Destination returns the destination of a logical type such as loras
+func (p DestinationProfile) Destination(destinationType string) (InstallDestinationConfig, bool) {
+	for _, destination := range p.Destinations {
+		if destination.Type == destinationType {
+			return destination, true
+		}
+	}
+	return InstallDestinationConfig{}, false
+}
+
+// Destinations holds every profile with absolute paths and the active profile
+type Destinations struct {
+	Profiles []DestinationProfile `json:"profiles"`
+	Active   string               `json:"active"`
+}
+
+// Profile returns the profile with the given name
+func (d *Destinations) Profile(name string) (DestinationProfile, bool) {
+	for _, profile := range d.Profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return DestinationProfile{}, false
+}
+
+// ActiveProfile returns the profile installs go to by default
+func (d *Destinations) ActiveProfile() DestinationProfile {
+	profile, _ := d.Profile(d.Active)
+	return profile
+}
+
+// PresetPath returns the directory a preset installs to in a profile. A preset
+// with an explicit destination_path installs there whatever the profile.
+func (d *Destinations) PresetPath(preset PresetResource, profileName string) (string, error) {
+	destinationType := preset.DestinationType()
+	if destinationType == "" {
+		return preset.DestinationPath, nil
+	}
+	profile, exists := d.Profile(profileName)
+	if !exists {
+		return "", fmt.Errorf("unknown profile %q", profileName)
+	}
+	destination, exists := profile.Destination(destinationType)
+	if !exists {
+		return "", fmt.Errorf("profile %s has no %s destination", profileName, destinationType)
+	}
+	return destination.Path, nil
+}
+
+var (
+	// destinationsFile is merged over the embedded profiles
+	destinationsFile string
+
+	profileSettingsMutex sync.RWMutex
+	activeProfileSetting string
+	profileRoots         map[string]string
+)
+
+// SetDestinationsFile sets the install destinations file merged over the embedded profiles
+func SetDestinationsFile(path string) {
+	destinationsFile = path
+}
+
+// SetActiveProfile sets the profile installs go to by default. An empty name
+// leaves the choice to the destinations file.
+func SetActiveProfile(name string) {
+	profileSettingsMutex.Lock()
+	defer profileSettingsMutex.Unlock()
+
+	activeProfileSetting = name
+}
+
+// SetProfileRoots overrides the root directory of profiles by name
+func SetProfileRoots(roots map[string]string) {
+	profileSettingsMutex.Lock()
+	defer profileSettingsMutex.Unlock()
+
+	profileRoots = roots
+}
+
+func currentProfileSettings() (string, map[string]string) {
+	profileSettingsMutex.RLock()
+	defer profileSettingsMutex.RUnlock()
+
+	return activeProfileSetting, profileRoots
+}
+
+// ParseProfileRoots parses a comma separated list of profile=root pairs
+func ParseProfileRoots(value string) (map[string]string, error) {
+	roots := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, root, found := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		root = strings.TrimSpace(root)
+		if !found || name == "" || root == "" {
+			return nil, fmt.Errorf("profile root %q must be in profile=path format", item)
+		}
+		if !filepath.IsAbs(root) && !hasPathVariables(root) {
+			return nil, fmt.Errorf("root of profile %s must be an absolute path", name)
+		}
+		roots[name] = root
+	}
+	return roots, nil
+}
+
+// LoadDestinations merges the embedded profiles with the destinations file
+// and resolves every destination to an absolute path
+func LoadDestinations() (*Destinations, error) {
+	merger := &destinationsMerger{}
+	issues := make([]CatalogIssue, 0)
+
+	parsed, parseIssues, err := parseDestinations(EmbeddedCatalogSource, installDestinationsYAML)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, parseIssues...)
+	merger.apply(parsed)
+
+	if destinationsFile != "" {
+		content, err := os.ReadFile(destinationsFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read install destinations %s: %v", destinationsFile, err)
+		}
+		if err == nil {
+			parsed, parseIssues, err := parseDestinations(destinationsFile, content)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, parseIssues...)
+			merger.apply(parsed)
+		}
+	}
+
+	destinations, resolveIssues := merger.resolve()
+	issues = append(issues, resolveIssues...)
+	if len(issues) > 0 {
+		return nil, &ValidationError{Issues: issues}
+	}
+	return destinations, nil
+}
+
+// parsedDestinations is the content of one destinations file
+type parsedDestinations struct {
+	Source        string
+	ActiveProfile string
+	Profiles      []DestinationProfile
+	// Destinations with absolute paths that apply to the active profile
+	Destinations []InstallDestinationConfig
+}
+
+// destinationsNodes is the layout of a destinations file with entries kept as nodes for line numbers
+type destinationsNodes struct {
+	ActiveProfile string      `yaml:"active_profile"`
+	Profiles      []yaml.Node `yaml:"profiles"`
+	Destinations  []yaml.Node `yaml:"destinations"`
+}
+
+// profileNodes is a profile with its destinations kept as nodes
+type profileNodes struct {
+	Name         string      `yaml:"name"`
+	Description  string      `yaml:"description"`
+	Root         string      `yaml:"root"`
+	Destinations []yaml.Node `yaml:"destinations"`
+}
+
+// parseDestinations parses a destinations file and checks its entries
+func parseDestinations(source string, content []byte) (parsedDestinations, []CatalogIssue, error) {
+	parsed := parsedDestinations{Source: source}
+	var file destinationsNodes
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return parsed, nil, fmt.Errorf("failed to parse install destinations %s: %v", source, err)
+	}
+	parsed.ActiveProfile = file.ActiveProfile
+
+	issues := make([]CatalogIssue, 0)
+	seen := make(map[string]int)
+	for i := range file.Profiles {
+		node := &file.Profiles[i]
+		var profile profileNodes
+		if err := node.Decode(&profile); err != nil {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, Message: err.Error()})
+			continue
+		}
+		if profile.Name == "" {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, Message: "profile without name"})
+			continue
+		}
+		if line, exists := seen[profile.Name]; exists {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, ID: profile.Name, Message: fmt.Sprintf("duplicate profile, first defined on line %d", line)})
+		}
+		seen[profile.Name] = node.Line
+		if profile.Root != "" && !filepath.IsAbs(profile.Root) && !hasPathVariables(profile.Root) {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, ID: profile.Name, Message: fmt.Sprintf("root %q is not absolute", profile.Root)})
+		}
+
+		destinations, destinationIssues := parseDestinationNodes(source, profile.Destinations, false)
+		issues = append(issues, destinationIssues...)
+		parsed.Profiles = append(parsed.Profiles, DestinationProfile{
+			Name:         profile.Name,
+			Description:  profile.Description,
+			Root:         profile.Root,
+			Destinations: destinations,
+		})
+	}
+
+	destinations, destinationIssues := parseDestinationNodes(source, file.Destinations, true)
+	issues = append(issues, destinationIssues...)
+	parsed.Destinations = destinations
+	return parsed, issues, nil
+}
+
+// parseDestinationNodes checks a list of destinations. Destinations outside
+// of a profile have no root and need absolute paths.
+func parseDestinationNodes(source string, nodes []yaml.Node, requireAbsolute bool) ([]InstallDestinationConfig, []CatalogIssue) {
+	destinations := make([]InstallDestinationConfig, 0, len(nodes))
+	issues := make([]CatalogIssue, 0)
+	seen := make(map[string]int)
+	for i := range nodes {
+		node := &nodes[i]
+		var destination InstallDestinationConfig
+		if err := node.Decode(&destination); err != nil {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, Message: err.Error()})
+			continue
+		}
+		add := func(format string, args ...interface{}) {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, ID: destination.Type, Message: fmt.Sprintf(format, args...)})
+		}
+		if destination.Type == "" {
+			add("destination without type")
+			continue
+		}
+		if line, exists := seen[destination.Type]; exists {
+			add("duplicate destination type, first defined on line %d", line)
+		}
+		seen[destination.Type] = node.Line
+		if destination.Path == "" {
+			add("path is required")
+		} else if requireAbsolute && !filepath.IsAbs(destination.Path) && !hasPathVariables(destination.Path) {
+			add("path %q is not absolute", destination.Path)
+		}
+		destinations = append(destinations, destination)
+	}
+	return destinations, issues
+}
+
+// destinationsMerger accumulates destinations files
+type destinationsMerger struct {
+	profiles      []DestinationProfile
+	activeProfile string
+	activeSource  string
+	// destinations with absolute paths merged into the active profile
+	destinations []InstallDestinationConfig
+}
+
+// apply merges a parsed file: profiles by name, destinations by type
+func (m *destinationsMerger) apply(parsed parsedDestinations) {
+	for _, profile := range parsed.Profiles {
+		merged := false
+		for i := range m.profiles {
+			if m.profiles[i].Name != profile.Name {
+				continue
+			}
+			if profile.Root != "" {
+				m.profiles[i].Root = profile.Root
+			}
+			if profile.Description != "" {
+				m.profiles[i].Description = profile.Description
+			}
+			m.profiles[i].Destinations = mergeDestinations(m.profiles[i].Destinations, profile.Destinations)
+			merged = true
+			break
+		}
+		if !merged {
+			m.profiles = append(m.profiles, profile)
+		}
+	}
+	if parsed.ActiveProfile != "" {
+		m.activeProfile = parsed.ActiveProfile
+		m.activeSource = parsed.Source
+	}
+	m.destinations = mergeDestinations(m.destinations, parsed.Destinations)
+}
+
+// resolve picks the active profile and makes every destination path absolute
+func (m *destinationsMerger) resolve() (*Destinations, []CatalogIssue) {
+	issues := make([]CatalogIssue, 0)
+	setting, roots := currentProfileSettings()
+
+	active, source := DefaultProfile, "default"
+	if m.activeProfile != "" {
+		active, source = m.activeProfile, m.activeSource
+	}
+	if setting != "" {
+		active, source = setting, "DESTINATION_PROFILE"
+	}
+
+	destinations := &Destinations{Active: active, Profiles: make([]DestinationProfile, 0, len(m.profiles))}
+	for _, profile := range m.profiles {
+		if root, exists := roots[profile.Name]; exists {
+			profile.Root = root
+		}
+		if profile.Name == active {
+			profile.Destinations = mergeDestinations(profile.Destinations, m.destinations)
+		}
+
+		addIssue := func(id, format string, args ...interface{}) {
+			issues = append(issues, CatalogIssue{File: "profile " + profile.Name, ID: id, Message: fmt.Sprintf(format, args...)})
+		}
+		rootTemplate := profile.Root
+		if profile.Root != "" {
+			root, err := ExpandPath(profile.Root)
+			switch {
+			case err != nil:
+				addIssue("", "root: %v", err)
+				root = ""
+			case !filepath.IsAbs(root):
+				addIssue("", "root %q does not expand to an absolute path", profile.Root)
+				root = ""
+			}
+			profile.Root = root
+		}
+
+		resolved := make([]InstallDestinationConfig, 0, len(profile.Destinations))
+		for _, destination := range profile.Destinations {
+			template := destination.Path
+			path, err := ExpandPath(destination.Path)
+			if err != nil {
+				addIssue(destination.Type, "%v", err)
+				continue
+			}
+			if !filepath.IsAbs(path) {
+				if profile.Root == "" {
+					if rootTemplate == "" {
+						addIssue(destination.Type, "relative path %q needs a profile root", destination.Path)
+					}
+					continue
+				}
+				template = rootTemplate + "/" + template
+				path = filepath.Join(profile.Root, path)
+			}
+			destination.Path = path
+			if template != path {
+				destination.Template = template
+			}
+			resolved = append(resolved, destination)
+		}
+		profile.Destinations = resolved
+		destinations.Profiles = append(destinations.Profiles, profile)
+	}
+
+	if _, exists := destinations.Profile(active); !exists {
+		issues = append(issues, CatalogIssue{File: source, Message: fmt.Sprintf("active profile %q is not defined", active)})
+	}
+	for name := range roots {
+		if _, exists := destinations.Profile(name); !exists {
+			issues = append(issues, CatalogIssue{File: "PROFILE_ROOTS", Message: fmt.Sprintf("unknown profile %q", name)})
+		}
+	}
+	return destinations, issues
+}
+
+func mergeDestinations(base, overrides []InstallDestinationConfig) []InstallDestinationConfig {
+	merged := append([]InstallDestinationConfig{}, base...)
+	for _, override := range overrides {
+		replaced := false
+		for i := range merged {
+			if merged[i].Type == override.Type {
+				merged[i] = override
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
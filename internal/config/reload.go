@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// configSnapshot is a consistent set of catalog and destinations swapped in as a whole
+type configSnapshot struct {
+	catalog      *Catalog
+	destinations *Destinations
+}
+
+// ReloadStatus reports the result of the last configuration reload
+type ReloadStatus struct {
+	LoadedAt      *time.Time     `json:"loadedAt,omitempty"`    // when the active configuration was loaded
+	LastAttempt   *time.Time     `json:"lastAttempt,omitempty"` // last reload, successful or not
+	Error         string         `json:"error,omitempty"`       // why the last reload was rejected
+	Issues        []CatalogIssue `json:"issues,omitempty"`      // every validation issue of the rejected configuration
+	Skipped       []CatalogIssue `json:"skipped,omitempty"`     // invalid subscription and custom entries left out of the active configuration
+	Files         []string       `json:"files"`                 // files on disk the configuration was loaded from
+	Presets       int            `json:"presets"`
+	Bundles       int            `json:"bundles"`
+	Destinations  int            `json:"destinations"` // destinations of the active profile
+	Profiles      int            `json:"profiles"`
+	ActiveProfile string         `json:"activeProfile,omitempty"`
+}
+
+var (
+	activeSnapshot atomic.Pointer[configSnapshot]
+
+	reloadMutex  sync.Mutex
+	reloadStatus = ReloadStatus{Files: []string{}}
+)
+
+// Reload loads the catalog and destinations and swaps them in atomically.
+// Invalid entries of subscriptions and custom presets are left out; any other
+// issue rejects the configuration and the last good one stays active.
+func Reload() (ReloadStatus, error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	now := time.Now()
+	reloadStatus.LastAttempt = &now
+
+	snapshot, skipped, err := loadSnapshot(currentCustomPresets())
+	if err != nil {
+		reloadStatus.Error = err.Error()
+		reloadStatus.Issues = nil
+		if validationErr, ok := err.(*ValidationError); ok {
+			reloadStatus.Issues = validationErr.Issues
+		}
+		return reloadStatus, err
+	}
+
+	activeSnapshot.Store(snapshot)
+	reloadStatus.LoadedAt = &now
+	reloadStatus.Error = ""
+	reloadStatus.Issues = nil
+	reloadStatus.Skipped = skipped
+	reloadStatus.Files = ConfigFiles()
+	reloadStatus.Presets = len(snapshot.catalog.Resources)
+	reloadStatus.Bundles = len(snapshot.catalog.Bundles)
+	reloadStatus.Destinations = len(snapshot.destinations.ActiveProfile().Destinations)
+	reloadStatus.Profiles = len(snapshot.destinations.Profiles)
+	reloadStatus.ActiveProfile = snapshot.destinations.Active
+	return reloadStatus, nil
+}
+
+// GetReloadStatus returns the result of the last reload
+func GetReloadStatus() ReloadStatus {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	return reloadStatus
+}
+
+// currentSnapshot returns the active configuration, loading it on first use
+func currentSnapshot() (*configSnapshot, error) {
+	if snapshot := activeSnapshot.Load(); snapshot != nil {
+		return snapshot, nil
+	}
+	if _, err := Reload(); err != nil {
+		return nil, err
+	}
+	return activeSnapshot.Load(), nil
+}
+
+// loadSnapshot loads the catalog with the given custom presets and the
+// destinations. Entries of subscriptions and custom presets with issues are
+// left out and returned as skipped, which may in turn leave out entries that
+// depend on them. Issues of the embedded catalog, local files or the
+// destinations reject the configuration.
+func loadSnapshot(custom [][]byte) (*configSnapshot, []CatalogIssue, error) {
+	skip := make(map[string]map[string]bool)
+	skipped := make([]CatalogIssue, 0)
+	for {
+		snapshot, issues, err := buildSnapshot(custom, skip)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(issues) == 0 {
+			return snapshot, skipped, nil
+		}
+
+		for _, issue := range issues {
+			if !isRemoteSource(issue.File) || skip[issue.File][issue.ID] {
+				return nil, nil, &ValidationError{Issues: issues}
+			}
+		}
+		for _, issue := range issues {
+			if skip[issue.File] == nil {
+				skip[issue.File] = make(map[string]bool)
+			}
+			skip[issue.File][issue.ID] = true
+		}
+		skipped = append(skipped, issues...)
+	}
+}
+
+// buildSnapshot loads the catalog without the skipped entries and checks it
+// against the destinations
+func buildSnapshot(custom [][]byte, skip map[string]map[string]bool) (*configSnapshot, []CatalogIssue, error) {
+	catalog, issues, err := loadCatalog(custom, skip)
+	if err != nil || len(issues) > 0 {
+		return nil, issues, err
+	}
+	destinations, err := LoadDestinations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if issues := validateCatalog(catalog, destinations); len(issues) > 0 {
+		return nil, issues, nil
+	}
+	return &configSnapshot{catalog: catalog, destinations: destinations}, nil, nil
+}
+
+// ConfigFiles returns the files on disk the configuration is loaded from
+func ConfigFiles() []string {
+	files := make([]string, 0)
+	if catalogFiles, err := CatalogFiles(catalogDir); err == nil {
+		files = append(files, catalogFiles...)
+	}
+	subs, cacheDir := currentSubscriptions()
+	for _, sub := range subs {
+		files = append(files, subscriptionCachePath(cacheDir, sub.Name))
+	}
+	if destinationsFile != "" {
+		files = append(files, destinationsFile)
+	}
+
+	existing := make([]string, 0, len(files))
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	return existing
+}
+
+// ConfigFingerprint changes whenever a configuration file is added, removed or modified
+func ConfigFingerprint() string {
+	files := ConfigFiles()
+	sort.Strings(files)
+
+	var builder strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&builder, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return builder.String()
+}
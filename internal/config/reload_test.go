@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withCatalogDir places a local catalog file in the catalog directory
+func withCatalogDir(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "local.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	SetCatalogDir(dir)
+	t.Cleanup(func() { SetCatalogDir("") })
+}
+
+func TestLoadSnapshotLeavesOutInvalidRemoteEntries(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription string
+		custom       []string
+		local        string
+		wantPresets  []string
+		wantSkipped  []string
+		wantErr      bool
+	}{
+		{
+			name:         "valid subscription",
+			subscription: "resources:" + validRemoteEntry,
+			wantPresets:  []string{"team/good"},
+		},
+		{
+			name:         "subscription entry without url",
+			subscription: "resources:" + validRemoteEntry + "  - id: broken\n    name: Broken\n    type: lora\n",
+			wantPresets:  []string{"team/good"},
+			wantSkipped:  []string{"team/broken"},
+		},
+		{
+			name:         "dependents of a left out entry are left out too",
+			subscription: "resources:" + validRemoteEntry + "  - id: broken\n    name: Broken\n    type: lora\n  - id: child\n    name: Child\n    type: lora\n    url: https://example.com/c\n    depends_on: [broken]\n",
+			wantPresets:  []string{"team/good"},
+			wantSkipped:  []string{"team/broken", "team/child"},
+		},
+		{
+			name:         "unparsable subscription",
+			subscription: "resources: [",
+			wantSkipped:  []string{""},
+		},
+		{
+			name:         "custom preset with unknown dependency",
+			subscription: "resources:" + validRemoteEntry,
+			custom:       []string{`{"id":"mine","name":"Mine","type":"lora","url":"https://example.com/m","depends_on":["missing"]}`},
+			wantPresets:  []string{"team/good"},
+			wantSkipped:  []string{"mine"},
+		},
+		{
+			name:         "local file issues still reject the configuration",
+			subscription: "resources:" + validRemoteEntry,
+			local:        "resources:\n  - id: local\n    name: Local\n    type: lora\n",
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSubscription(t, "team", tt.subscription)
+			if tt.local != "" {
+				withCatalogDir(t, tt.local)
+			}
+			custom := make([][]byte, 0, len(tt.custom))
+			for _, entry := range tt.custom {
+				custom = append(custom, []byte(entry))
+			}
+
+			snapshot, skipped, err := loadSnapshot(custom)
+			if tt.wantErr {
+				var validation *ValidationError
+				if !errors.As(err, &validation) {
+					t.Fatalf("loadSnapshot() error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadSnapshot() error = %v", err)
+			}
+
+			presets := make(map[string]bool)
+			for _, resource := range snapshot.catalog.Resources {
+				presets[resource.ID] = true
+			}
+			for _, id := range tt.wantPresets {
+				if !presets[id] {
+					t.Errorf("preset %s is missing", id)
+				}
+			}
+
+			skippedIDs := make(map[string]bool)
+			for _, issue := range skipped {
+				skippedIDs[issue.ID] = true
+			}
+			for _, id := range tt.wantSkipped {
+				if !skippedIDs[id] {
+					t.Errorf("%q was not left out (skipped: %v)", id, skipped)
+				}
+				if presets[id] {
+					t.Errorf("left out preset %s is in the catalog", id)
+				}
+			}
+			if len(skippedIDs) != len(tt.wantSkipped) {
+				t.Errorf("skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestCheckSubscriptionRejectsCrossEntryIssues(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid", content: "resources:" + validRemoteEntry},
+		{name: "depends on embedded preset id", content: "resources:" + validRemoteEntry + "    depends_on: [missing-preset]\n", wantErr: true},
+		{name: "missing url", content: "resources:\n  - id: broken\n    name: Broken\n    type: lora\n", wantErr: true},
+		{name: "bundle with unknown preset", content: "bundles:\n  - id: set\n    name: Set\n    resources: [nope]\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSubscription("team", []byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSubscription() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReloadKeepsTheLastGoodConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "local.yaml")
+	SetCatalogDir(dir)
+	t.Cleanup(func() {
+		SetCatalogDir("")
+		Reload()
+	})
+
+	steps := []struct {
+		name        string
+		content     string
+		wantErr     bool
+		wantPresets []string // local presets in the active catalog after the reload
+	}{
+		{name: "valid file", content: "resources:\n  - id: local\n    name: Local\n    type: lora\n    url: https://example.com/l\n", wantPresets: []string{"local"}},
+		{name: "invalid file is rejected", content: "resources:\n  - id: other\n    name: Other\n    type: lora\n", wantErr: true, wantPresets: []string{"local"}},
+		{name: "unparsable file is rejected", content: "resources: [", wantErr: true, wantPresets: []string{"local"}},
+		{name: "fixed file", content: "resources:\n  - id: other\n    name: Other\n    type: lora\n    url: https://example.com/o\n", wantPresets: []string{"other"}},
+	}
+
+	var loadedAt *time.Time
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := os.WriteFile(file, []byte(step.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			status, err := Reload()
+			if step.wantErr {
+				if err == nil || status.Error == "" {
+					t.Fatalf("Reload() error = %v, status error = %q, want both", err, status.Error)
+				}
+				if status.LoadedAt != loadedAt {
+					t.Fatal("LoadedAt changed on a rejected reload")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Reload() error = %v", err)
+				}
+				if status.Error != "" || len(status.Issues) != 0 || status.LoadedAt == nil {
+					t.Fatalf("status = %+v", status)
+				}
+				if len(status.Files) != 1 || status.Files[0] != file {
+					t.Fatalf("files = %v, want %s", status.Files, file)
+				}
+				loadedAt = status.LoadedAt
+			}
+			if GetReloadStatus().Error != status.Error {
+				t.Fatalf("GetReloadStatus() = %+v, want %+v", GetReloadStatus(), status)
+			}
+
+			resources, err := GetPresetResources()
+			if err != nil {
+				t.Fatal(err)
+			}
+			local := make([]string, 0)
+			for _, resource := range resources {
+				if resource.ID == "local" || resource.ID == "other" {
+					local = append(local, resource.ID)
+				}
+			}
+			if strings.Join(local, " ") != strings.Join(step.wantPresets, " ") {
+				t.Fatalf("local presets = %v, want %v", local, step.wantPresets)
+			}
+		})
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	SetCatalogDir(dir)
+	t.Cleanup(func() { SetCatalogDir("") })
+
+	empty := ConfigFingerprint()
+	file := filepath.Join(dir, "local.yaml")
+	if err := os.WriteFile(file, []byte("resources: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	added := ConfigFingerprint()
+	if added == empty {
+		t.Fatal("fingerprint did not change when a file was added")
+	}
+	if ConfigFingerprint() != added {
+		t.Fatal("fingerprint changed without a change on disk")
+	}
+
+	if err := os.WriteFile(file, []byte("resources: []\nbundles: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ConfigFingerprint() == added {
+		t.Fatal("fingerprint did not change when a file was edited")
+	}
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if ConfigFingerprint() != empty {
+		t.Fatal("fingerprint did not go back when the file was removed")
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ResourceType maps a preset type to the destination type it installs to
+type ResourceType struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+}
+
+// resourceTypeRegistry is the canonical list of resource types
+var resourceTypeRegistry = []ResourceType{
+	{Name: "checkpoint", Destination: "checkpoints"},
+	{Name: "lora", Destination: "loras"},
+	{Name: "vae", Destination: "vae"},
+	{Name: "embedding", Destination: "embeddings"},
+	{Name: "upscale_model", Destination: "upscale_models"},
+	{Name: "controlnet", Destination: "controlnet"},
+	{Name: "extension", Destination: "extensions"},
+	{Name: "script", Destination: "scripts"},
+}
+
+// ResourceTypes lists the preset types the installer knows about
+var ResourceTypes = func() []string {
+	names := make([]string, len(resourceTypeRegistry))
+	for i, resourceType := range resourceTypeRegistry {
+		names[i] = resourceType.Name
+	}
+	return names
+}()
+
+// GetResourceTypes returns the resource type registry
+func GetResourceTypes() []ResourceType {
+	return append([]ResourceType{}, resourceTypeRegistry...)
+}
+
+// LookupResourceType returns the registry entry of a resource type. The
+// destination type is accepted as well, so both lora and loras find the LoRA type.
+func LookupResourceType(name string) (ResourceType, bool) {
+	for _, resourceType := range resourceTypeRegistry {
+		if resourceType.Name == name || resourceType.Destination == name {
+			return resourceType, true
+		}
+	}
+	return ResourceType{}, false
+}
+
+// DestinationType returns the logical destination of a preset: Destination if
+// set, otherwise the destination of its type. Presets with an explicit
+// destination_path have none.
+func (p PresetResource) DestinationType() string {
+	if p.Destination != "" || p.DestinationPath != "" {
+		return p.Destination
+	}
+	if resourceType, exists := LookupResourceType(p.Type); exists {
+		return resourceType.Destination
+	}
+	return ""
+}
+
+// CheckDestination returns an error if path is an install destination of
+// another type than the resource type installs to. Paths that are not an
+// install destination of any profile are accepted.
+func (d *Destinations) CheckDestination(resourceType ResourceType, path string) error {
+	path = filepath.Clean(path)
+	mismatch := ""
+	for _, profile := range d.Profiles {
+		for _, destination := range profile.Destinations {
+			if filepath.Clean(destination.Path) != path {
+				continue
+			}
+			if destination.Type == resourceType.Destination {
+				return nil
+			}
+			mismatch = destination.Type
+		}
+	}
+	if mismatch != "" {
+		return fmt.Errorf("%s resources install to %s, not %s", resourceType.Name, resourceType.Destination, mismatch)
+	}
+	return nil
+}
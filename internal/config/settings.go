@@ -0,0 +1,344 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/downloader"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the config file when --config is not given
+const ConfigFileEnv = "STATION_CONFIG"
+
+// Where a setting comes from, from highest to lowest precedence
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// variablesSection is the config file section holding path variables
+const variablesSection = "variables"
+
+// Setting describes a configuration value and where it can be set
+type Setting struct {
+	Key      string // key in the config file, as section.name
+	Env      string // environment variable; credentials have none
+	FlagName string // flag when it differs from the one named after Env
+	Default  string
+	Usage    string
+	// Credential settings reference a token as env:NAME or file:PATH
+	Credential bool
+	apply      func(cfg *Config, value string) error
+}
+
+// Flag returns the command line flag of the setting, named after its
+// environment variable (DB_PATH is -db-path) unless it has its own name,
+// or "" if it has none
+func (s Setting) Flag() string {
+	if s.FlagName != "" {
+		return s.FlagName
+	}
+	return strings.ReplaceAll(strings.ToLower(s.Env), "_", "-")
+}
+
+// Settings lists every server setting
+var Settings = []Setting{
+	// HOST is set to the machine name in some shells and containers
+	{Key: "server.host", Env: "STATION_HOST", FlagName: "host", Default: "", Usage: "Address to listen on (empty for all interfaces)",
+		apply: func(cfg *Config, value string) error { cfg.Host = value; return nil }},
+	{Key: "server.port", Env: "PORT", Default: "8080", Usage: "Port to run the server on",
+		apply: func(cfg *Config, value string) error { cfg.Port = value; return nil }},
+	{Key: "server.base_url", Env: "BASE_URL", Default: "", Usage: "Base URL for the server",
+		apply: func(cfg *Config, value string) error { cfg.BaseURL = normalizeBaseURL(value); return nil }},
+	{Key: "server.log_level", Env: "LOG_LEVEL", Default: "info", Usage: "Log level (debug, info, warn, error)",
+		apply: func(cfg *Config, value string) error { cfg.LogLevel = value; return nil }},
+
+	{Key: "database.path", Env: "DB_PATH", Default: "./data.db", Usage: "Database file path",
+		apply: func(cfg *Config, value string) error { cfg.DBPath = value; return nil }},
+
+	{Key: "catalog.dir", Env: "CATALOG_DIR", Default: "/storage/station/catalogs", Usage: "Directory of extra preset catalogs",
+		apply: func(cfg *Config, value string) error { cfg.CatalogDir = value; return nil }},
+	{Key: "catalog.subscriptions", Env: "CATALOG_SUBSCRIPTIONS", Default: "", Usage: "Remote catalogs as name=url pairs",
+		apply: func(cfg *Config, value string) error { cfg.CatalogSubscriptions = value; return nil }},
+	{Key: "catalog.cache_dir", Env: "CATALOG_CACHE_DIR", Default: "/storage/station/catalog-cache", Usage: "Cache directory of remote catalogs",
+		apply: func(cfg *Config, value string) error { cfg.CatalogCacheDir = value; return nil }},
+	{Key: "catalog.refresh_interval", Env: "CATALOG_REFRESH_INTERVAL", Default: "1h", Usage: "How often remote catalogs are refreshed",
+		apply: func(cfg *Config, value string) (err error) {
+			cfg.CatalogRefreshInterval, err = parseDuration(value)
+			return err
+		}},
+	{Key: "catalog.watch_interval", Env: "CONFIG_WATCH_INTERVAL", Default: "5s", Usage: "How often catalog and destinations files are checked for changes (0 disables)",
+		apply: func(cfg *Config, value string) (err error) {
+			cfg.ConfigWatchInterval, err = parseDuration(value)
+			return err
+		}},
+
+	{Key: "destinations.file", Env: "INSTALL_DESTINATIONS_FILE", Default: "/storage/station/install_destinations.yaml", Usage: "Install destinations file",
+		apply: func(cfg *Config, value string) error { cfg.DestinationsFile = value; return nil }},
+	{Key: "destinations.profile", Env: "DESTINATION_PROFILE", Default: "", Usage: "Destination profile installs go to by default",
+		apply: func(cfg *Config, value string) error { cfg.DestinationProfile = value; return nil }},
+	{Key: "destinations.profile_roots", Env: "PROFILE_ROOTS", Default: "", Usage: "Profile root directories as profile=path pairs",
+		apply: func(cfg *Config, value string) error { cfg.ProfileRoots = value; return nil }},
+
+	{Key: "installer.download_backend", Env: "DOWNLOAD_BACKEND", Default: downloader.BackendWget, Usage: "Download backend (wget, http)",
+		apply: func(cfg *Config, value string) error {
+			if !contains(downloader.Backends, value) {
+				return fmt.Errorf("expected one of %s", strings.Join(downloader.Backends, ", "))
+			}
+			cfg.DownloadBackend = value
+			return nil
+		}},
+	{Key: "installer.max_concurrent", Env: "MAX_CONCURRENT_INSTALLS", Default: strconv.Itoa(DefaultMaxConcurrentInstalls), Usage: "Install tasks running at the same time",
+		apply: func(cfg *Config, value string) (err error) {
+			cfg.MaxConcurrentInstalls, err = parseInt(value)
+			return err
+		}},
+	{Key: "installer.allow_command_steps", Env: "ALLOW_COMMAND_STEPS", Default: "false", Usage: "Run post-install steps with action command",
+		apply: func(cfg *Config, value string) (err error) {
+			cfg.AllowCommandSteps, err = parseBool(value)
+			return err
+		}},
+	{Key: "installer.interrupted_task_policy", Env: "INTERRUPTED_TASK_POLICY", Default: "resume", Usage: "Tasks interrupted by a restart: resume or hold",
+		apply: func(cfg *Config, value string) error { cfg.InterruptedTaskPolicy = value; return nil }},
+	{Key: "installer.update_check_interval", Env: "UPDATE_CHECK_INTERVAL", Default: "6h", Usage: "How often installed resources are checked for updates (0 disables)",
+		apply: func(cfg *Config, value string) (err error) {
+			cfg.UpdateCheckInterval, err = parseDuration(value)
+			return err
+		}},
+	{Key: "installer.task_retention_max_age", Env: "TASK_RETENTION_MAX_AGE", Default: "168h", Usage: "Age after which finished tasks are pruned (0 disables)",
+		apply: func(cfg *Config, value string) (err error) {
+			cfg.TaskRetentionMaxAge, err = parseDuration(value)
+			return err
+		}},
+	{Key: "installer.task_retention_max_count", Env: "TASK_RETENTION_MAX_COUNT", Default: "200", Usage: "Finished tasks kept at most (0 disables)",
+		apply: func(cfg *Config, value string) (err error) {
+			cfg.TaskRetentionMaxCount, err = parseInt(value)
+			return err
+		}},
+
+	{Key: "credentials.huggingface_token", Default: "env:HF_TOKEN", Usage: "Where the Hugging Face token is read from (env:NAME or file:PATH)", Credential: true,
+		apply: func(cfg *Config, value string) error {
+			cfg.HuggingFaceToken = value
+			return checkCredentialReference(value)
+		}},
+	{Key: "credentials.civitai_token", Default: "env:CIVITAI_TOKEN", Usage: "Where the Civitai API key is read from (env:NAME or file:PATH)", Credential: true,
+		apply: func(cfg *Config, value string) error {
+			cfg.CivitaiToken = value
+			return checkCredentialReference(value)
+		}},
+}
+
+// LoadOptions are the inputs of Load besides the environment
+type LoadOptions struct {
+	File  string            // config file; STATION_CONFIG if empty
+	Flags map[string]string // command line values by setting key
+}
+
+// Load builds the configuration from command line flags, environment
+// variables, the config file and defaults, in that order of precedence
+func Load(options LoadOptions) (*Config, error) {
+	cfg := &Config{
+		File:    options.File,
+		Sources: make(map[string]string, len(Settings)),
+		values:  make(map[string]string, len(Settings)),
+	}
+	if cfg.File == "" {
+		cfg.File = os.Getenv(ConfigFileEnv)
+	}
+
+	fileValues := make(map[string]string)
+	if cfg.File != "" {
+		values, variables, err := readConfigFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		fileValues = values
+		cfg.PathVariables = variables
+	}
+
+	for _, setting := range Settings {
+		value, source := setting.Default, SourceDefault
+		if fileValue, exists := fileValues[setting.Key]; exists {
+			value, source = fileValue, SourceFile
+		}
+		if setting.Env != "" {
+			if envValue := os.Getenv(setting.Env); envValue != "" {
+				value, source = envValue, SourceEnv+" "+setting.Env
+			}
+		}
+		if flagValue, exists := options.Flags[setting.Key]; exists {
+			value, source = flagValue, SourceFlag+" -"+setting.Flag()
+		}
+
+		if err := setting.apply(cfg, value); err != nil {
+			// A misplaced token must not end up in the output
+			if setting.Credential {
+				return nil, fmt.Errorf("%s: invalid value from %s: %v", setting.Key, source, err)
+			}
+			return nil, fmt.Errorf("%s: invalid value %q from %s: %v", setting.Key, value, source, err)
+		}
+		cfg.Sources[setting.Key] = source
+		cfg.values[setting.Key] = value
+	}
+	return cfg, nil
+}
+
+// Value returns the effective value of a setting as it was given
+func (c *Config) Value(key string) string {
+	return c.values[key]
+}
+
+// readConfigFile reads the settings of a YAML config file as strings by key,
+// and its path variables
+func readConfigFile(path string) (map[string]string, map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var sections map[string]yaml.Node
+	if err := yaml.Unmarshal(content, &sections); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	known := make(map[string]bool, len(Settings))
+	for _, setting := range Settings {
+		known[setting.Key] = true
+	}
+
+	values := make(map[string]string)
+	variables := make(map[string]string)
+	for section, node := range sections {
+		if node.Kind != yaml.MappingNode {
+			return nil, nil, fmt.Errorf("%s:%d: section %s must be a mapping", path, node.Line, section)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, valueNode := node.Content[i].Value, node.Content[i+1]
+			value, err := settingValue(valueNode)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s:%d: %s.%s %v", path, valueNode.Line, section, name, err)
+			}
+			if section == variablesSection {
+				variables[name] = value
+				continue
+			}
+
+			key := section + "." + name
+			if !known[key] {
+				return nil, nil, fmt.Errorf("%s:%d: unknown setting %s", path, node.Content[i].Line, key)
+			}
+			values[key] = value
+		}
+	}
+	return values, variables, nil
+}
+
+// settingValue converts a config file value to the format of its environment
+// variable: lists are comma separated and mappings become name=value pairs
+func settingValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "", nil
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("must be a list of values")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	case yaml.MappingNode:
+		pairs := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("must map names to values")
+			}
+			pairs = append(pairs, node.Content[i].Value+"="+node.Content[i+1].Value)
+		}
+		return strings.Join(pairs, ","), nil
+	}
+	return "", fmt.Errorf("has an unsupported value")
+}
+
+// checkCredentialReference checks that a credential is referenced as env:NAME
+// or file:PATH; tokens themselves do not belong in the config
+func checkCredentialReference(reference string) error {
+	if reference == "" {
+		return nil
+	}
+	kind, target, _ := strings.Cut(reference, ":")
+	if (kind != "env" && kind != "file") || target == "" {
+		return fmt.Errorf("expected env:NAME or file:PATH")
+	}
+	return nil
+}
+
+// ResolveCredential returns the token a credential reference points to, or ""
+// if the environment variable is not set
+func ResolveCredential(reference string) (string, error) {
+	kind, target, _ := strings.Cut(reference, ":")
+	switch kind {
+	case "":
+		return "", nil
+	case "env":
+		return os.Getenv(target), nil
+	case "file":
+		path, err := ExpandPath(target)
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read credential: %v", err)
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+	return "", fmt.Errorf("invalid credential reference %q", reference)
+}
+
+// normalizeBaseURL prevents Git Bash on Windows from turning the base URL into a path
+func normalizeBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return ""
+	}
+	normalized := strings.TrimPrefix(baseURL, "C:/Program Files/Git")
+	if !strings.HasPrefix(normalized, "/") {
+		normalized = "/" + normalized
+	}
+	return normalized
+}
+
+func parseInt(value string) (int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer")
+	}
+	return parsed, nil
+}
+
+func parseBool(value string) (bool, error) {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("expected true or false")
+	}
+	return parsed, nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a duration such as 30s or 6h")
+	}
+	return parsed, nil
+}
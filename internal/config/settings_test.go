@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDefaultsMaxConcurrentInstalls(t *testing.T) {
+	t.Setenv(ConfigFileEnv, "")
+	t.Setenv("MAX_CONCURRENT_INSTALLS", "")
+
+	cfg, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxConcurrentInstalls != DefaultMaxConcurrentInstalls {
+		t.Fatalf("MaxConcurrentInstalls = %d, want %d", cfg.MaxConcurrentInstalls, DefaultMaxConcurrentInstalls)
+	}
+}
+
+// writeConfigFile writes a config file for a test and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "station.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const key = "installer.max_concurrent"
+	file := "installer:\n  max_concurrent: 3\n"
+
+	tests := []struct {
+		name       string
+		file       string
+		env        string
+		flag       string
+		want       int
+		wantSource string
+	}{
+		{name: "default", want: DefaultMaxConcurrentInstalls, wantSource: SourceDefault},
+		{name: "file over default", file: file, want: 3, wantSource: SourceFile},
+		{name: "env over file", file: file, env: "4", want: 4, wantSource: SourceEnv + " MAX_CONCURRENT_INSTALLS"},
+		{name: "flag over env", file: file, env: "4", flag: "5", want: 5, wantSource: SourceFlag + " -max-concurrent-installs"},
+		{name: "empty env is unset", file: file, env: "", want: 3, wantSource: SourceFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, "")
+			t.Setenv("MAX_CONCURRENT_INSTALLS", tt.env)
+			options := LoadOptions{}
+			if tt.file != "" {
+				options.File = writeConfigFile(t, tt.file)
+			}
+			if tt.flag != "" {
+				options.Flags = map[string]string{key: tt.flag}
+			}
+
+			cfg, err := Load(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.MaxConcurrentInstalls != tt.want || cfg.Sources[key] != tt.wantSource {
+				t.Fatalf("%s = %d from %q, want %d from %q", key, cfg.MaxConcurrentInstalls, cfg.Sources[key], tt.want, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileFromEnvironment(t *testing.T) {
+	t.Setenv("MAX_CONCURRENT_INSTALLS", "")
+	t.Setenv(ConfigFileEnv, writeConfigFile(t, "installer:\n  max_concurrent: 7\nvariables:\n  MODELS: /data/models\n"))
+
+	cfg, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxConcurrentInstalls != 7 || cfg.PathVariables["MODELS"] != "/data/models" {
+		t.Fatalf("config = %d, variables %v", cfg.MaxConcurrentInstalls, cfg.PathVariables)
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		flags   map[string]string
+		want    string // substring of the error
+		notWant string // must not appear in the error
+	}{
+		{name: "unknown setting", file: "installer:\n  max_parallel: 3\n", want: "unknown setting installer.max_parallel"},
+		{name: "section that is not a mapping", file: "installer: 3\n", want: "must be a mapping"},
+		{name: "invalid integer", flags: map[string]string{"installer.max_concurrent": "many"}, want: `invalid value "many"`},
+		{
+			name:    "token in place of a credential reference",
+			flags:   map[string]string{"credentials.huggingface_token": "hf_secret"},
+			want:    "credentials.huggingface_token",
+			notWant: "hf_secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, "")
+			options := LoadOptions{Flags: tt.flags}
+			if tt.file != "" {
+				options.File = writeConfigFile(t, tt.file)
+			}
+
+			_, err := Load(options)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load() error = %v, want one containing %q", err, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(err.Error(), tt.notWant) {
+				t.Fatalf("Load() error %q contains %q", err, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestLoadHost(t *testing.T) {
+	const key = "server.host"
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		flag       string
+		want       string
+		wantSource string
+	}{
+		{name: "HOST is not read", env: map[string]string{"HOST": "my-machine"}, want: "", wantSource: SourceDefault},
+		{name: "STATION_HOST", env: map[string]string{"HOST": "my-machine", "STATION_HOST": "127.0.0.1"}, want: "127.0.0.1", wantSource: SourceEnv + " STATION_HOST"},
+		{name: "flag keeps its name", env: map[string]string{"STATION_HOST": "127.0.0.1"}, flag: "0.0.0.0", want: "0.0.0.0", wantSource: SourceFlag + " -host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigFileEnv, "")
+			t.Setenv("HOST", "")
+			t.Setenv("STATION_HOST", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			options := LoadOptions{}
+			if tt.flag != "" {
+				options.Flags = map[string]string{key: tt.flag}
+			}
+
+			cfg, err := Load(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Host != tt.want || cfg.Sources[key] != tt.wantSource {
+				t.Fatalf("%s = %q from %q, want %q from %q", key, cfg.Host, cfg.Sources[key], tt.want, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Largest remote catalog that is accepted
+const maxRemoteCatalogSize = 10 << 20
+
+// subscriptionNameRegex restricts names because they become ID prefixes and file names
+var subscriptionNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+var subscriptionClient = &http.Client{Timeout: time.Minute}
+
+// CatalogSubscription is a remote catalog merged under its own namespace
+type CatalogSubscription struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// SubscriptionStatus reports the state of a subscription
+type SubscriptionStatus struct {
+	CatalogSubscription
+	LastFetch    *time.Time `json:"lastFetch,omitempty"`   // last fetch attempt
+	LastUpdated  *time.Time `json:"lastUpdated,omitempty"` // last time new content was received
+	ETag         string     `json:"etag,omitempty"`
+	LastModified string     `json:"lastModified,omitempty"`
+	Presets      int        `json:"presets"`
+	Bundles      int        `json:"bundles"`
+	Cached       bool       `json:"cached"` // a copy is available for offline starts
+	Error        string     `json:"error,omitempty"`
+}
+
+var (
+	subscriptionsMutex   sync.RWMutex
+	subscriptions        []CatalogSubscription
+	subscriptionCacheDir string
+
+	// refreshMutex serializes refreshes; readers only ever see complete cache files
+	refreshMutex sync.Mutex
+)
+
+// ParseCatalogSubscriptions parses a comma separated list of name=url pairs
+func ParseCatalogSubscriptions(value string) ([]CatalogSubscription, error) {
+	result := make([]CatalogSubscription, 0)
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, url, found := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		url = strings.TrimSpace(url)
+		if !found || url == "" {
+			return nil, fmt.Errorf("subscription %q must be in name=url format", item)
+		}
+		if !subscriptionNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("subscription name %q may only contain lowercase letters, digits, '-' and '_'", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate subscription name %q", name)
+		}
+		seen[name] = true
+		result = append(result, CatalogSubscription{Name: name, URL: url})
+	}
+	return result, nil
+}
+
+// SetCatalogSubscriptions sets the remote catalogs and the directory they are cached in
+func SetCatalogSubscriptions(subs []CatalogSubscription, cacheDir string) {
+	subscriptionsMutex.Lock()
+	defer subscriptionsMutex.Unlock()
+
+	subscriptions = subs
+	subscriptionCacheDir = cacheDir
+}
+
+// currentSubscriptions returns the configured subscriptions and cache directory
+func currentSubscriptions() ([]CatalogSubscription, string) {
+	subscriptionsMutex.RLock()
+	defer subscriptionsMutex.RUnlock()
+
+	return subscriptions, subscriptionCacheDir
+}
+
+func subscriptionCachePath(cacheDir, name string) string {
+	return filepath.Join(cacheDir, name+".yaml")
+}
+
+func subscriptionStatusPath(cacheDir, name string) string {
+	return filepath.Join(cacheDir, name+".status.json")
+}
+
+// readSubscriptionStatus loads the stored status of a subscription
+func readSubscriptionStatus(cacheDir string, sub CatalogSubscription) SubscriptionStatus {
+	status := SubscriptionStatus{CatalogSubscription: sub}
+	if content, err := os.ReadFile(subscriptionStatusPath(cacheDir, sub.Name)); err == nil {
+		json.Unmarshal(content, &status)
+		status.CatalogSubscription = sub
+	}
+	_, err := os.Stat(subscriptionCachePath(cacheDir, sub.Name))
+	status.Cached = err == nil
+	return status
+}
+
+// writeSubscriptionStatus stores the status of a subscription
+func writeSubscriptionStatus(cacheDir string, status SubscriptionStatus) error {
+	content, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(subscriptionStatusPath(cacheDir, status.Name), content)
+}
+
+// GetSubscriptionStatuses returns the status of every subscription
+func GetSubscriptionStatuses() []SubscriptionStatus {
+	subs, cacheDir := currentSubscriptions()
+
+	statuses := make([]SubscriptionStatus, 0, len(subs))
+	for _, sub := range subs {
+		statuses = append(statuses, readSubscriptionStatus(cacheDir, sub))
+	}
+	return statuses
+}
+
+// RefreshSubscriptions fetches every remote catalog and returns their status.
+// A catalog that fails to download or parse keeps its cached copy.
+func RefreshSubscriptions() []SubscriptionStatus {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+
+	subs, cacheDir := currentSubscriptions()
+	statuses := make([]SubscriptionStatus, 0, len(subs))
+	var mkdirErr error
+	if len(subs) > 0 {
+		mkdirErr = os.MkdirAll(cacheDir, 0755)
+	}
+
+	for _, sub := range subs {
+		status := readSubscriptionStatus(cacheDir, sub)
+		if mkdirErr != nil {
+			status.Error = fmt.Sprintf("failed to create cache directory: %v", mkdirErr)
+			statuses = append(statuses, status)
+			continue
+		}
+
+		now := time.Now()
+		status.LastFetch = &now
+		status.Error = ""
+		if err := fetchSubscription(cacheDir, &status); err != nil {
+			status.Error = err.Error()
+		}
+		if err := writeSubscriptionStatus(cacheDir, status); err != nil && status.Error == "" {
+			status.Error = fmt.Sprintf("failed to store status: %v", err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// fetchSubscription downloads a remote catalog with a conditional request and caches it
+func fetchSubscription(cacheDir string, status *SubscriptionStatus) error {
+	req, err := http.NewRequest(http.MethodGet, status.URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if status.Cached {
+		if status.ETag != "" {
+			req.Header.Set("If-None-Match", status.ETag)
+		}
+		if status.LastModified != "" {
+			req.Header.Set("If-Modified-Since", status.LastModified)
+		}
+	}
+
+	resp, err := subscriptionClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status: %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteCatalogSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if len(content) > maxRemoteCatalogSize {
+		return fmt.Errorf("catalog is larger than %d bytes", maxRemoteCatalogSize)
+	}
+
+	// Only a catalog that loads cleanly replaces the cached copy
+	merger := newCatalogMerger()
+	if err := merger.mergeNamespaced(status.Name, subscriptionSource(status.Name), content); err != nil {
+		return err
+	}
+	if len(merger.issues) > 0 {
+		return &ValidationError{Issues: merger.issues}
+	}
+	if err := checkSubscription(status.Name, content); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(subscriptionCachePath(cacheDir, status.Name), content); err != nil {
+		return fmt.Errorf("failed to cache catalog: %v", err)
+	}
+
+	catalog := merger.catalog()
+	now := time.Now()
+	status.LastUpdated = &now
+	status.ETag = resp.Header.Get("ETag")
+	status.LastModified = resp.Header.Get("Last-Modified")
+	status.Presets = len(catalog.Resources)
+	status.Bundles = len(catalog.Bundles)
+	status.Cached = true
+	return nil
+}
+
+// checkSubscription validates a remote catalog merged over the embedded
+// catalog against the destinations, reporting only the issues of its own
+// entries (missing fields, unknown dependencies, unusable destinations)
+func checkSubscription(name string, content []byte) error {
+	source := subscriptionSource(name)
+	merger := newCatalogMerger()
+	if err := merger.merge(EmbeddedCatalogSource, presetResourcesYAML); err != nil {
+		return err
+	}
+	if err := merger.mergeNamespaced(name, source, content); err != nil {
+		return err
+	}
+	destinations, err := LoadDestinations()
+	if err != nil {
+		return err
+	}
+
+	catalog := merger.catalog()
+	issues := append(expandCatalogPaths(catalog), validateCatalog(catalog, destinations)...)
+	own := make([]CatalogIssue, 0)
+	for _, issue := range issues {
+		if issue.File == source {
+			own = append(own, issue)
+		}
+	}
+	if len(own) > 0 {
+		return &ValidationError{Issues: own}
+	}
+	return nil
+}
+
+// cachedSubscriptions returns the cached content of every subscription that has one
+func cachedSubscriptions() ([]CatalogSubscription, [][]byte) {
+	subscriptions, cacheDir := currentSubscriptions()
+
+	subs := make([]CatalogSubscription, 0, len(subscriptions))
+	contents := make([][]byte, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		content, err := os.ReadFile(subscriptionCachePath(cacheDir, sub.Name))
+		if err != nil {
+			continue
+		}
+		subs = append(subs, sub)
+		contents = append(contents, content)
+	}
+	return subs, contents
+}
+
+// namespaceCatalog prefixes the IDs of a remote catalog with its namespace,
+// including references between its own entries
+func namespaceCatalog(namespace string, file *catalogFile) error {
+	ids := make(map[string]bool)
+	for i := range file.Resources {
+		var head catalogEntry
+		if err := file.Resources[i].Decode(&head); err != nil {
+			return err
+		}
+		ids[head.ID] = true
+	}
+
+	rename := func(id string) string {
+		if ids[id] {
+			return namespace + "/" + id
+		}
+		return id
+	}
+	for i := range file.Resources {
+		rewriteNodeValues(&file.Resources[i], "id", func(id string) string { return namespace + "/" + id })
+		rewriteNodeValues(&file.Resources[i], "depends_on", rename)
+	}
+	for i := range file.Bundles {
+		rewriteNodeValues(&file.Bundles[i], "id", func(id string) string { return namespace + "/" + id })
+		rewriteNodeValues(&file.Bundles[i], "resources", rename)
+	}
+	return nil
+}
+
+// rewriteNodeValues rewrites the scalar value, or each scalar of the sequence,
+// stored under key in a mapping node
+func rewriteNodeValues(node *yaml.Node, key string, rewrite func(string) string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		value := node.Content[i+1]
+		switch value.Kind {
+		case yaml.ScalarNode:
+			value.Value = rewrite(value.Value)
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item.Kind == yaml.ScalarNode {
+					item.Value = rewrite(item.Value)
+				}
+			}
+		}
+	}
+}
+
+// writeFileAtomic replaces a file without ever leaving it half written
+func writeFileAtomic(path string, content []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// withSubscription caches a remote catalog as if it had been fetched
+func withSubscription(t *testing.T, name, content string) {
+	t.Helper()
+	cacheDir := t.TempDir()
+	if err := os.WriteFile(subscriptionCachePath(cacheDir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	SetCatalogSubscriptions([]CatalogSubscription{{Name: name, URL: "https://example.com/" + name + ".yaml"}}, cacheDir)
+	t.Cleanup(func() { SetCatalogSubscriptions(nil, "") })
+}
+
+const validRemoteEntry = `
+  - id: good
+    name: Good
+    type: lora
+    url: https://example.com/good.safetensors
+`
+
+func TestParseCatalogSubscriptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []CatalogSubscription
+		wantErr string
+	}{
+		{name: "empty", value: "", want: []CatalogSubscription{}},
+		{name: "pairs", value: " team = https://example.com/team.yaml ,, lab=https://example.com/lab.yaml", want: []CatalogSubscription{
+			{Name: "team", URL: "https://example.com/team.yaml"},
+			{Name: "lab", URL: "https://example.com/lab.yaml"},
+		}},
+		{name: "missing url", value: "team=", wantErr: "name=url format"},
+		{name: "invalid name", value: "Team/A=https://example.com/a.yaml", wantErr: "may only contain"},
+		{name: "duplicate name", value: "a=https://example.com/1.yaml,a=https://example.com/2.yaml", wantErr: "duplicate subscription"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCatalogSubscriptions(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("subscriptions = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("subscriptions = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshSubscriptions(t *testing.T) {
+	valid := "resources:" + validRemoteEntry + `
+  - id: addon
+    name: Addon
+    type: lora
+    url: https://example.com/addon.safetensors
+    depends_on: [good]
+`
+	// Each request gets the next response; the server records the conditional headers
+	type response struct {
+		status int
+		body   string
+	}
+	var responses []response
+	var ifNoneMatch []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		next := responses[0]
+		responses = responses[1:]
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(next.status)
+		w.Write([]byte(next.body))
+	}))
+	t.Cleanup(server.Close)
+
+	cacheDir := t.TempDir()
+	SetCatalogSubscriptions([]CatalogSubscription{{Name: "team", URL: server.URL + "/team.yaml"}}, cacheDir)
+	t.Cleanup(func() { SetCatalogSubscriptions(nil, "") })
+
+	steps := []struct {
+		name        string
+		response    response
+		wantErr     string
+		wantPresets int
+		wantHeader  string // If-None-Match sent with the request
+	}{
+		{name: "first fetch", response: response{http.StatusOK, valid}, wantPresets: 2},
+		{name: "not modified", response: response{http.StatusNotModified, ""}, wantPresets: 2, wantHeader: `"v1"`},
+		{name: "invalid catalog keeps the cache", response: response{http.StatusOK, "resources:\n  - id: broken\n"}, wantErr: "broken", wantPresets: 2, wantHeader: `"v1"`},
+		{name: "server error keeps the cache", response: response{http.StatusInternalServerError, ""}, wantErr: "status: 500", wantPresets: 2, wantHeader: `"v1"`},
+	}
+
+	for i, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			responses = append(responses, step.response)
+			statuses := RefreshSubscriptions()
+			if len(statuses) != 1 {
+				t.Fatalf("statuses = %v", statuses)
+			}
+			status := statuses[0]
+			if step.wantErr == "" && status.Error != "" || step.wantErr != "" && !strings.Contains(status.Error, step.wantErr) {
+				t.Fatalf("error = %q, want %q", status.Error, step.wantErr)
+			}
+			if !status.Cached || status.Presets != step.wantPresets || status.LastFetch == nil {
+				t.Fatalf("status = %+v", status)
+			}
+			if ifNoneMatch[i] != step.wantHeader {
+				t.Fatalf("If-None-Match = %q, want %q", ifNoneMatch[i], step.wantHeader)
+			}
+
+			// The stored status is what the API reports between refreshes
+			stored := GetSubscriptionStatuses()
+			if len(stored) != 1 || stored[0].Error != status.Error || stored[0].Presets != status.Presets {
+				t.Fatalf("stored status = %+v, want %+v", stored, status)
+			}
+			cached, err := os.ReadFile(subscriptionCachePath(cacheDir, "team"))
+			if err != nil || string(cached) != valid {
+				t.Fatalf("cached catalog = %q (%v), want the first fetch", cached, err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionEntriesAreNamespaced(t *testing.T) {
+	withSubscription(t, "team", "resources:"+validRemoteEntry+`
+  - id: addon
+    name: Addon
+    type: lora
+    url: https://example.com/addon.safetensors
+    depends_on: [good]
+bundles:
+  - id: set
+    name: Set
+    resources: [good, addon]
+`)
+	snapshot, skipped, err := loadSnapshot(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Fatalf("skipped = %v", skipped)
+	}
+
+	resources := make(map[string]PresetResource)
+	for _, resource := range snapshot.catalog.Resources {
+		resources[resource.ID] = resource
+	}
+	addon, exists := resources["team/addon"]
+	if !exists {
+		t.Fatal("team/addon not in the catalog")
+	}
+	if addon.Source != "subscription:team" || !addon.FromRemoteSource() {
+		t.Fatalf("source = %q", addon.Source)
+	}
+	if strings.Join(addon.DependsOn, " ") != "team/good" {
+		t.Fatalf("depends_on = %v", addon.DependsOn)
+	}
+	if _, exists := resources["good"]; exists {
+		t.Fatal("entry kept its bare ID")
+	}
+
+	for _, bundle := range snapshot.catalog.Bundles {
+		if bundle.ID == "team/set" {
+			if strings.Join(bundle.Resources, " ") != "team/good team/addon" {
+				t.Fatalf("bundle resources = %v", bundle.Resources)
+			}
+			return
+		}
+	}
+	t.Fatal("team/set not in the catalog")
+}
@@ -0,0 +1,346 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SizeUnits lists the units accepted for preset sizes
+var SizeUnits = []string{"KB", "MB", "GB"}
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// CatalogIssue is a problem found in a catalog or destinations file
+type CatalogIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i CatalogIssue) String() string {
+	location := i.File
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d", i.File, i.Line)
+	}
+	if i.ID != "" {
+		return fmt.Sprintf("%s: %s: %s", location, i.ID, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, i.Message)
+}
+
+// ValidationError reports every issue that made a configuration invalid
+type ValidationError struct {
+	Issues []CatalogIssue
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Issues) == 1 {
+		return e.Issues[0].String()
+	}
+	return fmt.Sprintf("%s (and %d more issues)", e.Issues[0].String(), len(e.Issues)-1)
+}
+
+// LintFiles validates catalog and destinations files against the embedded
+// configuration and the configured destinations file. Catalog files are
+// merged in the given order; a file with a top-level `profiles`,
+// `destinations` or `active_profile` key is treated as a destinations file.
+func LintFiles(files ...string) []CatalogIssue {
+	issues := make([]CatalogIssue, 0)
+
+	destinationsMerger := &destinationsMerger{}
+	for _, source := range []string{EmbeddedCatalogSource, destinationsFile} {
+		content := installDestinationsYAML
+		if source != EmbeddedCatalogSource {
+			if source == "" {
+				continue
+			}
+			var err error
+			if content, err = os.ReadFile(source); os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				issues = append(issues, CatalogIssue{File: source, Message: fmt.Sprintf("failed to read: %v", err)})
+				continue
+			}
+		}
+		parsed, parseIssues, err := parseDestinations(source, content)
+		if err != nil {
+			issues = append(issues, CatalogIssue{File: source, Message: err.Error()})
+			continue
+		}
+		issues = append(issues, parseIssues...)
+		destinationsMerger.apply(parsed)
+	}
+
+	merger := newCatalogMerger()
+	if err := merger.merge(EmbeddedCatalogSource, presetResourcesYAML); err != nil {
+		issues = append(issues, CatalogIssue{File: EmbeddedCatalogSource, Message: err.Error()})
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			issues = append(issues, CatalogIssue{File: file, Message: fmt.Sprintf("failed to read: %v", err)})
+			continue
+		}
+
+		if isDestinationsFile(content) {
+			parsed, parseIssues, err := parseDestinations(file, content)
+			if err != nil {
+				issues = append(issues, CatalogIssue{File: file, Message: err.Error()})
+				continue
+			}
+			issues = append(issues, parseIssues...)
+			destinationsMerger.apply(parsed)
+			continue
+		}
+
+		if err := merger.merge(file, content); err != nil {
+			issues = append(issues, CatalogIssue{File: file, Message: err.Error()})
+		}
+	}
+
+	destinations, resolveIssues := destinationsMerger.resolve()
+	issues = append(issues, resolveIssues...)
+	issues = append(issues, merger.issues...)
+	catalog := merger.catalog()
+	issues = append(issues, expandCatalogPaths(catalog)...)
+	issues = append(issues, validateCatalog(catalog, destinations)...)
+	return issues
+}
+
+// isDestinationsFile reports whether content has a top-level key of a destinations file
+func isDestinationsFile(content []byte) bool {
+	var keys map[string]yaml.Node
+	if err := yaml.Unmarshal(content, &keys); err != nil {
+		return false
+	}
+	for _, key := range []string{"profiles", "destinations", "active_profile"} {
+		if _, exists := keys[key]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// lintCatalogNodes checks the entries of a single catalog file. Entries may
+// override only some fields, so only the fields that are set are checked here.
+func lintCatalogNodes(source string, file catalogFile) []CatalogIssue {
+	issues := make([]CatalogIssue, 0)
+
+	seen := make(map[string]int)
+	for i := range file.Resources {
+		node := &file.Resources[i]
+		var resource PresetResource
+		if err := node.Decode(&resource); err != nil {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, Message: err.Error()})
+			continue
+		}
+		if resource.ID == "" {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, Message: "entry without id"})
+			continue
+		}
+		if line, exists := seen[resource.ID]; exists {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, ID: resource.ID, Message: fmt.Sprintf("duplicate id, first defined on line %d", line)})
+		}
+		seen[resource.ID] = node.Line
+
+		for _, message := range checkResourceFields(resource) {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, ID: resource.ID, Message: message})
+		}
+	}
+
+	seen = make(map[string]int)
+	for i := range file.Bundles {
+		node := &file.Bundles[i]
+		var bundle PresetBundle
+		if err := node.Decode(&bundle); err != nil {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, Message: err.Error()})
+			continue
+		}
+		if bundle.ID == "" {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, Message: "bundle without id"})
+			continue
+		}
+		if line, exists := seen[bundle.ID]; exists {
+			issues = append(issues, CatalogIssue{File: source, Line: node.Line, ID: bundle.ID, Message: fmt.Sprintf("duplicate bundle id, first defined on line %d", line)})
+		}
+		seen[bundle.ID] = node.Line
+	}
+	return issues
+}
+
+// LocalOnlyActions are post-install actions that run code or call URLs.
+// Only the embedded catalog and local catalog files may use them.
+var LocalOnlyActions = []string{"command", "python_script", "http"}
+
+// IsLocalOnlyAction reports whether an action is one of LocalOnlyActions
+func IsLocalOnlyAction(action string) bool {
+	return contains(LocalOnlyActions, action)
+}
+
+// lintLocalOnlySteps reports post-install steps that a catalog from outside
+// the machine (a subscription or a preset saved through the API) may not use
+func lintLocalOnlySteps(source string, file catalogFile) []CatalogIssue {
+	issues := make([]CatalogIssue, 0)
+	for i := range file.Resources {
+		node := &file.Resources[i]
+		var resource PresetResource
+		if err := node.Decode(&resource); err != nil {
+			continue
+		}
+		for _, step := range resource.PostInstall {
+			if contains(LocalOnlyActions, step.Action) {
+				message := fmt.Sprintf("post_install action %q is only allowed in local catalog files", step.Action)
+				issues = append(issues, CatalogIssue{File: source, Line: node.Line, ID: resource.ID, Message: message})
+			}
+		}
+	}
+	return issues
+}
+
+// checkResourceFields checks the format of the fields a catalog entry sets
+func checkResourceFields(resource PresetResource) []string {
+	messages := make([]string, 0)
+	// Destination names such as loras are accepted like the install API does
+	if _, known := LookupResourceType(resource.Type); resource.Type != "" && !known {
+		messages = append(messages, fmt.Sprintf("unknown type %q (expected one of %s)", resource.Type, strings.Join(ResourceTypes, ", ")))
+	}
+	if (resource.Size.Value != 0 || resource.Size.Unit != "") && !contains(SizeUnits, resource.Size.Unit) {
+		messages = append(messages, fmt.Sprintf("unknown size unit %q (expected one of %s)", resource.Size.Unit, strings.Join(SizeUnits, ", ")))
+	}
+	if resource.Size.Value < 0 {
+		messages = append(messages, "size must not be negative")
+	}
+	if resource.SHA256 != "" && !sha256Regex.MatchString(resource.SHA256) {
+		messages = append(messages, fmt.Sprintf("malformed sha256 %q (expected 64 hex characters)", resource.SHA256))
+	}
+	if resource.URL != "" {
+		if parsed, err := url.Parse(resource.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			messages = append(messages, fmt.Sprintf("malformed url %q", resource.URL))
+		}
+	}
+	if resource.DestinationPath != "" && !filepath.IsAbs(resource.DestinationPath) && !hasPathVariables(resource.DestinationPath) {
+		messages = append(messages, fmt.Sprintf("destination_path %q is not absolute", resource.DestinationPath))
+	}
+	return messages
+}
+
+// validateCatalog checks the merged catalog: required fields, references
+// between entries and destination paths
+func validateCatalog(catalog *Catalog, destinations *Destinations) []CatalogIssue {
+	issues := make([]CatalogIssue, 0)
+
+	destinationPaths := make(map[string]bool)
+	destinationTypes := make(map[string]bool)
+	for _, profile := range destinations.Profiles {
+		for _, destination := range profile.Destinations {
+			destinationPaths[filepath.Clean(destination.Path)] = true
+			destinationTypes[destination.Type] = true
+		}
+	}
+	presetIDs := make(map[string]bool, len(catalog.Resources))
+	for _, resource := range catalog.Resources {
+		presetIDs[resource.ID] = true
+	}
+
+	for _, resource := range catalog.Resources {
+		add := func(format string, args ...interface{}) {
+			issues = append(issues, CatalogIssue{File: entrySource(resource.Source, resource.Override), ID: resource.ID, Message: fmt.Sprintf(format, args...)})
+		}
+		if resource.Name == "" {
+			add("name is required")
+		}
+		if resource.URL == "" {
+			add("url is required")
+		}
+		if resource.Type == "" {
+			add("type is required")
+		}
+		destinationType := resource.DestinationType()
+		switch {
+		case destinationType != "":
+			if !destinationTypes[destinationType] {
+				add("destination %q is not defined by any profile", destinationType)
+			}
+		case resource.DestinationPath == "":
+			add("destination or destination_path is required")
+		case !destinationPaths[filepath.Clean(resource.DestinationPath)]:
+			add("destination_path %s does not match any install destination", resource.DestinationPathTemplate())
+		}
+		if resourceType, known := LookupResourceType(resource.Type); known && !resource.AllowTypeMismatch {
+			if destinationType != "" && destinationType != resourceType.Destination {
+				add("%s resources install to %s, not %s (set allow_type_mismatch to allow it)", resourceType.Name, resourceType.Destination, destinationType)
+			} else if destinationType == "" {
+				if err := destinations.CheckDestination(resourceType, resource.DestinationPath); err != nil {
+					add("%v (set allow_type_mismatch to allow it)", err)
+				}
+			}
+		}
+		for _, dependency := range resource.DependsOn {
+			if !presetIDs[dependency] {
+				add("depends on unknown preset %q", dependency)
+			}
+		}
+	}
+
+	for _, bundle := range catalog.Bundles {
+		source := entrySource(bundle.Source, bundle.Override)
+		if len(bundle.Resources) == 0 {
+			issues = append(issues, CatalogIssue{File: source, ID: bundle.ID, Message: "bundle has no resources"})
+		}
+		for _, presetID := range bundle.Resources {
+			if !presetIDs[presetID] {
+				issues = append(issues, CatalogIssue{File: source, ID: bundle.ID, Message: fmt.Sprintf("bundle contains unknown preset %q", presetID)})
+			}
+		}
+	}
+	return issues
+}
+
+// expandCatalogPaths expands the variables in destination_path of every preset.
+// Issues quote the path as written, never its expanded value.
+func expandCatalogPaths(catalog *Catalog) []CatalogIssue {
+	issues := make([]CatalogIssue, 0)
+	for i := range catalog.Resources {
+		resource := &catalog.Resources[i]
+		if resource.DestinationPath == "" {
+			continue
+		}
+		path, err := ExpandPath(resource.DestinationPath)
+		if err != nil {
+			issues = append(issues, CatalogIssue{File: entrySource(resource.Source, resource.Override), ID: resource.ID, Message: fmt.Sprintf("destination_path: %v", err)})
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			issues = append(issues, CatalogIssue{File: entrySource(resource.Source, resource.Override), ID: resource.ID, Message: fmt.Sprintf("destination_path %q does not expand to an absolute path", resource.DestinationPath)})
+			continue
+		}
+		resource.destinationTemplate = resource.DestinationPath
+		resource.DestinationPath = path
+	}
+	return issues
+}
+
+// entrySource returns the catalog that last changed an entry
+func entrySource(source, override string) string {
+	if override != "" {
+		return override
+	}
+	return source
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLintLocalOnlySteps(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		wantIssues int
+	}{
+		{name: "command", action: "command", wantIssues: 1},
+		{name: "python script", action: "python_script", wantIssues: 1},
+		{name: "http", action: "http", wantIssues: 1},
+		{name: "extract", action: "extract"},
+		{name: "pip requirements", action: "pip_requirements"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file catalogFile
+			content := "resources:\n  - id: remote-model\n    post_install:\n      - name: step\n        action: " + tt.action + "\n"
+			if err := yaml.Unmarshal([]byte(content), &file); err != nil {
+				t.Fatal(err)
+			}
+
+			issues := lintLocalOnlySteps("subscription:team", file)
+			if len(issues) != tt.wantIssues {
+				t.Fatalf("issues = %v, want %d", issues, tt.wantIssues)
+			}
+			for _, issue := range issues {
+				if issue.ID != "remote-model" || issue.Line != 2 {
+					t.Fatalf("issue = %+v", issue)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalOnlyStepsInCatalogFiles(t *testing.T) {
+	content := []byte(`
+resources:
+  - id: extension
+    post_install:
+      - name: install
+        action: command
+        command: "true"
+`)
+
+	tests := []struct {
+		name      string
+		merge     func(m *catalogMerger) error
+		wantIssue bool
+	}{
+		{
+			name:  "local catalog file",
+			merge: func(m *catalogMerger) error { return m.merge("/storage/station/catalogs/local.yaml", content) },
+		},
+		{
+			name:      "subscription",
+			merge:     func(m *catalogMerger) error { return m.mergeNamespaced("team", "subscription:team", content) },
+			wantIssue: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merger := newCatalogMerger()
+			if err := tt.merge(merger); err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, issue := range merger.issues {
+				if strings.Contains(issue.Message, "only allowed in local catalog files") {
+					found = true
+				}
+			}
+			if found != tt.wantIssue {
+				t.Fatalf("issues = %v, want a local-only issue: %v", merger.issues, tt.wantIssue)
+			}
+		})
+	}
+}
+
+func TestLocalOnlyStepsAreRejectedInCustomPresets(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		wantErr bool
+	}{
+		{name: "command", action: "command", wantErr: true},
+		{name: "python script", action: "python_script", wantErr: true},
+		{name: "http", action: "http", wantErr: true},
+		{name: "extract", action: "extract"},
+		{name: "pip requirements", action: "pip_requirements"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := `{"id":"custom-model","post_install":[{"name":"step","action":"` + tt.action + `","command":"true","url":"http://example.com"}]}`
+			_, issues, err := loadCatalog([][]byte{[]byte(entry)}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rejected := false
+			for _, issue := range issues {
+				if issue.File == CustomCatalogSource && strings.Contains(issue.Message, "only allowed in local catalog files") {
+					rejected = true
+				}
+			}
+			if rejected != tt.wantErr {
+				t.Fatalf("rejected = %v, want %v (issues: %v)", rejected, tt.wantErr, issues)
+			}
+		})
+	}
+}
+
+func TestCheckResourceFields(t *testing.T) {
+	valid := PresetResource{
+		ID:     "model",
+		Type:   "lora",
+		URL:    "https://example.com/model.safetensors",
+		Size:   SizeInfo{Value: 1, Unit: "GB"},
+		SHA256: strings.Repeat("a", 64),
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*PresetResource)
+		want   string // substring of the only expected message; empty for none
+	}{
+		{name: "valid", modify: func(*PresetResource) {}},
+		{name: "destination name as type", modify: func(p *PresetResource) { p.Type = "loras" }},
+		{name: "unknown type", modify: func(p *PresetResource) { p.Type = "lorax" }, want: "unknown type"},
+		{name: "unknown size unit", modify: func(p *PresetResource) { p.Size.Unit = "TB" }, want: "unknown size unit"},
+		{name: "negative size", modify: func(p *PresetResource) { p.Size.Value = -1 }, want: "must not be negative"},
+		{name: "short sha256", modify: func(p *PresetResource) { p.SHA256 = "abc" }, want: "malformed sha256"},
+		{name: "ftp url", modify: func(p *PresetResource) { p.URL = "ftp://example.com/model" }, want: "malformed url"},
+		{name: "relative destination path", modify: func(p *PresetResource) { p.DestinationPath = "models/loras" }, want: "not absolute"},
+		{name: "destination path with variable", modify: func(p *PresetResource) { p.DestinationPath = "${COMFYUI_ROOT}/models/loras" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := valid
+			tt.modify(&resource)
+			messages := checkResourceFields(resource)
+			if tt.want == "" {
+				if len(messages) > 0 {
+					t.Fatalf("checkResourceFields() = %v, want none", messages)
+				}
+				return
+			}
+			if len(messages) != 1 || !strings.Contains(messages[0], tt.want) {
+				t.Fatalf("checkResourceFields() = %v, want one message containing %q", messages, tt.want)
+			}
+		})
+	}
+}
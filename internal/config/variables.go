@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pathVariableRegex matches ${NAME} references in paths
+var pathVariableRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Where the value of a path variable comes from
+const (
+	VariableSourceEnv     = "env"
+	VariableSourceConfig  = "file"
+	VariableSourceDefault = "default"
+)
+
+// PathVariable is a variable usable as ${NAME} in destination and preset paths
+type PathVariable struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // env, file or default
+}
+
+var (
+	pathVariablesMutex sync.RWMutex
+	configVariables    map[string]string
+
+	detectOnce        sync.Once
+	detectedVariables map[string]string
+)
+
+// SetPathVariables sets path variables from the config file; environment
+// variables of the same name take precedence
+func SetPathVariables(variables map[string]string) {
+	pathVariablesMutex.Lock()
+	defer pathVariablesMutex.Unlock()
+
+	configVariables = variables
+}
+
+// defaultPathVariables detects the storage and UI directories of the machine.
+// On Paperspace persistent storage is mounted at /storage and the UIs live in /opt/app.
+func defaultPathVariables() map[string]string {
+	detectOnce.Do(func() {
+		home, err := os.UserHomeDir()
+		if err != nil || home == "" {
+			home = "/root"
+		}
+		storage := firstExistingDir("/storage", home)
+
+		detectedVariables = map[string]string{
+			"HOME":          home,
+			"STORAGE":       storage,
+			"COMFYUI_ROOT":  firstExistingDir("/opt/app/ComfyUI", "/notebooks/ComfyUI", filepath.Join(storage, "ComfyUI")),
+			"A1111_ROOT":    firstExistingDir("/opt/app/stable-diffusion-webui", "/notebooks/stable-diffusion-webui", filepath.Join(storage, "stable-diffusion-webui")),
+			"FORGE_ROOT":    firstExistingDir("/opt/app/stable-diffusion-webui-forge", "/notebooks/stable-diffusion-webui-forge", filepath.Join(storage, "stable-diffusion-webui-forge")),
+			"INVOKEAI_ROOT": firstExistingDir("/opt/app/invokeai", "/notebooks/invokeai", filepath.Join(home, "invokeai")),
+		}
+	})
+	return detectedVariables
+}
+
+// firstExistingDir returns the first existing directory, or the first candidate if none exists
+func firstExistingDir(candidates ...string) string {
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
+// lookupPathVariable returns the value of a variable from env, the config file
+// or the detected defaults, in that order. Only the built-in variables and
+// those declared under variables: in the config file exist; other environment
+// variables are never expanded, so that paths cannot read arbitrary secrets.
+func lookupPathVariable(name string) (PathVariable, bool) {
+	pathVariablesMutex.RLock()
+	value, declared := configVariables[name]
+	pathVariablesMutex.RUnlock()
+	detected, builtIn := defaultPathVariables()[name]
+	if !declared && !builtIn {
+		return PathVariable{}, false
+	}
+
+	if value := os.Getenv(name); value != "" {
+		return PathVariable{Name: name, Value: value, Source: VariableSourceEnv}, true
+	}
+	if value != "" {
+		return PathVariable{Name: name, Value: value, Source: VariableSourceConfig}, true
+	}
+	if builtIn {
+		return PathVariable{Name: name, Value: detected, Source: VariableSourceDefault}, true
+	}
+	return PathVariable{}, false
+}
+
+// GetPathVariables returns the built-in and configured path variables with their values
+func GetPathVariables() []PathVariable {
+	names := make(map[string]bool)
+	for name := range defaultPathVariables() {
+		names[name] = true
+	}
+	pathVariablesMutex.RLock()
+	for name := range configVariables {
+		names[name] = true
+	}
+	pathVariablesMutex.RUnlock()
+
+	variables := make([]PathVariable, 0, len(names))
+	for name := range names {
+		if variable, exists := lookupPathVariable(name); exists {
+			variables = append(variables, variable)
+		}
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables
+}
+
+// ExpandPath replaces ${NAME} references in a path. Undefined variables are errors.
+func ExpandPath(path string) (string, error) {
+	var missing []string
+	expanded := pathVariableRegex.ReplaceAllStringFunc(path, func(reference string) string {
+		name := pathVariableRegex.FindStringSubmatch(reference)[1]
+		variable, exists := lookupPathVariable(name)
+		if !exists {
+			missing = append(missing, name)
+			return reference
+		}
+		return variable.Value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable ${%s} in %q", strings.Join(missing, "}, ${"), path)
+	}
+	return expanded, nil
+}
+
+// hasPathVariables reports whether a path still has to be expanded
+func hasPathVariables(path string) bool {
+	return pathVariableRegex.MatchString(path)
+}
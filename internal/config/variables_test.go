@@ -0,0 +1,75 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("STORAGE", "/mnt/storage")
+	t.Setenv("MODELS", "/env/models")
+	t.Setenv("SECRET_TOKEN", "hunter2")
+	SetPathVariables(map[string]string{"MODELS": "/file/models", "LORAS": "/file/loras", "EMPTY": ""})
+	t.Cleanup(func() { SetPathVariables(nil) })
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "no variables", path: "/opt/app/ComfyUI", want: "/opt/app/ComfyUI"},
+		{name: "built-in from env", path: "${STORAGE}/models", want: "/mnt/storage/models"},
+		{name: "declared overridden by env", path: "${MODELS}/checkpoints", want: "/env/models/checkpoints"},
+		{name: "declared in config file", path: "${LORAS}", want: "/file/loras"},
+		{name: "several variables", path: "${STORAGE}${LORAS}", want: "/mnt/storage/file/loras"},
+		{name: "undeclared env var", path: "/tmp/${SECRET_TOKEN}", wantErr: "undefined variable ${SECRET_TOKEN}"},
+		{name: "declared without value", path: "${EMPTY}/x", wantErr: "undefined variable ${EMPTY}"},
+		{name: "unknown", path: "${NOPE}/x", wantErr: "undefined variable ${NOPE}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandPath(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExpandPath(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "hunter2") {
+					t.Fatalf("error leaks the value of an environment variable: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandPath(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogIssuesQuoteUnexpandedPaths(t *testing.T) {
+	t.Setenv("STORAGE", "/mnt/private-storage")
+
+	entry := `{"id":"custom-model","name":"Model","type":"checkpoint","url":"https://example.com/model.safetensors","destination_path":"${STORAGE}/elsewhere"}`
+	catalog, issues, err := loadCatalog([][]byte{[]byte(entry)}, nil)
+	if err != nil || len(issues) > 0 {
+		t.Fatal(err, issues)
+	}
+	destinations, err := LoadDestinations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues = validateCatalog(catalog, destinations)
+	if len(issues) == 0 {
+		t.Fatal("expected an issue for an unknown destination_path")
+	}
+	for _, issue := range issues {
+		if strings.Contains(issue.Message, "/mnt/private-storage") {
+			t.Errorf("issue leaks the expanded path: %s", issue)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Credentials are the API tokens sent to the hosts that need them
+type Credentials struct {
+	HuggingFace string
+	Civitai     string
+}
+
+var (
+	credentialsMutex sync.RWMutex
+	credentials      Credentials
+)
+
+// SetCredentials sets the API tokens used for downloads and metadata requests
+func SetCredentials(c Credentials) {
+	credentialsMutex.Lock()
+	defer credentialsMutex.Unlock()
+
+	credentials = c
+}
+
+// authorizationHeader returns the Authorization header for a URL, or "" if
+// no token is configured for its host
+func authorizationHeader(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+
+	credentialsMutex.RLock()
+	defer credentialsMutex.RUnlock()
+
+	token := ""
+	switch {
+	case matchesHost(host, "huggingface.co"):
+		token = credentials.HuggingFace
+	case matchesHost(host, "civitai.com"):
+		token = credentials.Civitai
+	}
+	if token == "" {
+		return ""
+	}
+	return "Bearer " + token
+}
+
+// matchesHost reports whether host is domain or one of its subdomains
+func matchesHost(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// authorize adds the Authorization header for the request URL
+func authorize(req *http.Request) {
+	if header := authorizationHeader(req.URL.String()); header != "" {
+		req.Header.Set("Authorization", header)
+	}
+}
+
+// reauthorize replaces the Authorization header of a redirected request with
+// the one of its own host, so a token never follows a redirect to another site
+func reauthorize(req *http.Request) {
+	req.Header.Del("Authorization")
+	authorize(req)
+}
+
+// authorizedRedirect is the CheckRedirect of clients that send tokens
+func authorizedRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	reauthorize(req)
+	return nil
+}
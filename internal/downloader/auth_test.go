@@ -0,0 +1,100 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBackendForURL(t *testing.T) {
+	SetCredentials(Credentials{HuggingFace: "hf-token"})
+	t.Cleanup(func() { SetCredentials(Credentials{}) })
+
+	tests := []struct {
+		name    string
+		backend string
+		url     string
+		want    string
+	}{
+		{name: "git repository", backend: BackendWget, url: "https://github.com/example/extension.git", want: BackendGit},
+		{name: "host with token", backend: BackendWget, url: "https://huggingface.co/org/model/resolve/main/model.safetensors", want: BackendHTTP},
+		{name: "subdomain with token", backend: BackendWget, url: "https://cdn-lfs.huggingface.co/model.safetensors", want: BackendHTTP},
+		{name: "host without token", backend: BackendWget, url: "https://civitai.com/api/download/models/1", want: BackendWget},
+		{name: "other host", backend: BackendWget, url: "https://example.com/model.safetensors", want: BackendWget},
+		{name: "lookalike host", backend: BackendWget, url: "https://nothuggingface.co/model.safetensors", want: BackendWget},
+		{name: "selected http backend", backend: BackendHTTP, url: "https://example.com/model.safetensors", want: BackendHTTP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetBackend(tt.backend); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { SetBackend(BackendWget) })
+
+			if got := BackendForURL(tt.url); got != tt.want {
+				t.Fatalf("BackendForURL(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectsCarryOnlyTheTokenOfTheirHost(t *testing.T) {
+	SetCredentials(Credentials{HuggingFace: "hf-token", Civitai: "civitai-token"})
+	t.Cleanup(func() { SetCredentials(Credentials{}) })
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "same site", url: "https://cdn-lfs.huggingface.co/model.safetensors", want: "Bearer hf-token"},
+		{name: "other site", url: "https://storage.example.com/model.safetensors", want: ""},
+		{name: "site with another token", url: "https://civitai.com/api/download/models/1", want: "Bearer civitai-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Headers of the original request are copied to the redirect
+			req.Header.Set("Authorization", "Bearer hf-token")
+
+			if err := authorizedRedirect(req, []*http.Request{{}}); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Fatalf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectToAnotherHostDropsTheToken(t *testing.T) {
+	received := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Authorization")
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/model.safetensors", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	req, err := http.NewRequest(http.MethodHead, origin.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer hf-token")
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := <-received; got != "" {
+		t.Fatalf("redirect target received Authorization %q", got)
+	}
+}
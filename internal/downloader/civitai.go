@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// civitaiBaseURL is the Civitai site used for API requests
+const civitaiBaseURL = "https://civitai.com"
+
+// civitaiDownloadRegex matches model version download URLs such as
+// https://civitai.com/api/download/models/12345
+var civitaiDownloadRegex = regexp.MustCompile(`^https?://(?:www\.)?civitai\.com/api/download/models/(\d+)`)
+
+var civitaiClient = &http.Client{Timeout: 30 * time.Second, CheckRedirect: authorizedRedirect}
+
+// CivitaiVersionID returns the model version ID of a Civitai download URL
+func CivitaiVersionID(url string) (string, bool) {
+	matches := civitaiDownloadRegex.FindStringSubmatch(url)
+	if matches == nil {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// CivitaiDownloadURL returns the download URL of a model version
+func CivitaiDownloadURL(versionID string) string {
+	return fmt.Sprintf("%s/api/download/models/%s", civitaiBaseURL, versionID)
+}
+
+// CivitaiLatestVersion returns the newest version ID of the model a version belongs to
+func CivitaiLatestVersion(versionID string) (string, error) {
+	var version struct {
+		ModelID int64 `json:"modelId"`
+	}
+	if err := getCivitaiJSON(fmt.Sprintf("%s/api/v1/model-versions/%s", civitaiBaseURL, versionID), &version); err != nil {
+		return "", err
+	}
+
+	var model struct {
+		ModelVersions []struct {
+			ID int64 `json:"id"`
+		} `json:"modelVersions"`
+	}
+	if err := getCivitaiJSON(fmt.Sprintf("%s/api/v1/models/%d", civitaiBaseURL, version.ModelID), &model); err != nil {
+		return "", err
+	}
+
+	// Version IDs increase over time; the highest one is the newest
+	var latest int64
+	for _, modelVersion := range model.ModelVersions {
+		if modelVersion.ID > latest {
+			latest = modelVersion.ID
+		}
+	}
+	if latest == 0 {
+		return "", fmt.Errorf("model %d has no versions", version.ModelID)
+	}
+	return strconv.FormatInt(latest, 10), nil
+}
+
+func getCivitaiJSON(url string, target interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("civitai request failed: %v", err)
+	}
+	authorize(req)
+	resp, err := civitaiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("civitai request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("civitai request failed with status: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to parse civitai response: %v", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,21 +10,33 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // DownloadTask represents a download task with progress tracking
 type DownloadTask struct {
+	// Context stops the download when cancelled (optional)
+	Context  context.Context
 	URL      string
 	FilePath string
+	// Resume continues a partially downloaded file instead of starting over
+	Resume   bool
 	Progress float64
 	Error    error
 	// Progress callback function
-	ProgressCallback func(progress float64)
+	ProgressCallback func(info ProgressInfo)
+	// Log callback receiving backend output lines (status, redirects, retries)
+	LogCallback func(line string)
 	// Additional progress information
 	DownloadedBytes int64
 	TotalBytes      int64
 	DownloadSpeed   string
 	ETA             string
+	// Remote holds the revision headers of the download responses once it completed
+	Remote RemoteInfo
+
+	// responseHeaders are the headers of each response, redirects included
+	responseHeaders []http.Header
 }
 
 // Downloader interface for different download methods
@@ -37,10 +50,39 @@ type WgetDownloader struct{}
 // HTTPDownloader implements download using Go's HTTP client
 type HTTPDownloader struct{}
 
-// NewDownloader creates a new downloader instance
-// Always use wget as per requirements
-func NewDownloader() Downloader {
-	return &WgetDownloader{}
+// Download backends for files other than git repositories
+const (
+	BackendWget = "wget"
+	BackendHTTP = "http"
+)
+
+// Backends lists the supported download backends
+var Backends = []string{BackendWget, BackendHTTP}
+
+var (
+	backendMutex sync.RWMutex
+	backend      = BackendWget
+)
+
+// SetBackend selects the download backend: wget (default) or the built-in HTTP client
+func SetBackend(name string) error {
+	if name != BackendWget && name != BackendHTTP {
+		return fmt.Errorf("unknown download backend %q (expected one of %s)", name, strings.Join(Backends, ", "))
+	}
+
+	backendMutex.Lock()
+	defer backendMutex.Unlock()
+
+	backend = name
+	return nil
+}
+
+// CurrentBackend returns the selected download backend
+func CurrentBackend() string {
+	backendMutex.RLock()
+	defer backendMutex.RUnlock()
+
+	return backend
 }
 
 // Download downloads a file using wget command
@@ -50,12 +92,22 @@ func (w *WgetDownloader) Download(task *DownloadTask) error {
 		return fmt.Errorf("wget command is not available on this system")
 	}
 
+	ctx := task.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	// Use wget command
-	cmd := exec.Command("wget",
+	args := []string{
 		"--progress=bar:force",
 		"--show-progress",
+		"--server-response",
 		"-O", task.FilePath,
-		task.URL)
+	}
+	if task.Resume {
+		args = append(args, "--continue")
+	}
+	cmd := exec.CommandContext(ctx, "wget", append(args, task.URL)...)
 
 	// Create a pipe to capture wget output for progress tracking
 	stderr, err := cmd.StderrPipe()
@@ -68,11 +120,19 @@ func (w *WgetDownloader) Download(task *DownloadTask) error {
 		return fmt.Errorf("failed to start wget: %v", err)
 	}
 
-	// Monitor progress
-	go monitorWgetProgress(task, stderr)
+	// Monitor progress until wget closes its output
+	monitorWgetProgress(task, stderr)
 
 	// Wait for command to complete
 	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("download stopped: %v", ctx.Err())
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if reason := wgetExitReason(exitErr.ExitCode()); reason != "" {
+				return fmt.Errorf("wget command failed: %v (%s)", err, reason)
+			}
+		}
 		return fmt.Errorf("wget command failed: %v", err)
 	}
 
@@ -81,25 +141,67 @@ func (w *WgetDownloader) Download(task *DownloadTask) error {
 		return fmt.Errorf("downloaded file not found: %s", task.FilePath)
 	}
 
+	task.Remote = remoteInfoFromResponses(task.responseHeaders...)
 	return nil
 }
 
 // Download downloads a file using Go's HTTP client
 func (h *HTTPDownloader) Download(task *DownloadTask) error {
+	ctx := task.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	// Create HTTP request
-	req, err := http.NewRequest("GET", task.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", task.URL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
+	authorize(req)
+
+	// Continue a partial download from its current size
+	offset := int64(0)
+	if task.Resume {
+		if info, err := os.Stat(task.FilePath); err == nil && info.Size() > 0 {
+			offset = info.Size()
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	}
+
+	// Keep the headers of redirects, which carry the revision on some hosts
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if err := authorizedRedirect(req, via); err != nil {
+				return err
+			}
+			task.responseHeaders = append(task.responseHeaders, req.Response.Header)
+			return nil
+		},
+	}
 
 	// Make HTTP request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("download stopped: %v", ctx.Err())
+		}
 		return fmt.Errorf("failed to download: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if task.LogCallback != nil {
+		task.LogCallback(fmt.Sprintf("%s %s", resp.Proto, resp.Status))
+	}
+	task.responseHeaders = append(task.responseHeaders, resp.Header)
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range; start over
+		offset = 0
+	default:
 		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
 	}
 
@@ -107,17 +209,19 @@ func (h *HTTPDownloader) Download(task *DownloadTask) error {
 	contentLength := resp.ContentLength
 	if contentLength <= 0 {
 		contentLength = 0
+	} else {
+		contentLength += offset
 	}
 
 	// Create output file
-	file, err := os.Create(task.FilePath)
+	file, err := os.OpenFile(task.FilePath, flags, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %v", err)
 	}
 	defer file.Close()
 
 	// Download with progress tracking
-	progress := int64(0)
+	progress := offset
 	buffer := make([]byte, 32*1024) // 32KB buffer
 
 	for {
@@ -133,16 +237,27 @@ func (h *HTTPDownloader) Download(task *DownloadTask) error {
 				progressPercent := float64(progress) / float64(contentLength) * 100
 				task.Progress = progressPercent
 			}
+			if task.ProgressCallback != nil {
+				task.ProgressCallback(ProgressInfo{
+					Percentage:      task.Progress,
+					DownloadedBytes: progress,
+					TotalBytes:      contentLength,
+				})
+			}
 		}
 
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("download stopped: %v", ctx.Err())
+			}
 			return fmt.Errorf("failed to read response: %v", err)
 		}
 	}
 
+	task.Remote = remoteInfoFromResponses(task.responseHeaders...)
 	return nil
 }
 
@@ -150,37 +265,136 @@ func (h *HTTPDownloader) Download(task *DownloadTask) error {
 func monitorWgetProgress(task *DownloadTask, stderr io.ReadCloser) {
 	// Read stderr for progress information
 	buffer := make([]byte, 1024)
-	lastProgress := float64(-1)
+	partialLine := ""
 
 	for {
 		n, err := stderr.Read(buffer)
 		if n > 0 {
-			output := string(buffer[:n])
-
-			// Parse wget progress output
-			progressInfo := parseWgetProgress(output)
-			if progressInfo.Percentage >= 0 && progressInfo.Percentage != lastProgress {
-				// Only update if progress has changed
-				task.Progress = progressInfo.Percentage
-				task.DownloadedBytes = progressInfo.DownloadedBytes
-				task.TotalBytes = progressInfo.TotalBytes
-				task.DownloadSpeed = progressInfo.DownloadSpeed
-				task.ETA = progressInfo.ETA
-				lastProgress = progressInfo.Percentage
-
-				// Call progress callback if available
-				if task.ProgressCallback != nil {
-					task.ProgressCallback(progressInfo.Percentage)
-				}
+			var lines []string
+			lines, partialLine = splitOutputLines(partialLine + string(buffer[:n]))
+			for _, line := range lines {
+				handleWgetLine(task, line)
 			}
 		}
 		if err != nil {
 			// End of stream or error - this is normal when wget completes
+			handleWgetLine(task, partialLine)
 			break
 		}
 	}
 }
 
+// wgetProgressBarRegex matches progress bar updates such as "45%[===>  ]" or "[ <=>  ]"
+var wgetProgressBarRegex = regexp.MustCompile(`\[[ =<>]*\]`)
+
+// wgetStatusRegex and wgetHeaderRegex match the response status and header
+// lines printed, indented, by --server-response
+var (
+	wgetStatusRegex = regexp.MustCompile(`^\s+(HTTP/\S+ \d{3}.*)$`)
+	wgetHeaderRegex = regexp.MustCompile(`^\s+([A-Za-z0-9-]+):\s*(.*)$`)
+)
+
+// wgetLengthRegex matches the "Length: 2000000 (1.9M)" line printed before the download
+var wgetLengthRegex = regexp.MustCompile(`^Length:\s+(\d+)`)
+
+// splitOutputLines splits command output on carriage returns and newlines and returns
+// the complete lines and the trailing incomplete line
+func splitOutputLines(output string) ([]string, string) {
+	lines := strings.FieldsFunc(output, func(r rune) bool { return r == '\r' || r == '\n' })
+	if len(lines) == 0 {
+		return nil, ""
+	}
+
+	if !strings.HasSuffix(output, "\n") && !strings.HasSuffix(output, "\r") {
+		return lines[:len(lines)-1], lines[len(lines)-1]
+	}
+	return lines, ""
+}
+
+// urlQueryRegex matches the query and fragment of URLs in wget output
+var urlQueryRegex = regexp.MustCompile(`(https?://[^\s?#]+)[?#]\S*`)
+
+// redactURLs drops the query of every URL in a line: redirects to CDNs carry
+// signed tokens there, e.g. "Location: https://cdn/...?X-Amz-Signature=..."
+func redactURLs(line string) string {
+	return urlQueryRegex.ReplaceAllString(line, "$1?[redacted]")
+}
+
+// handleWgetLine updates progress from a progress bar line, collects response
+// headers and forwards every other line to the log callback. Headers are not
+// logged since they may carry cookies, and URLs are logged without their
+// query since it may carry signed tokens.
+func handleWgetLine(task *DownloadTask, line string) {
+	if matches := wgetStatusRegex.FindStringSubmatch(line); matches != nil {
+		task.responseHeaders = append(task.responseHeaders, http.Header{})
+		line = matches[1]
+	} else if matches := wgetHeaderRegex.FindStringSubmatch(line); matches != nil && len(task.responseHeaders) > 0 {
+		task.responseHeaders[len(task.responseHeaders)-1].Add(matches[1], matches[2])
+		return
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	if !wgetProgressBarRegex.MatchString(line) {
+		if matches := wgetLengthRegex.FindStringSubmatch(line); matches != nil {
+			task.TotalBytes, _ = strconv.ParseInt(matches[1], 10, 64)
+		}
+		if task.LogCallback != nil {
+			task.LogCallback(redactURLs(line))
+		}
+		return
+	}
+
+	progressInfo := parseWgetProgress(line)
+	if progressInfo.Percentage < 0 {
+		return
+	}
+	// Human readable sizes are rounded; never report more than the total
+	if task.TotalBytes > 0 && (progressInfo.DownloadedBytes > task.TotalBytes || progressInfo.Percentage == 100) {
+		progressInfo.DownloadedBytes = task.TotalBytes
+	}
+	// Only update if progress has changed
+	if progressInfo.Percentage == task.Progress && progressInfo.DownloadedBytes == task.DownloadedBytes {
+		return
+	}
+
+	task.Progress = progressInfo.Percentage
+	task.DownloadedBytes = progressInfo.DownloadedBytes
+	task.DownloadSpeed = progressInfo.DownloadSpeed
+	task.ETA = progressInfo.ETA
+
+	// Call progress callback if available
+	if task.ProgressCallback != nil {
+		progressInfo.TotalBytes = task.TotalBytes
+		task.ProgressCallback(progressInfo)
+	}
+}
+
+// wgetExitReason describes wget exit codes as documented in wget(1)
+func wgetExitReason(code int) string {
+	switch code {
+	case 1:
+		return "generic error"
+	case 2:
+		return "parse error"
+	case 3:
+		return "file I/O error"
+	case 4:
+		return "network failure"
+	case 5:
+		return "SSL verification failure"
+	case 6:
+		return "authentication failure"
+	case 7:
+		return "protocol error"
+	case 8:
+		return "server issued an error response"
+	}
+	return ""
+}
+
 // ProgressInfo contains detailed progress information
 type ProgressInfo struct {
 	Percentage      float64
@@ -190,49 +404,53 @@ type ProgressInfo struct {
 	ETA             string
 }
 
-// parseWgetProgress extracts detailed progress information from wget output
-func parseWgetProgress(output string) ProgressInfo {
+// wgetProgressRegex matches wget progress bar lines:
+// percentage, downloaded size, speed and the optional eta/elapsed time
+var wgetProgressRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)%\[[^\]]*\]\s+([0-9.,]+[KMGT]?)\s+(--\.-[KMGT]?B/s|[0-9.,]+[KMGT]?B/s)(?:\s+(?:eta|in)\s+([0-9hms. ]+))?`)
+
+// parseWgetProgress extracts detailed progress information from a wget progress line
+func parseWgetProgress(line string) ProgressInfo {
 	// wget progress format examples:
-	// " 45%[======>                    ] 1,234,567  1.23MB/s  eta 0m 30s"
-	// " 100%[========================>] 2,345,678  2.34MB/s  in 1m 30s"
-	// " 67%[============>              ] 1,234,567  2.34MB/s  eta 0m 15s"
-
-	// Enhanced regex pattern to capture more information
-	// Matches: percentage, downloaded bytes, total bytes, speed, eta/time
-	progressRegex := regexp.MustCompile(`\s*(\d+(?:\.\d+)?)%\[.*?\]\s+([0-9,]+)\s+([0-9,]+)\s+([0-9.]+[KMGT]?B/s)\s+(?:eta|in)\s+(\d+m\s+\d+s|\d+s)`)
-
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		// Look for percentage pattern with progress bar
-		if strings.Contains(line, "%") && strings.Contains(line, "[") {
-			matches := progressRegex.FindStringSubmatch(line)
-			if len(matches) >= 6 {
-				// Parse percentage
-				if percent, err := strconv.ParseFloat(matches[1], 64); err == nil && percent >= 0 && percent <= 100 {
-					// Parse downloaded bytes
-					downloadedStr := strings.ReplaceAll(matches[2], ",", "")
-					downloadedBytes, _ := strconv.ParseInt(downloadedStr, 10, 64)
-
-					// Parse total bytes
-					totalStr := strings.ReplaceAll(matches[3], ",", "")
-					totalBytes, _ := strconv.ParseInt(totalStr, 10, 64)
-
-					// Extract speed and ETA
-					speed := matches[4]
-					eta := matches[5]
-
-					return ProgressInfo{
-						Percentage:      percent,
-						DownloadedBytes: downloadedBytes,
-						TotalBytes:      totalBytes,
-						DownloadSpeed:   speed,
-						ETA:             eta,
-					}
-				}
-			}
-		}
+	// "model.safetensors  45%[======>             ] 984.56K   977KB/s    eta 1s"
+	// "model.safetensors 100%[===================>]   1.91G  10.2MB/s    in 3m 10s"
+	matches := wgetProgressRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return ProgressInfo{Percentage: -1} // No progress found
+	}
+
+	percent, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return ProgressInfo{Percentage: -1}
+	}
+
+	return ProgressInfo{
+		Percentage:      percent,
+		DownloadedBytes: parseWgetSize(matches[2]),
+		DownloadSpeed:   matches[3],
+		ETA:             strings.TrimSpace(matches[4]),
+	}
+}
+
+// parseWgetSize converts sizes such as "984.56K" or "1,234,567" to bytes.
+// wget uses binary (1024-based) units.
+func parseWgetSize(value string) int64 {
+	value = strings.ReplaceAll(value, ",", "")
+	multiplier := float64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	case strings.HasSuffix(value, "T"):
+		multiplier = 1 << 40
+	}
+	number, err := strconv.ParseFloat(strings.TrimRight(value, "KMGT"), 64)
+	if err != nil {
+		return 0
 	}
-	return ProgressInfo{Percentage: -1} // No progress found
+	return int64(number * multiplier)
 }
 
 // isWgetAvailable checks if wget command is available
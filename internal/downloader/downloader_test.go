@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPDownloaderRecordsRevisionOfFirstResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/resolve/model.safetensors", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Repo-Commit", "abc123")
+		w.Header().Set("X-Linked-Etag", `"sha-of-file"`)
+		http.Redirect(w, r, "/cdn/model.safetensors", http.StatusFound)
+	})
+	mux.HandleFunc("/cdn/model.safetensors", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"cdn-etag"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte("weights"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	task := &DownloadTask{URL: server.URL + "/resolve/model.safetensors", FilePath: filepath.Join(t.TempDir(), "model.safetensors")}
+	if err := (&HTTPDownloader{}).Download(task); err != nil {
+		t.Fatal(err)
+	}
+
+	want := RemoteInfo{Revision: "abc123", ETag: "sha-of-file", LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}
+	if task.Remote != want {
+		t.Fatalf("Remote = %+v, want %+v", task.Remote, want)
+	}
+}
+
+func TestHandleWgetLineCollectsResponseHeaders(t *testing.T) {
+	logged := make([]string, 0)
+	task := &DownloadTask{LogCallback: func(line string) { logged = append(logged, line) }}
+
+	for _, line := range []string{
+		"HTTP request sent, awaiting response... ",
+		"  HTTP/1.1 302 Found",
+		"  X-Repo-Commit: abc123",
+		"  Set-Cookie: session=secret",
+		"  Location: https://cdn.example.com/model",
+		"  HTTP/1.1 200 OK",
+		`  ETag: W/"cdn-etag"`,
+		"  Last-Modified: Mon, 02 Jan 2006 15:04:05 GMT",
+		"Length: 7 (7B) [application/octet-stream]",
+	} {
+		handleWgetLine(task, line)
+	}
+
+	want := RemoteInfo{Revision: "abc123", ETag: "cdn-etag", LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}
+	if got := remoteInfoFromResponses(task.responseHeaders...); got != want {
+		t.Fatalf("remote info = %+v, want %+v", got, want)
+	}
+	if task.TotalBytes != 7 {
+		t.Errorf("TotalBytes = %d, want 7", task.TotalBytes)
+	}
+	for _, line := range logged {
+		if line == "Set-Cookie: session=secret" || line == "X-Repo-Commit: abc123" {
+			t.Errorf("header line was logged: %q", line)
+		}
+	}
+}
+
+func TestHandleWgetLineRedactsSignedURLs(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{
+			line: "Location: https://cdn-lfs.hf.co/repos/model?X-Amz-Signature=secret&Expires=1 [following]",
+			want: "Location: https://cdn-lfs.hf.co/repos/model?[redacted] [following]",
+		},
+		{
+			line: "--2026-01-02 03:04:05--  https://civitai-delivery.example.com/file.safetensors?token=secret",
+			want: "--2026-01-02 03:04:05--  https://civitai-delivery.example.com/file.safetensors?[redacted]",
+		},
+		{
+			line: "Connecting to example.com (example.com)|93.184.216.34|:443... connected.",
+			want: "Connecting to example.com (example.com)|93.184.216.34|:443... connected.",
+		},
+		{
+			line: "Location: https://example.com/model.safetensors [following]",
+			want: "Location: https://example.com/model.safetensors [following]",
+		},
+	}
+
+	for _, tt := range tests {
+		logged := make([]string, 0)
+		task := &DownloadTask{LogCallback: func(line string) { logged = append(logged, line) }}
+		handleWgetLine(task, tt.line)
+		if len(logged) != 1 || logged[0] != tt.want {
+			t.Errorf("logged %q, want %q", logged, tt.want)
+		}
+	}
+}
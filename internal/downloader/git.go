@@ -0,0 +1,153 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GitDownloader implements download using git clone
+type GitDownloader struct{}
+
+// BackendGit clones repositories
+const BackendGit = "git"
+
+// BackendForURL returns the backend that downloads a URL: git for
+// repositories, the HTTP client for hosts that get an API token and the
+// selected backend for everything else. wget would expose the token on its
+// command line and send it on to the hosts it is redirected to, while the
+// HTTP client drops it on redirects to other domains.
+func BackendForURL(url string) string {
+	switch {
+	case IsGitURL(url):
+		return BackendGit
+	case authorizationHeader(url) != "":
+		return BackendHTTP
+	}
+	return CurrentBackend()
+}
+
+// NewDownloaderForURL creates the downloader of BackendForURL
+func NewDownloaderForURL(url string) Downloader {
+	switch BackendForURL(url) {
+	case BackendGit:
+		return &GitDownloader{}
+	case BackendHTTP:
+		return &HTTPDownloader{}
+	}
+	return &WgetDownloader{}
+}
+
+// gitProgressRegex matches git progress lines such as "Receiving objects:  45% (450/1000)"
+var gitProgressRegex = regexp.MustCompile(`^([A-Za-z ]+):\s+(\d+)%`)
+
+// Download clones the repository into FilePath, or fast-forwards an existing clone
+func (g *GitDownloader) Download(task *DownloadTask) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git command is not available on this system")
+	}
+
+	ctx := task.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var cmd *exec.Cmd
+	if _, err := os.Stat(filepath.Join(task.FilePath, ".git")); err == nil {
+		cmd = exec.CommandContext(ctx, "git", "-C", task.FilePath, "pull", "--ff-only", "--progress")
+	} else {
+		cmd = exec.CommandContext(ctx, "git", "clone", "--progress", task.URL, task.FilePath)
+	}
+
+	// git writes progress and messages to stderr
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git: %v", err)
+	}
+
+	monitorGitProgress(task, stderr)
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("download stopped: %v", ctx.Err())
+		}
+		return fmt.Errorf("git command failed: %v", err)
+	}
+	return nil
+}
+
+// GitRevision returns the commit checked out in a repository
+func GitRevision(dir string) (string, error) {
+	output, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve revision: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GitRemoteRevision returns the commit HEAD points to in a remote repository
+func GitRemoteRevision(ctx context.Context, url string) (string, error) {
+	output, err := exec.CommandContext(ctx, "git", "ls-remote", url, "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query remote revision: %v", err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("remote has no HEAD")
+	}
+	return fields[0], nil
+}
+
+// monitorGitProgress forwards git output to the log and progress callbacks
+func monitorGitProgress(task *DownloadTask, stderr io.ReadCloser) {
+	buffer := make([]byte, 1024)
+	partialLine := ""
+
+	handle := func(line string) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return
+		}
+		if matches := gitProgressRegex.FindStringSubmatch(line); matches != nil {
+			// Receiving objects is the phase that reflects the transfer
+			percent, _ := strconv.ParseFloat(matches[2], 64)
+			if matches[1] == "Receiving objects" && percent != task.Progress {
+				task.Progress = percent
+				if task.ProgressCallback != nil {
+					task.ProgressCallback(ProgressInfo{Percentage: percent})
+				}
+			}
+			// Only the final progress line of each phase is logged
+			if !strings.Contains(line, "done") {
+				return
+			}
+		}
+		if task.LogCallback != nil {
+			task.LogCallback(line)
+		}
+	}
+
+	for {
+		n, err := stderr.Read(buffer)
+		if n > 0 {
+			var lines []string
+			lines, partialLine = splitOutputLines(partialLine + string(buffer[:n]))
+			for _, line := range lines {
+				handle(line)
+			}
+		}
+		if err != nil {
+			handle(partialLine)
+			break
+		}
+	}
+}
@@ -1,10 +1,34 @@
 package downloader
 
 import (
+	neturl "net/url"
 	"path/filepath"
 	"strings"
 )
 
+// Hosts whose two-segment paths (owner/repo) are treated as git repositories
+var gitHosts = []string{"github.com", "gitlab.com", "codeberg.org"}
+
+// IsGitURL reports whether a URL points at a git repository
+func IsGitURL(url string) bool {
+	parsed, err := neturl.Parse(url)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+
+	path := strings.Trim(parsed.Path, "/")
+	if strings.HasSuffix(path, ".git") {
+		return true
+	}
+
+	for _, host := range gitHosts {
+		if strings.EqualFold(parsed.Host, host) {
+			return len(strings.Split(path, "/")) == 2
+		}
+	}
+	return false
+}
+
 // ExtractFilenameFromURL extracts filename from URL
 func ExtractFilenameFromURL(url string) string {
 	parts := strings.Split(url, "/")
@@ -38,6 +62,15 @@ func SanitizeFilename(name string) string {
 
 // GenerateOutputPath generates the full output path for a download
 func GenerateOutputPath(installPath, url, resourceName string) string {
+	// Git repositories are cloned into a directory named after the repository
+	if IsGitURL(url) {
+		repository := strings.TrimSuffix(ExtractFilenameFromURL(strings.TrimRight(url, "/")), ".git")
+		if repository == "" {
+			repository = SanitizeFilename(resourceName)
+		}
+		return filepath.Join(installPath, repository)
+	}
+
 	// First, try to extract filename from URL
 	filename := ExtractFilenameFromURL(url)
 
@@ -0,0 +1,149 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// FileSHA256 computes the hex encoded sha256 digest of a file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// remoteClient requests headers of remote files
+var remoteClient = &http.Client{Timeout: 30 * time.Second, CheckRedirect: authorizedRedirect}
+
+// RemoteSize returns the Content-Length reported by the server for a URL
+// (following redirects), or -1 when the size is unknown. The request stops
+// when ctx is cancelled.
+func RemoteSize(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create request: %v", err)
+	}
+	authorize(req)
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return -1, fmt.Errorf("failed to request headers: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return resp.ContentLength, nil
+}
+
+// RemoteInfo identifies the revision of a remote file
+type RemoteInfo struct {
+	Revision     string // repository commit, e.g. X-Repo-Commit on Hugging Face
+	ETag         string
+	LastModified string
+}
+
+// RemoteMetadata returns revision headers of a URL. Headers of the first
+// response are used because hosts such as Hugging Face only send them there
+// before redirecting to a CDN.
+func RemoteMetadata(url string) (RemoteInfo, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return RemoteInfo{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	authorize(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return RemoteInfo{}, fmt.Errorf("failed to request headers: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return RemoteInfo{}, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	return remoteInfoFromHeader(resp.Header), nil
+}
+
+// remoteInfoFromHeader reads the revision headers of a response
+func remoteInfoFromHeader(header http.Header) RemoteInfo {
+	etag := header.Get("X-Linked-Etag")
+	if etag == "" {
+		etag = header.Get("ETag")
+	}
+	return RemoteInfo{
+		Revision:     header.Get("X-Repo-Commit"),
+		ETag:         strings.Trim(strings.TrimPrefix(etag, "W/"), `"`),
+		LastModified: header.Get("Last-Modified"),
+	}
+}
+
+// remoteInfoFromResponses reads the revision headers of a chain of responses.
+// Each value is taken from the first response that has it, like RemoteMetadata,
+// since the CDN a download is redirected to has its own ETag.
+func remoteInfoFromResponses(headers ...http.Header) RemoteInfo {
+	var info RemoteInfo
+	for _, header := range headers {
+		next := remoteInfoFromHeader(header)
+		if info.Revision == "" {
+			info.Revision = next.Revision
+		}
+		if info.ETag == "" {
+			info.ETag = next.ETag
+		}
+		if info.LastModified == "" {
+			info.LastModified = next.LastModified
+		}
+	}
+	return info
+}
+
+// FileMatches reports whether an existing file matches the expected sha256
+// or size. An empty sha256 and a non-positive size never match.
+func FileMatches(path, expectedSHA256 string, expectedSize int64) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if info.IsDir() {
+		return false, nil
+	}
+
+	if expectedSHA256 != "" {
+		actual, err := FileSHA256(path)
+		if err != nil {
+			return false, err
+		}
+		return strings.EqualFold(actual, expectedSHA256), nil
+	}
+
+	if expectedSize > 0 {
+		return info.Size() == expectedSize, nil
+	}
+
+	return false, nil
+}
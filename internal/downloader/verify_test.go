@@ -0,0 +1,58 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRemoteSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/model.safetensors":
+			w.Header().Set("Content-Length", "1024")
+		case "/redirect":
+			http.Redirect(w, r, "/model.safetensors", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		want    int64
+		wantErr bool
+	}{
+		{path: "/model.safetensors", want: 1024},
+		{path: "/redirect", want: 1024},
+		{path: "/missing", want: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		size, err := RemoteSize(context.Background(), server.URL+tt.path)
+		if size != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("RemoteSize(%s) = %d, %v; want %d, error %v", tt.path, size, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRemoteSizeStopsWhenCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A stalled server never answers
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	started := time.Now()
+	if _, err := RemoteSize(ctx, server.URL); err == nil {
+		t.Fatal("RemoteSize() succeeded against a stalled server")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("RemoteSize() returned after %v, want it to stop on cancel", elapsed)
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// reloadConfig loads the catalog and destinations again and logs the result.
+// A rejected configuration leaves the last good one active.
+func reloadConfig(reason string) (config.ReloadStatus, error) {
+	status, err := config.Reload()
+	if err != nil {
+		for _, issue := range status.Issues {
+			logger.Warn("Config issue: %s", issue)
+		}
+		logger.Error(err, "Rejected configuration reload (%s), keeping the last good configuration", reason)
+		return status, err
+	}
+	for _, issue := range status.Skipped {
+		logger.Warn("Left out invalid entry: %s", issue)
+	}
+	logger.Info("Reloaded configuration (%s): %d presets, %d bundles, %d destinations",
+		reason, status.Presets, status.Bundles, status.Destinations)
+	return status, nil
+}
+
+// startConfigWatcher polls the configuration files and reloads them when they change
+func startConfigWatcher(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		fingerprint := config.ConfigFingerprint()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			current := config.ConfigFingerprint()
+			if current == fingerprint {
+				continue
+			}
+			fingerprint = current
+			reloadConfig("files changed")
+		}
+	}()
+}
+
+// GetReloadStatusHandler returns when the configuration was last loaded and why a reload failed
+func GetReloadStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(config.GetReloadStatus()); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ReloadConfigHandler reloads the catalog and destinations right away.
+// An invalid configuration is reported with 422 and the last good one stays active.
+func ReloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := reloadConfig("requested")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
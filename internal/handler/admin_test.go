@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+func TestReloadConfigHandler(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "local.yaml")
+	config.SetCatalogDir(dir)
+	t.Cleanup(func() {
+		config.SetCatalogDir("")
+		config.Reload()
+	})
+
+	tests := []struct {
+		name       string
+		content    string
+		wantStatus int
+		wantError  bool
+	}{
+		{name: "valid catalog", content: "resources: []\n", wantStatus: http.StatusOK},
+		{name: "invalid catalog", content: "resources:\n  - id: broken\n", wantStatus: http.StatusUnprocessableEntity, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			ReloadConfigHandler(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var status config.ReloadStatus
+			if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+				t.Fatal(err)
+			}
+			if (status.Error != "") != tt.wantError {
+				t.Fatalf("error = %q, want an error: %v", status.Error, tt.wantError)
+			}
+
+			// The status endpoint reports the same result until the next reload
+			rec = httptest.NewRecorder()
+			GetReloadStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+			var reported config.ReloadStatus
+			if err := json.NewDecoder(rec.Body).Decode(&reported); err != nil {
+				t.Fatal(err)
+			}
+			if reported.Error != status.Error {
+				t.Fatalf("reported error = %q, want %q", reported.Error, status.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// startSubscriptionRefresher fetches remote catalogs now and then on every interval
+func startSubscriptionRefresher(interval time.Duration) {
+	if len(config.GetSubscriptionStatuses()) == 0 {
+		return
+	}
+
+	go func() {
+		refreshSubscriptions()
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			refreshSubscriptions()
+		}
+	}()
+}
+
+// refreshSubscriptions fetches remote catalogs, logs failures and applies
+// the new content
+func refreshSubscriptions() []config.SubscriptionStatus {
+	statuses := config.RefreshSubscriptions()
+	for _, status := range statuses {
+		if status.Error != "" {
+			logger.Warn("Catalog subscription %s failed: %s (cached copy: %t)", status.Name, status.Error, status.Cached)
+		}
+	}
+	reloadConfig("subscriptions refreshed")
+	return statuses
+}
+
+// GetCatalogSubscriptionsHandler returns the last fetch time and error of each subscription
+func GetCatalogSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	response := CatalogSubscriptionsResponse{Subscriptions: config.GetSubscriptionStatuses()}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RefreshCatalogSubscriptionsHandler fetches every subscription right away
+func RefreshCatalogSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	response := CatalogSubscriptionsResponse{Subscriptions: refreshSubscriptions()}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
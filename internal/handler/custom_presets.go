@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+// customPresetIDRegex keeps custom IDs usable as a single path segment. IDs of
+// subscription presets contain a slash ("team/model"); they can be overridden
+// and their overrides deleted with the slash escaped as %2F in the {id} segment.
+var customPresetIDRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// customPresetsMutex serializes changes to custom presets
+var customPresetsMutex sync.Mutex
+
+// customPresetEntries returns the stored custom presets in ID order, with the
+// entry of id replaced, or removed if entry is nil
+func customPresetEntries(id string, entry json.RawMessage) [][]byte {
+	presets := installStore.Presets()
+	if id != "" {
+		if entry == nil {
+			delete(presets, id)
+		} else {
+			presets[id] = entry
+		}
+	}
+
+	ids := make([]string, 0, len(presets))
+	for presetID := range presets {
+		ids = append(ids, presetID)
+	}
+	sort.Strings(ids)
+
+	entries := make([][]byte, 0, len(ids))
+	for _, presetID := range ids {
+		entries = append(entries, presets[presetID])
+	}
+	return entries
+}
+
+// findPreset returns the preset with the given ID from the active configuration
+func findPreset(presetID string) (config.PresetResource, bool, error) {
+	resources, err := config.GetPresetResources()
+	if err != nil {
+		return config.PresetResource{}, false, err
+	}
+	for _, resource := range resources {
+		if resource.ID == presetID {
+			return resource, true, nil
+		}
+	}
+	return config.PresetResource{}, false, nil
+}
+
+// decodePresetEntry reads a preset from the request body. Only the fields the
+// client sent are kept so that an override changes nothing else.
+func decodePresetEntry(r *http.Request) (json.RawMessage, config.PresetResource, error) {
+	var preset config.PresetResource
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, preset, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, preset, err
+	}
+	// Where a preset comes from is decided by the server
+	delete(fields, "source")
+	delete(fields, "override")
+
+	entry, err := json.Marshal(fields)
+	if err != nil {
+		return nil, preset, err
+	}
+	if err := json.Unmarshal(entry, &preset); err != nil {
+		return nil, preset, err
+	}
+	return entry, preset, nil
+}
+
+// withPresetID sets the id field of a stored entry
+func withPresetID(entry json.RawMessage, presetID string) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry, &fields); err != nil {
+		return nil, err
+	}
+	id, _ := json.Marshal(presetID)
+	fields["id"] = id
+	return json.Marshal(fields)
+}
+
+// saveCustomPreset validates the configuration with the entry of presetID
+// replaced (or removed if entry is nil), reloads the catalog with it and only
+// then stores it. A failed reload or store leaves the previous presets active.
+func saveCustomPreset(presetID string, entry json.RawMessage) (int, error) {
+	entries := customPresetEntries(presetID, entry)
+	if err := config.CheckCustomPresets(entries); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			messages := make([]string, 0, len(validationErr.Issues))
+			for _, issue := range validationErr.Issues {
+				messages = append(messages, issue.String())
+			}
+			return http.StatusBadRequest, fmt.Errorf("Invalid preset: %s", strings.Join(messages, "; "))
+		}
+		return http.StatusInternalServerError, fmt.Errorf("Failed to load preset resources: %v", err)
+	}
+
+	config.SetCustomPresets(entries)
+	if _, err := reloadConfig("presets changed"); err != nil {
+		restoreCustomPresets()
+		return http.StatusInternalServerError, fmt.Errorf("Failed to reload preset resources: %v", err)
+	}
+
+	var err error
+	if entry == nil {
+		err = installStore.DeletePreset(presetID)
+	} else {
+		err = installStore.PutPreset(presetID, entry)
+	}
+	if err != nil {
+		restoreCustomPresets()
+		return http.StatusInternalServerError, fmt.Errorf("Failed to store preset: %v", err)
+	}
+	return http.StatusOK, nil
+}
+
+// restoreCustomPresets activates the stored custom presets again after a failed change
+func restoreCustomPresets() {
+	config.SetCustomPresets(customPresetEntries("", nil))
+	reloadConfig("presets restored")
+}
+
+// writePresetResponse responds with the preset as it is merged now, or with
+// 204 if the change disabled it
+func writePresetResponse(w http.ResponseWriter, presetID string, statusCode int) {
+	preset, exists, err := findPreset(presetID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset resources: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(preset); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CreatePresetResourceHandler saves a new custom preset
+func CreatePresetResourceHandler(w http.ResponseWriter, r *http.Request) {
+	entry, preset, err := decodePresetEntry(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !customPresetIDRegex.MatchString(preset.ID) {
+		http.Error(w, "id is required and may only contain lowercase letters, digits, '.', '-' and '_'", http.StatusBadRequest)
+		return
+	}
+
+	customPresetsMutex.Lock()
+	defer customPresetsMutex.Unlock()
+
+	existing, exists, err := findPreset(preset.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset resources: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, stored := installStore.Presets()[preset.ID]; stored || exists {
+		message := fmt.Sprintf("Preset %s already exists", preset.ID)
+		if exists && existing.Source != config.CustomCatalogSource {
+			message += "; use PUT to override it"
+		}
+		http.Error(w, message, http.StatusConflict)
+		return
+	}
+
+	if statusCode, err := saveCustomPreset(preset.ID, entry); err != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+	writePresetResponse(w, preset.ID, http.StatusCreated)
+}
+
+// UpdatePresetResourceHandler replaces a custom preset, or overrides the
+// fields of a built-in preset that the request sets. Built-in presets
+// themselves are never changed.
+func UpdatePresetResourceHandler(w http.ResponseWriter, r *http.Request) {
+	presetID := r.PathValue("id")
+
+	entry, preset, err := decodePresetEntry(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if preset.ID != "" && preset.ID != presetID {
+		http.Error(w, "Preset ID cannot be changed", http.StatusBadRequest)
+		return
+	}
+	entry, err = withPresetID(entry, presetID)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	customPresetsMutex.Lock()
+	defer customPresetsMutex.Unlock()
+
+	_, exists, err := findPreset(presetID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset resources: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, stored := installStore.Presets()[presetID]; !stored && !exists {
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	if statusCode, err := saveCustomPreset(presetID, entry); err != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+	writePresetResponse(w, presetID, http.StatusOK)
+}
+
+// DeletePresetResourceHandler deletes a custom preset, or the override of a
+// built-in preset
+func DeletePresetResourceHandler(w http.ResponseWriter, r *http.Request) {
+	presetID := r.PathValue("id")
+
+	customPresetsMutex.Lock()
+	defer customPresetsMutex.Unlock()
+
+	if _, stored := installStore.Presets()[presetID]; !stored {
+		_, exists, err := findPreset(presetID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to load preset resources: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			http.Error(w, "Built-in presets cannot be deleted; disable them with an override instead", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	if statusCode, err := saveCustomPreset(presetID, nil); err != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+func TestSaveCustomPresetKeepsConfigurationWhenStoreFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	withInstallStore(t, path)
+	// A directory in place of the database makes every save fail
+	if err := os.MkdirAll(filepath.Join(path, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"id":"my-lora","name":"My LoRA","type":"lora","url":"https://example.com/my-lora.safetensors"}`
+	w := servePresetRequest(http.MethodPost, "/preset-resources", body)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("POST = %d %s, want 500", w.Code, w.Body)
+	}
+
+	if _, exists, err := findPreset("my-lora"); err != nil || exists {
+		t.Fatalf("preset active after a failed save (exists=%v, err=%v)", exists, err)
+	}
+}
+
+func TestInvalidCustomPresetIsNotStored(t *testing.T) {
+	withInstallStore(t, "")
+
+	body := `{"id":"my-lora","name":"My LoRA","type":"lora"}`
+	w := servePresetRequest(http.MethodPost, "/preset-resources", body)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("POST = %d %s, want 400", w.Code, w.Body)
+	}
+	if _, stored := installStore.Presets()["my-lora"]; stored {
+		t.Fatal("invalid preset was stored")
+	}
+}
+
+func TestSubscriptionPresetsCanBeOverriddenWithEscapedID(t *testing.T) {
+	withInstallStore(t, "")
+	cacheDir := t.TempDir()
+	catalog := "resources:\n  - id: good\n    name: Good\n    type: lora\n    url: https://example.com/good.safetensors\n"
+	if err := os.WriteFile(filepath.Join(cacheDir, "team.yaml"), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.SetCatalogSubscriptions([]config.CatalogSubscription{{Name: "team", URL: "https://example.com/team.yaml"}}, cacheDir)
+	t.Cleanup(func() { config.SetCatalogSubscriptions(nil, "") })
+	if _, err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := servePresetRequest(http.MethodPut, "/preset-resources/team%2Fgood", `{"name":"Renamed"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT = %d %s, want 200", w.Code, w.Body)
+	}
+	preset, exists, err := findPreset("team/good")
+	if err != nil || !exists {
+		t.Fatalf("findPreset() exists=%v err=%v", exists, err)
+	}
+	if preset.Name != "Renamed" || preset.Override != config.CustomCatalogSource {
+		t.Fatalf("preset = %q overridden by %q, want Renamed by custom", preset.Name, preset.Override)
+	}
+
+	w = servePresetRequest(http.MethodDelete, "/preset-resources/team%2Fgood", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d %s, want 204", w.Code, w.Body)
+	}
+	if preset, _, _ := findPreset("team/good"); preset.Name != "Good" {
+		t.Fatalf("override not removed, name = %q", preset.Name)
+	}
+}
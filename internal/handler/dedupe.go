@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// How long an Idempotency-Key keeps pointing at the task it created
+const idempotencyKeyTTL = 24 * time.Hour
+
+type idempotencyEntry struct {
+	TaskID    string
+	CreatedAt time.Time
+}
+
+// Idempotency keys of recent install requests (guarded by installTasksMutex)
+var idempotencyKeys = make(map[string]idempotencyEntry)
+
+// findActiveDuplicateLocked returns an active task that writes the same output
+// path, if any. The same URL installed to another directory or profile is not
+// a duplicate.
+// installTasksMutex must be held by the caller.
+func findActiveDuplicateLocked(outputPath string) *InstallTask {
+	outputPath = filepath.Clean(outputPath)
+	for _, task := range installTasks {
+		// Unfinished tasks, including paused ones, still own their output path
+		if task.Status.IsTerminal() {
+			continue
+		}
+		if filepath.Clean(task.OutputPath) == outputPath {
+			return task
+		}
+	}
+	return nil
+}
+
+// lookupIdempotencyKeyLocked returns the task created by an earlier request
+// with the same Idempotency-Key, dropping expired keys along the way.
+// installTasksMutex must be held by the caller.
+func lookupIdempotencyKeyLocked(key string) *InstallTask {
+	now := time.Now()
+	for k, entry := range idempotencyKeys {
+		if now.Sub(entry.CreatedAt) > idempotencyKeyTTL {
+			delete(idempotencyKeys, k)
+		}
+	}
+
+	entry, exists := idempotencyKeys[key]
+	if !exists {
+		return nil
+	}
+	return installTasks[entry.TaskID]
+}
+
+// rememberIdempotencyKeyLocked records the task created for an Idempotency-Key.
+// installTasksMutex must be held by the caller.
+func rememberIdempotencyKeyLocked(key, taskID string) {
+	if key == "" {
+		return
+	}
+	idempotencyKeys[key] = idempotencyEntry{TaskID: taskID, CreatedAt: time.Now()}
+}
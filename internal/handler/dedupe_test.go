@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postInstall sends an install request that is scheduled far in the future,
+// so no worker starts
+func postInstall(t *testing.T, path, url, idempotencyKey string) (int, InstallResponse) {
+	t.Helper()
+	notBefore := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	body := `{"url":"` + url + `","name":"model","path":"` + path + `","notBefore":"` + notBefore + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/installer/install", strings.NewReader(body))
+	if idempotencyKey != "" {
+		r.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	w := httptest.NewRecorder()
+	InstallHandler(w, r)
+
+	var response InstallResponse
+	if w.Code < 300 || w.Code == http.StatusConflict {
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("decode response %q: %v", w.Body, err)
+		}
+	}
+	return w.Code, response
+}
+
+func TestInstallDeduplicatesByOutputPath(t *testing.T) {
+	withInstallStore(t, "")
+	withInstallTasks(t)
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "comfyui"), filepath.Join(dir, "a1111")
+	const url = "https://example.com/model.safetensors"
+
+	code, created := postInstall(t, first, url, "")
+	if code != http.StatusOK {
+		t.Fatalf("first install = %d, want 200", code)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		url      string
+		key      string
+		want     int
+		wantTask bool // the response names the first task
+	}{
+		{name: "same URL to another directory", path: second, url: url, want: http.StatusOK},
+		{name: "same output path", path: first, url: url, want: http.StatusConflict, wantTask: true},
+		{name: "same output path with a trailing slash", path: first + "/", url: url, want: http.StatusConflict, wantTask: true},
+		{name: "other URL with the same file name", path: first, url: "https://mirror.example.com/model.safetensors", want: http.StatusConflict, wantTask: true},
+		{name: "retry with an idempotency key", path: filepath.Join(dir, "forge"), url: url, key: "retry-1", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, response := postInstall(t, tt.path, tt.url, tt.key)
+			if code != tt.want {
+				t.Fatalf("install = %d, want %d", code, tt.want)
+			}
+			if tt.wantTask && response.TaskID != created.TaskID {
+				t.Fatalf("conflict names task %s, want %s", response.TaskID, created.TaskID)
+			}
+		})
+	}
+}
+
+func TestInstallReturnsTheTaskOfARetriedRequest(t *testing.T) {
+	withInstallStore(t, "")
+	withInstallTasks(t)
+	dir := t.TempDir()
+
+	_, created := postInstall(t, filepath.Join(dir, "first"), "https://example.com/model.safetensors", "retry-1")
+	code, retried := postInstall(t, filepath.Join(dir, "second"), "https://example.com/model.safetensors", "retry-1")
+	if code != http.StatusOK || retried.TaskID != created.TaskID {
+		t.Fatalf("retry = %d task %s, want 200 task %s", code, retried.TaskID, created.TaskID)
+	}
+
+	installTasksMutex.RLock()
+	defer installTasksMutex.RUnlock()
+	if len(installTasks) != 1 {
+		t.Fatalf("%d tasks created, want 1", len(installTasks))
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/store"
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+// presetRouter serves the custom preset routes like the API router
+func presetRouter() *http.ServeMux {
+	router := http.NewServeMux()
+	router.HandleFunc("POST /preset-resources", CreatePresetResourceHandler)
+	router.HandleFunc("PUT /preset-resources/{id}", UpdatePresetResourceHandler)
+	router.HandleFunc("DELETE /preset-resources/{id}", DeletePresetResourceHandler)
+	return router
+}
+
+// servePresetRequest sends a request to the custom preset routes
+func servePresetRequest(method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	presetRouter().ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+// withInstallStore replaces the install store for a test
+func withInstallStore(t *testing.T, path string) {
+	t.Helper()
+	opened, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := installStore
+	installStore = opened
+	t.Cleanup(func() {
+		installStore = saved
+		config.SetCustomPresets(customPresetEntries("", nil))
+		config.Reload()
+	})
+}
+
+// withInstallTasks gives a test an empty task list and restores the previous one
+func withInstallTasks(t *testing.T) {
+	t.Helper()
+	installTasksMutex.Lock()
+	savedTasks, savedKeys, savedRunning := installTasks, idempotencyKeys, runningInstalls
+	installTasks = make(map[string]*InstallTask)
+	idempotencyKeys = make(map[string]idempotencyEntry)
+	runningInstalls = 0
+	installTasksMutex.Unlock()
+
+	t.Cleanup(func() {
+		installTasksMutex.Lock()
+		defer installTasksMutex.Unlock()
+		for _, task := range installTasks {
+			if task.cancel != nil {
+				task.cancel()
+			}
+		}
+		installTasks, idempotencyKeys, runningInstalls = savedTasks, savedKeys, savedRunning
+	})
+}
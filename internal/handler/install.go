@@ -1,12 +1,19 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"paperspace-stable-diffusion-station/internal/config"
 	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/internal/postinstall"
+	"paperspace-stable-diffusion-station/pkg/logger"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,144 +35,393 @@ func InstallHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validation
-	if req.URL == "" {
-		http.Error(w, "URL is required", http.StatusBadRequest)
-		return
-	}
-	if req.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
-		return
-	}
-	if req.Path == "" {
-		http.Error(w, "Path is required", http.StatusBadRequest)
+	task, statusCode, err := newInstallTask(req)
+	if err != nil {
+		http.Error(w, err.Error(), statusCode)
 		return
 	}
+	taskID := task.ID
 
-	// Generate task ID
-	taskID := fmt.Sprintf("task_%d", time.Now().UnixNano())
+	idempotencyKey := r.Header.Get("Idempotency-Key")
 
-	// Create installation task
-	task := &InstallTask{
-		ID:        taskID,
-		URL:       req.URL,
-		Name:      req.Name,
-		Path:      req.Path,
-		Type:      req.Type,
-		Status:    "pending",
-		Progress:  0,
-		StartTime: time.Now(),
+	installTasksMutex.Lock()
+
+	// A retried request with the same Idempotency-Key gets the original task back
+	if idempotencyKey != "" {
+		if existing := lookupIdempotencyKeyLocked(idempotencyKey); existing != nil {
+			response := InstallResponse{
+				TaskID:  existing.ID,
+				Status:  existing.Status,
+				Message: "Installation task already created for this Idempotency-Key",
+			}
+			installTasksMutex.Unlock()
+			writeInstallResponse(w, http.StatusOK, response)
+			return
+		}
 	}
 
-	// Store task in map
-	installTasksMutex.Lock()
-	installTasks[taskID] = task
-	installTasksMutex.Unlock()
+	// Refuse a second task for the same output path while the first is active
+	if existing := findActiveDuplicateLocked(task.OutputPath); existing != nil {
+		response := InstallResponse{
+			TaskID:  existing.ID,
+			Status:  existing.Status,
+			Message: "An installation to the same output path is already in progress",
+		}
+		installTasksMutex.Unlock()
+		writeInstallResponse(w, http.StatusConflict, response)
+		return
+	}
 
-	// Start installation process asynchronously
-	go processInstallation(task)
+	// Store task in map and let the scheduler start it when a slot is free
+	task.logs.Add("installer", "Created %s task %s -> %s (priority %d)", task.Status, task.URL, task.OutputPath, task.Priority)
+	enqueueTaskLocked(task)
+	rememberIdempotencyKeyLocked(idempotencyKey, taskID)
+	installTasksMutex.Unlock()
 
 	// Return response
 	response := InstallResponse{
 		TaskID:  taskID,
-		Status:  "pending",
+		Status:  task.Status,
 		Message: "Installation task created successfully",
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeInstallResponse(w, http.StatusOK, response)
+}
+
+// lastTaskNanos keeps generated task IDs unique when tasks are created in quick succession
+var lastTaskNanos atomic.Int64
+
+// newTaskID generates a unique task ID
+func newTaskID() string {
+	for {
+		now := time.Now().UnixNano()
+		last := lastTaskNanos.Load()
+		if now <= last {
+			now = last + 1
+		}
+		if lastTaskNanos.CompareAndSwap(last, now) {
+			return fmt.Sprintf("task_%d", now)
+		}
+	}
+}
+
+// resolveInstallPath picks the destination of the request type in the profile
+// of the request (or the active profile) when Path is omitted, and rejects a
+// Path that is the destination of another type unless AllowTypeMismatch is set
+func resolveInstallPath(req *InstallRequest) (int, error) {
+	resourceType, knownType := config.LookupResourceType(req.Type)
+	if req.Path != "" && (!knownType || req.AllowTypeMismatch) {
+		return http.StatusOK, nil
+	}
+	if req.Path == "" && !knownType {
+		return http.StatusBadRequest, fmt.Errorf("Path is required unless type is one of: %s", strings.Join(config.ResourceTypes, ", "))
+	}
+
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to load installation destinations: %v", err)
+	}
+
+	if req.Path != "" {
+		if err := destinations.CheckDestination(resourceType, req.Path); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("%v; set allowTypeMismatch to install anyway", err)
+		}
+		return http.StatusOK, nil
+	}
+
+	profileName := req.Profile
+	if profileName == "" {
+		profileName = destinations.Active
+	}
+	profile, exists := destinations.Profile(profileName)
+	if !exists {
+		return http.StatusBadRequest, fmt.Errorf("Unknown profile %q", profileName)
+	}
+	destination, exists := profile.Destination(resourceType.Destination)
+	if !exists {
+		return http.StatusBadRequest, fmt.Errorf("Profile %s has no %s destination", profileName, resourceType.Destination)
+	}
+	req.Path = destination.Path
+	req.Profile = profileName
+	return http.StatusOK, nil
+}
+
+// newInstallTask validates an install request and builds its task.
+// On error the returned status code is the HTTP status to respond with.
+func newInstallTask(req InstallRequest) (*InstallTask, int, error) {
+	// Validation
+	if req.URL == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("URL is required")
+	}
+	if req.Name == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("Name is required")
+	}
+	if statusCode, err := resolveInstallPath(&req); err != nil {
+		return nil, statusCode, err
+	}
+
+	var window *installWindow
+	if req.Window != "" {
+		parsed, err := parseInstallWindow(req.Window)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		window = parsed
+	}
+
+	postInstallSteps, droppedSteps, err := resolvePostInstallSteps(req)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to load post-install steps: %v", err)
+	}
+	for _, step := range postInstallSteps {
+		if err := postinstall.Validate(step); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Invalid post-install step %q: %v", step.Name, err)
+		}
+	}
+
+	// Create installation task
+	task := &InstallTask{
+		ID:          newTaskID(),
+		URL:         req.URL,
+		Name:        req.Name,
+		Path:        req.Path,
+		OutputPath:  downloader.GenerateOutputPath(req.Path, req.URL, req.Name),
+		Type:        req.Type,
+		SHA256:      req.SHA256,
+		Size:        req.Size,
+		Status:      StateQueued,
+		Progress:    0,
+		Priority:    req.Priority,
+		StartTime:   time.Now(),
+		NotBefore:   req.NotBefore,
+		Window:      req.Window,
+		PresetID:    req.PresetID,
+		Profile:     req.Profile,
+		PostInstall: postInstallSteps,
+		logs:        newTaskLog(),
+		window:      window,
+	}
+
+	// Deferred tasks wait in the scheduled state until their start time
+	if start := task.earliestStartLocked(task.StartTime); start.After(task.StartTime) {
+		task.Status = StateScheduled
+		task.ScheduledFor = &start
+	}
+	task.Timeline = []TaskPhase{{State: task.Status, At: task.StartTime}}
+	for _, name := range droppedSteps {
+		task.logs.Add("installer", "Skipping post-install step %q: it is only allowed for presets from local catalog files", name)
+	}
+
+	return task, http.StatusOK, nil
+}
+
+// writeInstallResponse encodes an InstallResponse with the given status code
+func writeInstallResponse(w http.ResponseWriter, statusCode int, response InstallResponse) {
+	w.WriteHeader(statusCode)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-// processInstallation executes the installation process
-func processInstallation(task *InstallTask) {
-	installTasksMutex.Lock()
-	task.Status = "downloading"
-	installTasksMutex.Unlock()
+// processInstallation executes the installation process.
+// ctx is cancelled when the task is cancelled or paused by the user.
+func processInstallation(ctx context.Context, task *InstallTask) {
+	task.logs.Add("installer", "Installation started")
 
 	// Create installation directory using destination path directly
 	installPath := task.Path
 	if err := os.MkdirAll(installPath, 0755); err != nil {
-		installTasksMutex.Lock()
-		task.Status = "failed"
-		task.Error = fmt.Sprintf("Failed to create directory: %v", err)
-		now := time.Now()
-		task.EndTime = &now
-		installTasksMutex.Unlock()
+		failTask(task, fmt.Sprintf("Failed to create directory: %v", err))
 		return
 	}
 
 	// Check if resource has URL for download
 	if task.URL == "" {
+		failTask(task, "No URL provided for resource download")
+		return
+	}
+
+	// Keep an existing file when it already matches the expected hash or size.
+	// The station did not create it, so no receipt is recorded and
+	// uninstalling never deletes it.
+	if existingFileMatches(ctx, task) {
+		task.logs.Add("validation", "Existing file %s matches, skipping download", task.OutputPath)
+		task.logs.Add("installer", "Not recorded as installed; the file was not created by this task")
 		installTasksMutex.Lock()
-		task.Status = "failed"
-		task.Error = "No URL provided for resource download"
-		now := time.Now()
-		task.EndTime = &now
+		task.Progress = 100
+		task.Skipped = true
+		task.InstalledPaths = []string{task.OutputPath}
+		task.transitionLocked(StateCompleted, "existing file kept")
 		installTasksMutex.Unlock()
 		return
 	}
 
 	// Download file using downloader package
-	if err := downloadFile(task, installPath); err != nil {
-		installTasksMutex.Lock()
-		task.Status = "failed"
-		task.Error = fmt.Sprintf("Download failed: %v", err)
-		now := time.Now()
-		task.EndTime = &now
-		installTasksMutex.Unlock()
+	if !setTaskState(task, StateDownloading, "") {
+		return
+	}
+	if err := downloadFile(ctx, task); err != nil {
+		if ctx.Err() != nil {
+			// Cancelled or paused; the state has already been changed by the caller
+			task.logs.Add("installer", "Download stopped: %v", err)
+			return
+		}
+		failTask(task, fmt.Sprintf("Download failed: %v", err))
+		return
+	}
+
+	// Verify the downloaded file against the expected hash or size
+	if !setTaskState(task, StateVerifying, "") {
+		return
+	}
+	if err := verifyDownloadedFile(task); err != nil {
+		failTask(task, fmt.Sprintf("Validation failed: %v", err))
+		return
+	}
+	installTasksMutex.Lock()
+	task.InstalledPaths = []string{task.OutputPath}
+	installTasksMutex.Unlock()
+
+	// Updates replace the installed version only once the new one is verified
+	if task.UpdateOf != "" {
+		if err := swapInUpdate(task); err != nil {
+			failTask(task, fmt.Sprintf("Update failed: %v", err))
+			return
+		}
+	}
+
+	// Run post-install steps, each as its own phase
+	if !runPostInstallSteps(ctx, task) {
+		if task.UpdateOf != "" {
+			rollbackFailedUpdate(task)
+		}
 		return
 	}
 
 	// Installation completed
 	installTasksMutex.Lock()
-	task.Status = "completed"
 	task.Progress = 100
-	now := time.Now()
-	task.EndTime = &now
+	completed := task.transitionLocked(StateCompleted, "")
 	installTasksMutex.Unlock()
+	if completed {
+		recordInstall(task)
+	}
 }
 
 // downloadFile downloads a file using the downloader package
-func downloadFile(task *InstallTask, installPath string) error {
-	// Update status to installing
+func downloadFile(ctx context.Context, task *InstallTask) error {
 	installTasksMutex.Lock()
-	task.Status = "installing"
-	task.Progress = 0
+	resume := task.resumeDownload
+	task.resumeDownload = false
+	if !resume {
+		task.Progress = 0
+		task.DownloadedBytes = 0
+	}
+	task.resetTransferRateLocked()
 	installTasksMutex.Unlock()
 
-	// Generate output path
-	outputPath := downloader.GenerateOutputPath(installPath, task.URL, task.Name)
+	source := downloader.BackendForURL(task.URL)
 
 	// Create download task with progress callback
 	downloadTask := &downloader.DownloadTask{
+		Context:  ctx,
 		URL:      task.URL,
-		FilePath: outputPath,
+		FilePath: task.OutputPath,
+		Resume:   resume,
 		Progress: 0,
-		ProgressCallback: func(progress float64) {
+		ProgressCallback: func(info downloader.ProgressInfo) {
 			// Update task progress in real-time
 			installTasksMutex.Lock()
-			task.Progress = progress
+			task.Progress = info.Percentage
+			task.updateTransferStatsLocked(info.DownloadedBytes, info.TotalBytes, time.Now())
 			installTasksMutex.Unlock()
 		},
+		LogCallback: func(line string) {
+			task.logs.Add(source, "%s", line)
+		},
 	}
 
 	// Create downloader and download
-	dl := downloader.NewDownloader()
+	dl := downloader.NewDownloaderForURL(task.URL)
 	if err := dl.Download(downloadTask); err != nil {
 		return err
 	}
 
 	// Update progress to completion
 	installTasksMutex.Lock()
+	task.remote = downloadTask.Remote
 	task.Progress = 100
+	task.resetTransferRateLocked()
 	installTasksMutex.Unlock()
 
 	return nil
 }
 
+// verifyDownloadedFile checks the downloaded file against the expected sha256 and size
+func verifyDownloadedFile(task *InstallTask) error {
+	info, err := os.Stat(task.OutputPath)
+	if err != nil {
+		return fmt.Errorf("downloaded file not found: %v", err)
+	}
+	if info.IsDir() {
+		task.logs.Add("validation", "Repository cloned into %s", task.OutputPath)
+		return nil
+	}
+	task.logs.Add("validation", "Downloaded file size: %d bytes", info.Size())
+
+	if task.Size > 0 && info.Size() != task.Size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d bytes", task.Size, info.Size())
+	}
+
+	// The digest is also recorded in the install receipt
+	actual, err := downloader.FileSHA256(task.OutputPath)
+	if err != nil {
+		return err
+	}
+	if task.SHA256 != "" {
+		if !strings.EqualFold(actual, task.SHA256) {
+			return fmt.Errorf("sha256 mismatch: expected %s, got %s", task.SHA256, actual)
+		}
+		task.logs.Add("validation", "sha256 verified: %s", actual)
+	}
+	installTasksMutex.Lock()
+	task.fileSHA256 = actual
+	installTasksMutex.Unlock()
+	return nil
+}
+
+// existingFileMatches reports whether the task's output file is already in place.
+// Without an expected hash or size the remote Content-Length is used instead.
+func existingFileMatches(ctx context.Context, task *InstallTask) bool {
+	// Existing git clones are updated instead
+	if downloader.IsGitURL(task.URL) {
+		return false
+	}
+	if _, err := os.Stat(task.OutputPath); err != nil {
+		return false
+	}
+
+	expectedSize := task.Size
+	if task.SHA256 == "" && expectedSize <= 0 {
+		remoteSize, err := downloader.RemoteSize(ctx, task.URL)
+		if err != nil {
+			logger.Warn("Could not determine remote size of %s: %v", task.URL, err)
+			task.logs.Add("validation", "Could not determine remote size: %v", err)
+			return false
+		}
+		expectedSize = remoteSize
+	}
+
+	matches, err := downloader.FileMatches(task.OutputPath, task.SHA256, expectedSize)
+	if err != nil {
+		logger.Warn("Could not verify existing file %s: %v", task.OutputPath, err)
+		task.logs.Add("validation", "Could not verify existing file: %v", err)
+		return false
+	}
+	return matches
+}
+
 // GetInstallStatusHandler handles getting the status of installation tasks
 func GetInstallStatusHandler(w http.ResponseWriter, r *http.Request) {
 
@@ -183,6 +439,10 @@ func GetInstallStatusHandler(w http.ResponseWriter, r *http.Request) {
 
 	installTasksMutex.RLock()
 	task, exists := installTasks[taskID]
+	var snapshot InstallTask
+	if exists {
+		snapshot = *task
+	}
 	installTasksMutex.RUnlock()
 
 	if !exists {
@@ -191,7 +451,7 @@ func GetInstallStatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(task); err != nil {
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -215,17 +475,23 @@ func CancelInstallHandler(w http.ResponseWriter, r *http.Request) {
 
 	installTasksMutex.Lock()
 	task, exists := installTasks[req.TaskID]
-	if exists {
-		task.Status = "cancelled"
-		now := time.Now()
-		task.EndTime = &now
-	}
-	installTasksMutex.Unlock()
-
 	if !exists {
+		installTasksMutex.Unlock()
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
+	if !task.transitionLocked(StateCancelled, "cancelled by user") {
+		state := task.Status
+		installTasksMutex.Unlock()
+		http.Error(w, fmt.Sprintf("Task cannot be cancelled in state %s", state), http.StatusConflict)
+		return
+	}
+	// Stop the running download, if any
+	if task.cancel != nil {
+		task.cancel()
+	}
+	updateQueuePositionsLocked()
+	installTasksMutex.Unlock()
 
 	response := map[string]string{
 		"status":  "cancelled",
@@ -239,7 +505,10 @@ func CancelInstallHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetAllInstallTasksHandler handles getting all installation tasks
+// GetAllInstallTasksHandler handles getting all installation tasks.
+// The response body stays a plain array; the total number of matching tasks
+// and the cursor of the next page are returned in the X-Total-Count and
+// X-Next-Cursor headers.
 func GetAllInstallTasksHandler(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != "GET" {
@@ -247,15 +516,30 @@ func GetAllInstallTasksHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, err := parseTaskQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	installTasksMutex.RLock()
-	tasks := make([]*InstallTask, 0, len(installTasks))
+	tasks := make([]InstallTask, 0, len(installTasks))
 	for _, task := range installTasks {
-		tasks = append(tasks, task)
+		if query.matches(task) {
+			tasks = append(tasks, *task)
+		}
 	}
 	installTasksMutex.RUnlock()
 
+	query.sortTasks(tasks)
+	page, nextCursor := query.paginate(tasks)
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(tasks)))
+	if nextCursor != "" {
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+	if err := json.NewEncoder(w).Encode(page); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -8,7 +8,7 @@ import (
 	"paperspace-stable-diffusion-station/internal/config"
 )
 
-// GetInstallationDestinationsHandler returns the list of available installation destinations
+// GetInstallationDestinationsHandler returns the installation destinations of the active profile
 func GetInstallationDestinationsHandler(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET requests
 	if r.Method != "GET" {
@@ -16,25 +16,39 @@ func GetInstallationDestinationsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get installation destinations from config
-	destinations, err := config.GetInstallDestinations()
+	// Get installation destinations of the active profile from config
+	destinations, err := config.GetDestinations()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to load installation destinations: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// ?profile= lists the destinations of another profile
+	profileName := destinations.Active
+	if param := r.URL.Query().Get("profile"); param != "" {
+		profileName = param
+	}
+	profile, exists := destinations.Profile(profileName)
+	if !exists {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
 	// Convert to API response format
-	apiDestinations := make([]InstallationDestination, len(destinations))
-	for i, dest := range destinations {
+	apiDestinations := make([]InstallationDestination, len(profile.Destinations))
+	for i, dest := range profile.Destinations {
 		apiDestinations[i] = InstallationDestination{
-			Type: dest.Type,
-			Path: dest.Path,
+			Type:     dest.Type,
+			Path:     dest.Path,
+			Template: dest.Template,
 		}
 	}
 
 	// Create response
 	response := InstallationDestinationsResponse{
+		Profile:      profile.Name,
 		Destinations: apiDestinations,
+		Variables:    config.GetPathVariables(),
 	}
 
 	// Encode and send response
@@ -43,3 +57,34 @@ func GetInstallationDestinationsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 }
+
+// GetDestinationProfilesHandler returns every destination profile and the active one
+func GetDestinationProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load installation destinations: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := DestinationProfilesResponse{
+		Active:   destinations.Active,
+		Profiles: destinations.Profiles,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetResourceTypesHandler returns the resource types and the destination type each installs to
+func GetResourceTypesHandler(w http.ResponseWriter, r *http.Request) {
+	response := ResourceTypesResponse{Types: config.GetResourceTypes()}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
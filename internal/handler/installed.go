@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/internal/store"
+	"paperspace-stable-diffusion-station/internal/version"
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// installStore records installed resources; replaced by the persistent store in Init
+var installStore, _ = store.Open("")
+
+// migrateLegacyReceiptsLocked moves receipts of profile-based presets recorded
+// under the bare preset ID, before receipts were kept per profile, to the
+// active profile, where they were installed. Restored update tasks follow the
+// move. installTasksMutex must be held by the caller.
+func migrateLegacyReceiptsLocked() {
+	resources, err := config.GetPresetResources()
+	if err != nil {
+		return
+	}
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		return
+	}
+	profileBased := make(map[string]bool, len(resources))
+	for _, preset := range resources {
+		profileBased[preset.ID] = preset.DestinationType() != ""
+	}
+
+	for _, resource := range installStore.Installed() {
+		if resource.PresetID == "" || resource.Profile != "" || resource.ID != resource.PresetID || !profileBased[resource.PresetID] {
+			continue
+		}
+		migrated := resource
+		migrated.Profile = destinations.Active
+		migrated.ID = receiptID(resource.PresetID, destinations.Active)
+		if _, exists := installStore.GetInstalled(migrated.ID); exists {
+			continue
+		}
+		if err := installStore.RenameInstalled(resource.ID, migrated); err != nil {
+			logger.Error(err, "Failed to move the install record of %s to profile %s", resource.PresetID, destinations.Active)
+			continue
+		}
+		for _, task := range installTasks {
+			if task.UpdateOf == resource.ID {
+				task.UpdateOf = migrated.ID
+				persistTaskLocked(task)
+			}
+		}
+		logger.Info("Moved the install record of %s to profile %s", resource.PresetID, destinations.Active)
+	}
+}
+
+// recordInstall stores the receipt of a completed task and exports the
+// station.lock of its profile
+func recordInstall(task *InstallTask) {
+	installTasksMutex.RLock()
+	resource := store.InstalledResource{
+		ID:          task.ID,
+		PresetID:    task.PresetID,
+		Profile:     task.Profile,
+		TaskID:      task.ID,
+		Name:        task.Name,
+		Type:        task.Type,
+		URL:         task.URL,
+		Path:        task.Path,
+		Paths:       append([]string{}, task.InstalledPaths...),
+		InstalledAt: time.Now(),
+	}
+	outputPath := task.OutputPath
+	remote := task.remote
+	fileSHA256 := task.fileSHA256
+	installTasksMutex.RUnlock()
+
+	if resource.PresetID != "" {
+		resource.ID = receiptID(resource.PresetID, resource.Profile)
+	}
+	if task.UpdateOf != "" {
+		resource.ID = task.UpdateOf
+		resource.Previous = task.previousInstall
+	}
+	resource.InstallerVersion = version.Get().Version
+	for _, path := range resource.Paths {
+		resource.Size += pathSize(path)
+	}
+
+	// Resolve exactly what was installed
+	if downloader.IsGitURL(resource.URL) {
+		revision, err := downloader.GitRevision(outputPath)
+		if err != nil {
+			task.logs.Add("installer", "Could not resolve revision: %v", err)
+		}
+		resource.Revision = revision
+	} else if versionID, ok := downloader.CivitaiVersionID(resource.URL); ok {
+		resource.Revision = versionID
+	} else {
+		// Taken from the download itself so they describe the file that was installed
+		resource.Revision = remote.Revision
+		resource.ETag = remote.ETag
+		resource.LastModified = remote.LastModified
+	}
+	// The digest computed while verifying, unless post-install steps replaced the file
+	if len(resource.Paths) == 1 && resource.Paths[0] == outputPath {
+		resource.SHA256 = fileSHA256
+	}
+
+	if err := installStore.PutInstalled(resource); err != nil {
+		logger.Error(err, "Failed to record install %s", resource.ID)
+		task.logs.Add("installer", "Failed to record install: %v", err)
+		return
+	}
+	task.logs.Add("installer", "Recorded install %s (%d paths, %d bytes)", resource.ID, len(resource.Paths), resource.Size)
+	exportLockFile(resource)
+}
+
+// exportLockFile refreshes the station.lock in the root of the profile a
+// receipt belongs to. Each lock file covers every receipt of its profile.
+func exportLockFile(resource store.InstalledResource) {
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		logger.Error(err, "Failed to load installation destinations, %s not exported", store.LockFileName)
+		return
+	}
+	for _, profile := range destinations.Profiles {
+		if profile.Root == "" || !inProfile(resource, profile) {
+			continue
+		}
+		if err := installStore.ExportLockFile(profile.Root, func(r store.InstalledResource) bool { return inProfile(r, profile) }); err != nil {
+			logger.Error(err, "Failed to export %s in %s", store.LockFileName, profile.Root)
+		}
+	}
+}
+
+// inProfile reports whether a receipt belongs to a profile: it was installed
+// for the profile, or without a profile into a directory under its root
+func inProfile(resource store.InstalledResource, profile config.DestinationProfile) bool {
+	if resource.Profile != "" {
+		return resource.Profile == profile.Name
+	}
+	return profile.Root != "" && (filepath.Clean(resource.Path) == filepath.Clean(profile.Root) || isWithinDir(resource.Path, profile.Root))
+}
+
+// pathSize returns the size of a file or the total size of a directory tree
+func pathSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// installedDependents returns the installed presets that depend on presetID
+// in the given profile. Dependents in any profile count for a preset with an
+// explicit destination_path, which every profile shares.
+func installedDependents(presetID, profile string) ([]string, error) {
+	if presetID == "" {
+		return nil, nil
+	}
+
+	resources, err := config.GetPresetResources()
+	if err != nil {
+		return nil, err
+	}
+
+	installedPresets := make(map[string]bool)
+	for _, installed := range installStore.Installed() {
+		if profile == "" || installed.Profile == "" || installed.Profile == profile {
+			installedPresets[installed.PresetID] = true
+		}
+	}
+
+	dependents := make([]string, 0)
+	for _, resource := range resources {
+		if !installedPresets[resource.ID] {
+			continue
+		}
+		for _, dependency := range resource.DependsOn {
+			if dependency == presetID {
+				dependents = append(dependents, resource.ID)
+				break
+			}
+		}
+	}
+	return dependents, nil
+}
+
+// isWithinDir reports whether path is strictly inside dir
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// GetInstalledResourcesHandler lists the recorded installs
+func GetInstalledResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(InstalledResourcesResponse{Resources: installStore.Installed()}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetInstalledResourceHandler returns the receipt of a single install
+func GetInstalledResourceHandler(w http.ResponseWriter, r *http.Request) {
+	resource, exists := installStore.GetInstalled(r.PathValue("id"))
+	if !exists {
+		http.Error(w, "Installed resource not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resource); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UninstallHandler removes the files recorded in an install manifest.
+// Installs other installed presets depend on are kept unless ?force=true is given.
+func UninstallHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	force := false
+	if value := r.URL.Query().Get("force"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "force must be a boolean", http.StatusBadRequest)
+			return
+		}
+		force = parsed
+	}
+
+	resource, exists := installStore.GetInstalled(id)
+	if !exists {
+		http.Error(w, "Installed resource not found", http.StatusNotFound)
+		return
+	}
+
+	dependents, err := installedDependents(resource.PresetID, resource.Profile)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset resources: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(dependents) > 0 && !force {
+		http.Error(w, fmt.Sprintf("Installed presets depend on %s: %s (use force=true to uninstall anyway)", id, strings.Join(dependents, ", ")), http.StatusConflict)
+		return
+	}
+
+	// Do not remove files a running task is writing
+	installTasksMutex.RLock()
+	for _, task := range installTasks {
+		if !task.Status.IsTerminal() && (task.URL == resource.URL || resource.PresetID != "" && task.PresetID == resource.PresetID) {
+			installTasksMutex.RUnlock()
+			http.Error(w, fmt.Sprintf("Task %s is installing %s", task.ID, id), http.StatusConflict)
+			return
+		}
+	}
+	installTasksMutex.RUnlock()
+
+	// The version kept for rollback goes as well
+	paths := resource.Paths
+	if resource.Previous != nil {
+		paths = append(append([]string{}, paths...), resource.Previous.Paths...)
+	}
+
+	// Never follow a manifest outside of the destination directory; checked
+	// before anything is removed so a bad manifest removes nothing
+	for _, path := range paths {
+		if !isWithinDir(path, resource.Path) {
+			http.Error(w, fmt.Sprintf("Refusing to remove %s outside of %s", path, resource.Path), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := UninstallResponse{ID: id, RemovedPaths: make([]string, 0), MissingPaths: make([]string, 0)}
+	for _, path := range paths {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			response.MissingPaths = append(response.MissingPaths, path)
+			continue
+		}
+
+		size := pathSize(path)
+		if err := os.RemoveAll(path); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to remove %s: %v", path, err), http.StatusInternalServerError)
+			return
+		}
+		response.RemovedPaths = append(response.RemovedPaths, path)
+		response.ReclaimedBytes += size
+	}
+
+	if err := installStore.DeleteInstalled(id); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update install records: %v", err), http.StatusInternalServerError)
+		return
+	}
+	exportLockFile(resource)
+	logger.Info("Uninstalled %s, reclaimed %d bytes", id, response.ReclaimedBytes)
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
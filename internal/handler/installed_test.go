@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/internal/store"
+)
+
+// callUninstall sends DELETE /installer/installed/{id} with the query
+func callUninstall(id, query string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodDelete, "/installer/installed/"+url.PathEscape(id)+"?"+query, nil)
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	UninstallHandler(w, r)
+	return w
+}
+
+func TestUninstall(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		outside     bool         // the manifest lists a path outside of the destination
+		task        *InstallTask // a task of the same resource
+		dependent   bool         // an installed preset depends on the resource
+		wantCode    int
+		wantRemoved int
+		wantMissing int
+	}{
+		{name: "removes the files and the record", wantCode: http.StatusOK, wantRemoved: 2, wantMissing: 1},
+		{name: "path outside of the destination", outside: true, wantCode: http.StatusInternalServerError},
+		{name: "task still running", task: &InstallTask{Status: StateDownloading}, wantCode: http.StatusConflict},
+		{name: "finished task does not block", task: &InstallTask{Status: StateCompleted}, wantCode: http.StatusOK, wantRemoved: 2, wantMissing: 1},
+		{name: "installed dependent", dependent: true, wantCode: http.StatusConflict},
+		{name: "installed dependent with force", dependent: true, query: "force=true", wantCode: http.StatusOK, wantRemoved: 2, wantMissing: 1},
+		{name: "invalid force", query: "force=maybe", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInstallStore(t, "")
+			withInstallTasks(t)
+			for _, body := range []string{
+				`{"id":"base-model","name":"Base","type":"checkpoint","url":"https://example.com/base.safetensors"}`,
+				`{"id":"my-lora","name":"LoRA","type":"lora","url":"https://example.com/lora.safetensors","depends_on":["base-model"]}`,
+			} {
+				if w := servePresetRequest(http.MethodPost, "/preset-resources", body); w.Code != http.StatusCreated {
+					t.Fatalf("POST = %d %s", w.Code, w.Body)
+				}
+			}
+
+			dir := t.TempDir()
+			model := filepath.Join(dir, "base.safetensors")
+			extracted := filepath.Join(dir, "base")
+			previous := filepath.Join(dir, ".base.safetensors.previous")
+			for _, path := range []string{model, previous, filepath.Join(extracted, "config.json")} {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			paths := []string{model, extracted, filepath.Join(dir, "gone.txt")}
+			if tt.outside {
+				paths = append(paths, filepath.Join(t.TempDir(), "elsewhere"))
+			}
+			resource := store.InstalledResource{
+				ID:       "base-model",
+				PresetID: "base-model",
+				URL:      "https://example.com/base.safetensors",
+				Path:     dir,
+				Paths:    paths,
+				Previous: &store.InstalledResource{Path: dir, Paths: []string{previous}},
+			}
+			if err := installStore.PutInstalled(resource); err != nil {
+				t.Fatal(err)
+			}
+			if tt.dependent {
+				if err := installStore.PutInstalled(store.InstalledResource{ID: "my-lora", PresetID: "my-lora"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.task != nil {
+				tt.task.ID = "task"
+				tt.task.URL = resource.URL
+				tt.task.logs = newTaskLog()
+				installTasksMutex.Lock()
+				installTasks[tt.task.ID] = tt.task
+				installTasksMutex.Unlock()
+			}
+
+			w := callUninstall(resource.ID, tt.query)
+			if w.Code != tt.wantCode {
+				t.Fatalf("DELETE = %d %s, want %d", w.Code, w.Body, tt.wantCode)
+			}
+			_, recorded := installStore.GetInstalled(resource.ID)
+			if tt.wantCode != http.StatusOK {
+				if !recorded {
+					t.Fatal("record removed by a refused uninstall")
+				}
+				if _, err := os.Stat(model); err != nil {
+					t.Fatalf("file removed by a refused uninstall: %v", err)
+				}
+				return
+			}
+
+			var response UninstallResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			// The previous version counts as removed as well
+			if len(response.RemovedPaths) != tt.wantRemoved+1 || len(response.MissingPaths) != tt.wantMissing {
+				t.Fatalf("removed %v, missing %v", response.RemovedPaths, response.MissingPaths)
+			}
+			if response.ReclaimedBytes != 12 {
+				t.Fatalf("reclaimed %d bytes, want 12", response.ReclaimedBytes)
+			}
+			for _, path := range []string{model, extracted, previous} {
+				if _, err := os.Lstat(path); !os.IsNotExist(err) {
+					t.Fatalf("%s still exists", path)
+				}
+			}
+			if recorded {
+				t.Fatal("record kept after uninstall")
+			}
+		})
+	}
+}
+
+func TestUninstallUnknownResource(t *testing.T) {
+	withInstallStore(t, "")
+	if w := callUninstall("nothing", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("DELETE = %d, want 404", w.Code)
+	}
+}
+
+// withProfileRoot moves the root of the active profile to a temporary directory
+func withProfileRoot(t *testing.T) (string, string) {
+	t.Helper()
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	config.SetProfileRoots(map[string]string{destinations.Active: root})
+	t.Cleanup(func() {
+		config.SetProfileRoots(nil)
+		config.Reload()
+	})
+	if _, err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return destinations.Active, root
+}
+
+// readLockFile returns the receipt IDs in the station.lock of dir
+func readLockFile(t *testing.T, dir string) []string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, store.LockFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock store.LockFile
+	if err := json.Unmarshal(content, &lock); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(lock.Resources))
+	for _, resource := range lock.Resources {
+		ids = append(ids, resource.ID)
+	}
+	return ids
+}
+
+func TestRecordInstallWritesTheReceiptAndTheProfileLockFile(t *testing.T) {
+	withInstallStore(t, "")
+	profile, root := withProfileRoot(t)
+
+	tests := []struct {
+		name     string
+		task     *InstallTask
+		wantID   string
+		wantLock []string // receipts in the station.lock of the profile root
+	}{
+		{
+			name:     "preset install",
+			task:     &InstallTask{ID: "task-1", PresetID: "base-model", Profile: profile, Path: filepath.Join(root, "models", "checkpoints")},
+			wantID:   "base-model@" + profile,
+			wantLock: []string{"base-model@" + profile},
+		},
+		{
+			name:     "install without profile under the root",
+			task:     &InstallTask{ID: "task-2", Path: filepath.Join(root, "models", "loras")},
+			wantID:   "task-2",
+			wantLock: []string{"base-model@" + profile, "task-2"},
+		},
+		{
+			name:     "install outside every profile",
+			task:     &InstallTask{ID: "task-3", Path: t.TempDir()},
+			wantID:   "task-3",
+			wantLock: []string{"base-model@" + profile, "task-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.MkdirAll(tt.task.Path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			tt.task.URL = "https://example.com/" + tt.task.ID + ".safetensors"
+			tt.task.OutputPath = filepath.Join(tt.task.Path, tt.task.ID+".safetensors")
+			if err := os.WriteFile(tt.task.OutputPath, []byte("weights"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			digest, err := downloader.FileSHA256(tt.task.OutputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.task.InstalledPaths = []string{tt.task.OutputPath}
+			tt.task.fileSHA256 = digest
+			tt.task.logs = newTaskLog()
+
+			recordInstall(tt.task)
+
+			receipt, exists := installStore.GetInstalled(tt.wantID)
+			if !exists {
+				t.Fatalf("no receipt %s", tt.wantID)
+			}
+			if receipt.Size != 7 || receipt.SHA256 != digest || receipt.TaskID != tt.task.ID {
+				t.Fatalf("receipt = %+v", receipt)
+			}
+			if _, err := os.Stat(filepath.Join(tt.task.Path, store.LockFileName)); !os.IsNotExist(err) {
+				t.Fatal("station.lock written into the destination directory")
+			}
+			lock := readLockFile(t, root)
+			if len(lock) != len(tt.wantLock) {
+				t.Fatalf("station.lock lists %v, want %v", lock, tt.wantLock)
+			}
+			for i := range lock {
+				if lock[i] != tt.wantLock[i] {
+					t.Fatalf("station.lock lists %v, want %v", lock, tt.wantLock)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/postinstall"
+)
+
+// resolvePostInstallSteps returns the post-install steps of a request:
+// the preset's own steps if it defines any, otherwise the steps of its type.
+// Presets from a subscription or saved through the API get no local-only
+// steps, not even from the type defaults; the names of the dropped steps are
+// returned so they can be logged.
+func resolvePostInstallSteps(req InstallRequest) ([]config.PostInstallStep, []string, error) {
+	resourceType := req.Type
+	remote := false
+
+	if req.PresetID != "" {
+		resources, err := config.GetPresetResources()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, resource := range resources {
+			if resource.ID != req.PresetID {
+				continue
+			}
+			remote = resource.FromRemoteSource()
+			if len(resource.PostInstall) > 0 {
+				steps, dropped := dropLocalOnlySteps(resource.PostInstall, remote)
+				return steps, dropped, nil
+			}
+			resourceType = resource.Type
+			break
+		}
+	}
+
+	if resourceType == "" {
+		return nil, nil, nil
+	}
+	steps, err := config.GetPostInstallSteps(resourceType)
+	if err != nil {
+		return nil, nil, err
+	}
+	steps, dropped := dropLocalOnlySteps(steps, remote)
+	return steps, dropped, nil
+}
+
+// dropLocalOnlySteps removes local-only steps when the preset is not trusted
+func dropLocalOnlySteps(steps []config.PostInstallStep, remote bool) ([]config.PostInstallStep, []string) {
+	if !remote {
+		return steps, nil
+	}
+	kept := make([]config.PostInstallStep, 0, len(steps))
+	dropped := make([]string, 0)
+	for _, step := range steps {
+		if config.IsLocalOnlyAction(step.Action) {
+			dropped = append(dropped, step.Name)
+			continue
+		}
+		kept = append(kept, step)
+	}
+	return kept, dropped
+}
+
+// runPostInstallSteps runs each post-install step as its own phase of the task.
+// It returns false when the task did not reach the end of the pipeline
+// (failed, cancelled or an illegal transition).
+func runPostInstallSteps(ctx context.Context, task *InstallTask) bool {
+	env := postinstall.Environment{
+		TaskID:     task.ID,
+		URL:        task.URL,
+		InstallDir: task.Path,
+		OutputPath: task.OutputPath,
+	}
+	logf := func(format string, args ...interface{}) {
+		task.logs.Add("hook", format, args...)
+	}
+
+	for _, step := range task.PostInstall {
+		phase := StatePostInstall
+		if step.Action == "extract" {
+			phase = StateExtracting
+		}
+		if !setTaskState(task, phase, step.Name) {
+			return false
+		}
+
+		task.logs.Add("hook", "Running step %q (%s)", step.Name, step.Action)
+		result, err := postinstall.Run(ctx, step, env, logf)
+		if err != nil {
+			if ctx.Err() != nil {
+				task.logs.Add("hook", "Step %q stopped: %v", step.Name, err)
+				return false
+			}
+			if step.ContinueOnError {
+				task.logs.Add("hook", "Step %q failed, continuing: %v", step.Name, err)
+				continue
+			}
+			failTask(task, fmt.Sprintf("Post-install step %q failed: %v", step.Name, err))
+			return false
+		}
+
+		if result.Skipped {
+			task.logs.Add("hook", "Step %q skipped", step.Name)
+			continue
+		}
+		task.logs.Add("hook", "Step %q finished", step.Name)
+
+		// Later steps run inside the directory an archive extracted to
+		if step.Action == "extract" && len(result.CreatedPaths) == 1 {
+			env.OutputPath = result.CreatedPaths[0]
+		}
+
+		installTasksMutex.Lock()
+		task.InstalledPaths = append(task.InstalledPaths, result.CreatedPaths...)
+		if step.Action == "extract" && step.RemoveArchive {
+			task.InstalledPaths = removePath(task.InstalledPaths, task.OutputPath)
+		}
+		installTasksMutex.Unlock()
+	}
+	return true
+}
+
+// removePath returns paths without the given path
+func removePath(paths []string, path string) []string {
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p != path {
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRemotePresetsGetNoLocalOnlySteps(t *testing.T) {
+	withInstallStore(t, "")
+	body := `{"id":"my-node","name":"My Node","type":"extension","url":"https://example.com/my-node.zip"}`
+	if w := servePresetRequest(http.MethodPost, "/preset-resources", body); w.Code != http.StatusCreated {
+		t.Fatalf("POST = %d %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name        string
+		req         InstallRequest
+		wantActions []string
+		wantDropped []string
+	}{
+		{
+			name:        "type defaults of a direct request",
+			req:         InstallRequest{Type: "extension"},
+			wantActions: []string{"extract", "pip_requirements", "python_script"},
+		},
+		{
+			name:        "type defaults of a custom preset",
+			req:         InstallRequest{Type: "extension", PresetID: "my-node"},
+			wantActions: []string{"extract", "pip_requirements"},
+			wantDropped: []string{"Run install script"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, dropped, err := resolvePostInstallSteps(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions := make([]string, 0, len(steps))
+			for _, step := range steps {
+				actions = append(actions, step.Action)
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("actions = %v, want %v", actions, tt.wantActions)
+			}
+			if len(dropped) != len(tt.wantDropped) || (len(dropped) > 0 && !reflect.DeepEqual(dropped, tt.wantDropped)) {
+				t.Errorf("dropped = %v, want %v", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+// bundleTargets are the presets of a bundle resolved in the active profile,
+// with their files checked
+type bundleTargets struct {
+	targets  []presetTarget
+	existing existingFiles
+	err      error
+}
+
+// resolveBundleTargets resolves and checks the presets of a bundle; it runs
+// without installTasksMutex. Listing bundles never hashes files: installed
+// presets are known from their receipts and other files are checked by size.
+func resolveBundleTargets(bundle config.PresetBundle) bundleTargets {
+	targets, err := resolvePresetTargets(nil, bundle.Resources...)
+	if err != nil {
+		return bundleTargets{err: err}
+	}
+	return bundleTargets{targets: targets, existing: checkExistingFiles(targets, false)}
+}
+
+// bundleStatusLocked reports the size of a bundle and how much of it is installed
+// in the active profile.
+// installTasksMutex must be held by the caller.
+func bundleStatusLocked(bundle config.PresetBundle, resolved bundleTargets) PresetBundleStatus {
+	status := PresetBundleStatus{PresetBundle: bundle, Presets: make([]BundlePresetStatus, 0)}
+
+	if resolved.err != nil {
+		status.Error = resolved.err.Error()
+		return status
+	}
+	plan := buildInstallPlanLocked(resolved.targets, resolved.existing)
+	for i, preset := range resolved.targets {
+		presetStatus := BundlePresetStatus{
+			PresetID:  preset.ID,
+			Name:      preset.Name,
+			SizeBytes: preset.Size.Bytes(),
+			Installed: plan.Steps[i].Action == planActionSkip,
+		}
+		if plan.Steps[i].Action == planActionInProgress {
+			presetStatus.TaskID = plan.Steps[i].TaskID
+		}
+
+		status.TotalBytes += presetStatus.SizeBytes
+		if presetStatus.Installed {
+			status.InstalledBytes += presetStatus.SizeBytes
+			status.InstalledCount++
+		}
+		status.Presets = append(status.Presets, presetStatus)
+	}
+	return status
+}
+
+// findPresetBundle returns the bundle with the given ID
+func findPresetBundle(bundleID string) (config.PresetBundle, bool, error) {
+	bundles, err := config.GetPresetBundles()
+	if err != nil {
+		return config.PresetBundle{}, false, err
+	}
+	for _, bundle := range bundles {
+		if bundle.ID == bundleID {
+			return bundle, true, nil
+		}
+	}
+	return config.PresetBundle{}, false, nil
+}
+
+// GetPresetBundlesHandler returns the preset bundles with their size and install status
+func GetPresetBundlesHandler(w http.ResponseWriter, r *http.Request) {
+	bundles, err := config.GetPresetBundles()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset bundles: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resolved := make([]bundleTargets, len(bundles))
+	for i, bundle := range bundles {
+		resolved[i] = resolveBundleTargets(bundle)
+	}
+
+	response := PresetBundlesResponse{Bundles: make([]PresetBundleStatus, 0, len(bundles))}
+	installTasksMutex.RLock()
+	for i, bundle := range bundles {
+		response.Bundles = append(response.Bundles, bundleStatusLocked(bundle, resolved[i]))
+	}
+	installTasksMutex.RUnlock()
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// InstallPresetBundleHandler queues every preset of a bundle that is not installed yet
+func InstallPresetBundleHandler(w http.ResponseWriter, r *http.Request) {
+	bundleID := r.PathValue("id")
+
+	var req PresetInstallRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	bundle, exists, err := findPresetBundle(bundleID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset bundles: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Bundle not found", http.StatusNotFound)
+		return
+	}
+
+	targets, err := resolvePresetTargets(req.Profiles, bundle.Resources...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing := checkExistingFiles(targets, true)
+
+	installTasksMutex.Lock()
+	plan, statusCode, err := installPresetsLocked(req.Priority, targets, existing)
+	installTasksMutex.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+	plan.BundleID = bundleID
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
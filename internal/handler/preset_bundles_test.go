@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/store"
+)
+
+func TestPresetBundlesSummary(t *testing.T) {
+	withInstallStore(t, "")
+	withInstallTasks(t)
+	withProfileRoot(t)
+	catalogDir := t.TempDir()
+	catalog := `
+resources:
+  - id: recorded
+    name: Recorded
+    type: lora
+    url: https://example.com/recorded.safetensors
+    size: {value: 1, unit: KB}
+  - id: on-disk
+    name: On disk
+    type: lora
+    url: https://example.com/on-disk.safetensors
+    sha256: 5d35ab8c4f9ae1ae5a8d9e1c4d2c30ff0b2e2ba2a5d1a39bf5eb0a8b3b7dbd8f
+    size: {value: 1, unit: KB}
+  - id: missing
+    name: Missing
+    type: lora
+    url: https://example.com/missing.safetensors
+    size: {value: 2, unit: KB}
+bundles:
+  - id: set
+    name: Set
+    resources: [recorded, on-disk, missing]
+`
+	if err := os.WriteFile(filepath.Join(catalogDir, "bundles.yaml"), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.SetCatalogDir(catalogDir)
+	t.Cleanup(func() {
+		config.SetCatalogDir("")
+		config.Reload()
+	})
+	if _, err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := installStore.PutInstalled(store.InstalledResource{ID: receiptID("recorded", destinations.Active), PresetID: "recorded", Profile: destinations.Active}); err != nil {
+		t.Fatal(err)
+	}
+	preset, _, err := findPreset("on-disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelDir, err := destinations.PresetPath(preset, destinations.Active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(modelDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	onDisk := filepath.Join(modelDir, "on-disk.safetensors")
+	if err := os.WriteFile(onDisk, make([]byte, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	GetPresetBundlesHandler(w, httptest.NewRequest(http.MethodGet, "/preset-bundles", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET = %d %s", w.Code, w.Body)
+	}
+	var response PresetBundlesResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	var set *PresetBundleStatus
+	for i := range response.Bundles {
+		if response.Bundles[i].ID == "set" {
+			set = &response.Bundles[i]
+		}
+	}
+	if set == nil {
+		t.Fatal("bundle missing from the list")
+	}
+
+	// The file on disk counts by its size; its hash is not computed for the list
+	if set.Error != "" || set.InstalledCount != 2 || set.TotalBytes != 4*1024 || set.InstalledBytes != 2*1024 {
+		t.Fatalf("bundle status = %+v", *set)
+	}
+	fileDigestsMutex.Lock()
+	_, hashed := fileDigests[onDisk]
+	fileDigestsMutex.Unlock()
+	if hashed {
+		t.Fatal("listing bundles hashed a file")
+	}
+}
@@ -0,0 +1,555 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// Install plan actions
+const (
+	planActionInstall    = "install"
+	planActionSkip       = "skip"
+	planActionInProgress = "in_progress"
+)
+
+// resolvePresetDependencies returns the presets and all of their dependencies in
+// install order (dependencies first), each preset once. Unknown dependencies
+// and cycles are errors.
+func resolvePresetDependencies(resources []config.PresetResource, presetIDs ...string) ([]config.PresetResource, error) {
+	byID := make(map[string]config.PresetResource, len(resources))
+	for _, resource := range resources {
+		byID[resource.ID] = resource
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	order := make([]config.PresetResource, 0)
+	path := make([]string, 0)
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			// Report the cycle starting from the first occurrence of id
+			start := 0
+			for i, p := range path {
+				if p == id {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), id)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		resource, exists := byID[id]
+		if !exists {
+			if len(path) == 0 {
+				return fmt.Errorf("preset %q not found", id)
+			}
+			return fmt.Errorf("preset %q depends on unknown preset %q", path[len(path)-1], id)
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+		for _, dependency := range resource.DependsOn {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+
+		order = append(order, resource)
+		return nil
+	}
+
+	for _, presetID := range presetIDs {
+		if err := visit(presetID); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// presetTarget is a preset resolved to its directory in one destination profile
+type presetTarget struct {
+	config.PresetResource
+	// Profile is empty for presets with an explicit destination_path
+	Profile string
+	Path    string
+}
+
+// key identifies the target among the targets of a plan
+func (t presetTarget) key() string {
+	return t.ID + "@" + t.Profile
+}
+
+// receiptID returns the ID of the install record of a preset in a profile
+func receiptID(presetID, profile string) string {
+	if profile == "" {
+		return presetID
+	}
+	return presetID + "@" + profile
+}
+
+// presetInstallRequest builds the install request of a preset target
+func presetInstallRequest(target presetTarget, priority int) InstallRequest {
+	return InstallRequest{
+		URL:               target.URL,
+		Name:              target.Name,
+		Path:              target.Path,
+		Type:              target.Type,
+		SHA256:            target.SHA256,
+		Priority:          priority,
+		PresetID:          target.ID,
+		Profile:           target.Profile,
+		AllowTypeMismatch: target.AllowTypeMismatch,
+	}
+}
+
+// presetTargets resolves presets to their directories in each profile.
+// Presets with an explicit destination_path are targeted once.
+func presetTargets(presets []config.PresetResource, requested []string) ([]presetTarget, error) {
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load installation destinations: %v", err)
+	}
+	// Blank entries, e.g. from "?profiles=a,,b", are ignored
+	profiles := make([]string, 0, len(requested))
+	for _, profile := range requested {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			profiles = append(profiles, profile)
+		}
+	}
+	if len(profiles) == 0 {
+		profiles = []string{destinations.Active}
+	}
+	for _, profile := range profiles {
+		if _, exists := destinations.Profile(profile); !exists {
+			return nil, fmt.Errorf("unknown profile %q", profile)
+		}
+	}
+
+	targets := make([]presetTarget, 0, len(presets)*len(profiles))
+	for i, profile := range profiles {
+		for _, preset := range presets {
+			if preset.DestinationType() == "" {
+				if i == 0 {
+					targets = append(targets, presetTarget{PresetResource: preset, Path: preset.DestinationPath})
+				}
+				continue
+			}
+			path, err := destinations.PresetPath(preset, profile)
+			if err != nil {
+				return nil, fmt.Errorf("preset %s: %v", preset.ID, err)
+			}
+			targets = append(targets, presetTarget{PresetResource: preset, Profile: profile, Path: path})
+		}
+	}
+	return targets, nil
+}
+
+// planPresetStepLocked decides what to do with a single preset target of a
+// plan; existing holds the result of checkExistingFiles for the target.
+// installTasksMutex must be held by the caller.
+func planPresetStepLocked(target presetTarget, existing existingFiles) InstallPlanStep {
+	step := InstallPlanStep{
+		PresetID:  target.ID,
+		Name:      target.Name,
+		Profile:   target.Profile,
+		Path:      target.Path,
+		Action:    planActionInstall,
+		DependsOn: target.DependsOn,
+	}
+
+	outputPath := downloader.GenerateOutputPath(target.Path, target.URL, target.Name)
+	// The latest finished task of the target decides whether its file can be trusted
+	var latest *InstallTask
+	for _, task := range installTasks {
+		samePreset := task.PresetID == target.ID && task.Profile == target.Profile
+		if !samePreset && task.OutputPath != outputPath && (task.URL != target.URL || task.Path != target.Path) {
+			continue
+		}
+		if !task.Status.IsTerminal() {
+			step.Action = planActionInProgress
+			step.Reason = fmt.Sprintf("task is %s", task.Status)
+			step.TaskID = task.ID
+			return step
+		}
+		if latest == nil || task.seq > latest.seq {
+			latest = task
+		}
+	}
+	if latest != nil {
+		switch {
+		case latest.Status == StateCompleted && latest.PresetID == target.ID && latest.Profile == target.Profile:
+			step.Action = planActionSkip
+			step.Reason = "already installed"
+			step.TaskID = latest.ID
+			return step
+		case (latest.Status == StateFailed || latest.Status == StateCancelled) && latest.OutputPath == outputPath:
+			// Downloads write to the output path, so the file may be incomplete
+			step.Reason = fmt.Sprintf("last task %s", latest.Status)
+			step.TaskID = latest.ID
+			return step
+		}
+	}
+
+	if _, installed := installStore.GetInstalled(receiptID(target.ID, target.Profile)); installed {
+		step.Action = planActionSkip
+		step.Reason = "already installed"
+		return step
+	}
+	if existing[target.key()] {
+		step.Action = planActionSkip
+		step.Reason = "already installed"
+	}
+	return step
+}
+
+// existingFiles records, by target key, whether the output path of the target holds the file of its preset
+type existingFiles map[string]bool
+
+// checkExistingFiles checks the output paths of targets without an install
+// record against their presets. It runs without installTasksMutex because
+// hashing a large model takes a while; with verify false no file is hashed
+// (see presetFileMatches).
+func checkExistingFiles(targets []presetTarget, verify bool) existingFiles {
+	existing := make(existingFiles, len(targets))
+	for _, target := range targets {
+		if _, installed := installStore.GetInstalled(receiptID(target.ID, target.Profile)); installed {
+			continue
+		}
+		outputPath := downloader.GenerateOutputPath(target.Path, target.URL, target.Name)
+		existing[target.key()] = presetFileMatches(target.PresetResource, outputPath, verify)
+	}
+	return existing
+}
+
+// presetSizeTolerance is how far a file may be from the catalog size of a
+// preset, which is rounded and often in decimal rather than binary units
+const presetSizeTolerance = 0.1
+
+// presetFileMatches reports whether an existing file at path is the file of a
+// preset: its sha256 when the preset gives one, otherwise its size within
+// presetSizeTolerance of the catalog size. Without either, and for git
+// clones, the file only has to exist. Without verify the sha256 is only
+// compared when it is cached and the size is used otherwise.
+func presetFileMatches(preset config.PresetResource, path string, verify bool) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if downloader.IsGitURL(preset.URL) {
+		return true
+	}
+	if info.IsDir() {
+		return false
+	}
+
+	if preset.SHA256 != "" {
+		if verify {
+			digest, err := cachedFileSHA256(path, info)
+			if err != nil {
+				logger.Warn("Could not verify existing file %s: %v", path, err)
+				return false
+			}
+			return strings.EqualFold(digest, preset.SHA256)
+		}
+		if digest, cached := lookupFileSHA256(path, info); cached {
+			return strings.EqualFold(digest, preset.SHA256)
+		}
+	}
+	if expected := preset.Size.Bytes(); expected > 0 {
+		return math.Abs(float64(info.Size()-expected)) <= float64(expected)*presetSizeTolerance
+	}
+	return true
+}
+
+// fileDigest is the sha256 of a file as of its size and modification time
+type fileDigest struct {
+	size    int64
+	modTime time.Time
+	sha256  string
+}
+
+var (
+	fileDigestsMutex sync.Mutex
+	fileDigests      = make(map[string]fileDigest)
+)
+
+// cachedFileSHA256 returns the sha256 of a file, hashing it again only when
+// its size or modification time changed since the last call
+func cachedFileSHA256(path string, info os.FileInfo) (string, error) {
+	if digest, cached := lookupFileSHA256(path, info); cached {
+		return digest, nil
+	}
+	digest, err := downloader.FileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	fileDigestsMutex.Lock()
+	fileDigests[path] = fileDigest{size: info.Size(), modTime: info.ModTime(), sha256: digest}
+	fileDigestsMutex.Unlock()
+	return digest, nil
+}
+
+// lookupFileSHA256 returns the cached sha256 of a file if it has not changed since
+func lookupFileSHA256(path string, info os.FileInfo) (string, bool) {
+	fileDigestsMutex.Lock()
+	defer fileDigestsMutex.Unlock()
+
+	cached, exists := fileDigests[path]
+	if !exists || cached.size != info.Size() || !cached.modTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	return cached.sha256, true
+}
+
+// resolvePresetTargets resolves the dependencies of presets and targets each
+// of them in every profile, or in the active profile if none are given
+func resolvePresetTargets(profiles []string, presetIDs ...string) ([]presetTarget, error) {
+	resources, err := config.GetPresetResources()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load preset resources: %v", err)
+	}
+
+	presets, err := resolvePresetDependencies(resources, presetIDs...)
+	if err != nil {
+		return nil, err
+	}
+	return presetTargets(presets, profiles)
+}
+
+// buildInstallPlanLocked plans each target; existing holds the result of
+// checkExistingFiles for the targets.
+// installTasksMutex must be held by the caller.
+func buildInstallPlanLocked(targets []presetTarget, existing existingFiles) InstallPlanResponse {
+	plan := InstallPlanResponse{Steps: make([]InstallPlanStep, 0, len(targets))}
+	for _, target := range targets {
+		plan.Steps = append(plan.Steps, planPresetStepLocked(target, existing))
+	}
+	return plan
+}
+
+// presetExists reports whether a preset with the given ID is defined
+func presetExists(presetID string) (bool, error) {
+	resources, err := config.GetPresetResources()
+	if err != nil {
+		return false, err
+	}
+	for _, resource := range resources {
+		if resource.ID == presetID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetPresetInstallPlanHandler shows what installing a preset would do without
+// starting anything; ?profiles=a,b plans the install for several profiles
+func GetPresetInstallPlanHandler(w http.ResponseWriter, r *http.Request) {
+	presetID := r.PathValue("id")
+
+	exists, err := presetExists(presetID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset resources: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	var profiles []string
+	if param := r.URL.Query().Get("profiles"); param != "" {
+		profiles = strings.Split(param, ",")
+	}
+
+	targets, err := resolvePresetTargets(profiles, presetID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing := checkExistingFiles(targets, true)
+
+	installTasksMutex.RLock()
+	plan := buildInstallPlanLocked(targets, existing)
+	installTasksMutex.RUnlock()
+	plan.PresetID = presetID
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// InstallPresetHandler installs a preset together with its missing dependencies.
+// Dependencies are queued first and each task waits for the tasks of its dependencies.
+func InstallPresetHandler(w http.ResponseWriter, r *http.Request) {
+	presetID := r.PathValue("id")
+
+	var req PresetInstallRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	exists, err := presetExists(presetID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load preset resources: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	targets, err := resolvePresetTargets(req.Profiles, presetID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing := checkExistingFiles(targets, true)
+
+	installTasksMutex.Lock()
+	plan, statusCode, err := installPresetsLocked(req.Priority, targets, existing)
+	installTasksMutex.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+	plan.PresetID = presetID
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// installPresetsLocked queues the preset targets that are not installed yet,
+// as resolved by resolvePresetTargets and checked by checkExistingFiles.
+// Each task waits for the tasks of its dependencies in the same profile.
+// Nothing is queued on error; the returned status code is the HTTP status to
+// respond with.
+// installTasksMutex must be held by the caller.
+func installPresetsLocked(priority int, targets []presetTarget, existing existingFiles) (InstallPlanResponse, int, error) {
+	plan := buildInstallPlanLocked(targets, existing)
+
+	// Build every task before queuing any so that an invalid preset queues nothing
+	tasks := make([]*InstallTask, len(targets))
+	for i, target := range targets {
+		if plan.Steps[i].Action != planActionInstall {
+			continue
+		}
+		task, statusCode, err := newInstallTask(presetInstallRequest(target, priority))
+		if err != nil {
+			return plan, statusCode, fmt.Errorf("Preset %s: %v", target.ID, err)
+		}
+		tasks[i] = task
+		plan.Steps[i].TaskID = task.ID
+	}
+
+	taskIDs := make(map[string]string, len(plan.Steps))
+	for i, step := range plan.Steps {
+		if step.Action != planActionSkip {
+			taskIDs[targets[i].key()] = step.TaskID
+		}
+	}
+
+	for i, task := range tasks {
+		if task == nil {
+			continue
+		}
+		for _, dependency := range targets[i].DependsOn {
+			// A dependency with an explicit destination_path is shared by every profile
+			for _, key := range []string{dependency + "@" + targets[i].Profile, dependency + "@"} {
+				if taskID, ok := taskIDs[key]; ok {
+					task.DependsOnTasks = append(task.DependsOnTasks, taskID)
+					break
+				}
+			}
+		}
+		task.logs.Add("installer", "Created %s task %s -> %s (priority %d)", task.Status, task.URL, task.OutputPath, task.Priority)
+		if len(task.DependsOnTasks) > 0 {
+			task.logs.Add("installer", "Waiting for tasks: %s", strings.Join(task.DependsOnTasks, ", "))
+		}
+		enqueueTaskLocked(task)
+	}
+	return plan, http.StatusAccepted, nil
+}
+
+// dependenciesReadyLocked reports whether all tasks a task depends on have
+// completed. A dependency that failed or was cancelled blocks the task, and so
+// does a dependency task that is gone (deleted, pruned or finished before a
+// restart) unless the presets the task depends on are installed. The returned
+// message explains why the task is blocked.
+// installTasksMutex must be held by the caller.
+func (t *InstallTask) dependenciesReadyLocked() (bool, string) {
+	ready := true
+	for _, id := range t.DependsOnTasks {
+		dependency, exists := installTasks[id]
+		if !exists {
+			if missing := t.missingDependencyPresets(); missing != "" {
+				return false, fmt.Sprintf("Dependency task %s no longer exists and %s", id, missing)
+			}
+			continue
+		}
+		if dependency.Status == StateCompleted {
+			continue
+		}
+		if dependency.Status == StateFailed || dependency.Status == StateCancelled {
+			return false, fmt.Sprintf("Dependency %s (%s) %s", dependency.ID, dependency.Name, dependency.Status)
+		}
+		ready = false
+	}
+	return ready, ""
+}
+
+// missingDependencyPresets describes the dependencies of the task's preset
+// that have no install record in its profile, or returns "" if all have one
+func (t *InstallTask) missingDependencyPresets() string {
+	resources, err := config.GetPresetResources()
+	if err != nil {
+		return fmt.Sprintf("the catalog could not be loaded: %v", err)
+	}
+	for _, resource := range resources {
+		if resource.ID != t.PresetID {
+			continue
+		}
+		missing := make([]string, 0)
+		for _, dependency := range resource.DependsOn {
+			_, inProfile := installStore.GetInstalled(receiptID(dependency, t.Profile))
+			// A dependency with an explicit destination_path is shared by every profile
+			_, shared := installStore.GetInstalled(receiptID(dependency, ""))
+			if !inProfile && !shared {
+				missing = append(missing, dependency)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Sprintf("%s is not installed", strings.Join(missing, ", "))
+		}
+		return ""
+	}
+	return fmt.Sprintf("preset %s is no longer in the catalog", t.PresetID)
+}
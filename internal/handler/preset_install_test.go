@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/internal/store"
+)
+
+// profilePreset returns a catalog preset installed through the destination profile
+func profilePreset(t *testing.T) config.PresetResource {
+	t.Helper()
+	resources, err := config.GetPresetResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, preset := range resources {
+		if preset.DestinationType() != "" && len(preset.DependsOn) == 0 {
+			return preset
+		}
+	}
+	t.Fatal("no profile-based preset in the catalog")
+	return config.PresetResource{}
+}
+
+func TestLegacyReceiptsMoveToTheActiveProfile(t *testing.T) {
+	withInstallStore(t, "")
+	preset := profilePreset(t)
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := store.InstalledResource{ID: preset.ID, PresetID: preset.ID, Name: preset.Name}
+	adhoc := store.InstalledResource{ID: "task-1", Name: "ad-hoc"}
+	for _, resource := range []store.InstalledResource{legacy, adhoc} {
+		if err := installStore.PutInstalled(resource); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	installTasksMutex.Lock()
+	migrateLegacyReceiptsLocked()
+	installTasksMutex.Unlock()
+
+	moved, exists := installStore.GetInstalled(receiptID(preset.ID, destinations.Active))
+	if !exists || moved.Profile != destinations.Active {
+		t.Fatalf("receipt not moved to profile %s: %+v", destinations.Active, moved)
+	}
+	if _, exists := installStore.GetInstalled(preset.ID); exists {
+		t.Fatal("receipt under the bare preset ID was kept")
+	}
+	if _, exists := installStore.GetInstalled(adhoc.ID); !exists {
+		t.Fatal("receipt of an ad-hoc install was moved")
+	}
+
+	installTasksMutex.RLock()
+	step := planPresetStepLocked(presetTarget{PresetResource: preset, Profile: destinations.Active, Path: t.TempDir()}, nil)
+	installTasksMutex.RUnlock()
+	if step.Action != planActionSkip {
+		t.Fatalf("plan action = %s, want %s", step.Action, planActionSkip)
+	}
+}
+
+func TestInstallPlanProfilesParameter(t *testing.T) {
+	withInstallStore(t, "")
+	preset := profilePreset(t)
+	router := http.NewServeMux()
+	router.HandleFunc("GET /preset-resources/{id}/install-plan", GetPresetInstallPlanHandler)
+
+	tests := []struct {
+		name     string
+		profiles string
+		want     int
+	}{
+		{name: "blank entries are ignored", profiles: " , ", want: http.StatusOK},
+		{name: "entries are trimmed", profiles: " comfyui ,, a1111", want: http.StatusOK},
+		{name: "unknown profile", profiles: "comfyui,unknown", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/preset-resources/" + url.PathEscape(preset.ID) + "/install-plan?profiles=" + url.QueryEscape(tt.profiles)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			if w.Code != tt.want {
+				t.Fatalf("GET %s = %d %s, want %d", target, w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePresetDependencies(t *testing.T) {
+	resources := []config.PresetResource{
+		{ID: "base"},
+		{ID: "vae"},
+		{ID: "lora", DependsOn: []string{"base"}},
+		{ID: "workflow", DependsOn: []string{"lora", "vae", "base"}},
+		{ID: "loop-a", DependsOn: []string{"loop-b"}},
+		{ID: "loop-b", DependsOn: []string{"loop-c"}},
+		{ID: "loop-c", DependsOn: []string{"loop-b"}},
+		{ID: "self", DependsOn: []string{"self"}},
+		{ID: "broken", DependsOn: []string{"missing"}},
+	}
+
+	tests := []struct {
+		name    string
+		ids     []string
+		want    string // install order, or the error message
+		wantErr bool
+	}{
+		{name: "no dependencies", ids: []string{"base"}, want: "base"},
+		{name: "dependencies first", ids: []string{"lora"}, want: "base lora"},
+		{name: "shared dependency once", ids: []string{"workflow"}, want: "base lora vae workflow"},
+		{name: "several presets", ids: []string{"vae", "lora", "base"}, want: "vae base lora"},
+		{name: "cycle", ids: []string{"loop-a"}, want: "dependency cycle: loop-b -> loop-c -> loop-b", wantErr: true},
+		{name: "self dependency", ids: []string{"self"}, want: "dependency cycle: self -> self", wantErr: true},
+		{name: "unknown dependency", ids: []string{"broken"}, want: `preset "broken" depends on unknown preset "missing"`, wantErr: true},
+		{name: "unknown preset", ids: []string{"nothing"}, want: `preset "nothing" not found`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := resolvePresetDependencies(resources, tt.ids...)
+			if tt.wantErr {
+				if err == nil || err.Error() != tt.want {
+					t.Fatalf("error = %v, want %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, len(order))
+			for i, preset := range order {
+				ids[i] = preset.ID
+			}
+			if got := strings.Join(ids, " "); got != tt.want {
+				t.Fatalf("order = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanPresetStepTrustsExistingFilesOnlyWhenTheyMatch(t *testing.T) {
+	withInstallStore(t, "")
+	dir := t.TempDir()
+	content := []byte("model weights")
+	const digest = "5d35ab8c4f9ae1ae5a8d9e1c4d2c30ff0b2e2ba2a5d1a39bf5eb0a8b3b7dbd8f"
+
+	tests := []struct {
+		name   string
+		preset config.PresetResource
+		task   *InstallTask // latest task of the output path, if any
+		want   string
+	}{
+		{name: "file without size or hash", want: planActionSkip},
+		{name: "matching size", preset: config.PresetResource{Size: config.SizeInfo{Value: 13, Unit: "B"}}, want: planActionSkip},
+		{name: "size within tolerance", preset: config.PresetResource{Size: config.SizeInfo{Value: 14, Unit: "B"}}, want: planActionSkip},
+		{name: "partial file", preset: config.PresetResource{Size: config.SizeInfo{Value: 1, Unit: "KB"}}, want: planActionInstall},
+		{name: "other hash", preset: config.PresetResource{SHA256: digest}, want: planActionInstall},
+		{name: "failed task", task: &InstallTask{Status: StateFailed}, want: planActionInstall},
+		{name: "cancelled task", task: &InstallTask{Status: StateCancelled}, want: planActionInstall},
+		{name: "paused task", task: &InstallTask{Status: StatePaused}, want: planActionInProgress},
+		{name: "completed task of another install", task: &InstallTask{Status: StateCompleted}, want: planActionSkip},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preset := tt.preset
+			preset.ID = "model"
+			preset.Name = "model"
+			preset.URL = "https://example.com/" + strings.Repeat("m", i+1) + ".safetensors"
+			target := presetTarget{PresetResource: preset, Path: dir}
+			outputPath := filepath.Join(dir, filepath.Base(preset.URL))
+			if err := os.WriteFile(outputPath, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			existing := checkExistingFiles([]presetTarget{target}, true)
+
+			installTasksMutex.Lock()
+			saved := installTasks
+			installTasks = make(map[string]*InstallTask)
+			if tt.task != nil {
+				tt.task.ID = "task"
+				tt.task.OutputPath = outputPath
+				installTasks[tt.task.ID] = tt.task
+			}
+			step := planPresetStepLocked(target, existing)
+			installTasks = saved
+			installTasksMutex.Unlock()
+
+			if step.Action != tt.want {
+				t.Fatalf("action = %s (%s), want %s", step.Action, step.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetFileMatchesHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.safetensors")
+	if err := os.WriteFile(path, []byte("model weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := downloader.FileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preset := config.PresetResource{URL: "https://example.com/model.safetensors", SHA256: strings.ToUpper(digest)}
+	if !presetFileMatches(preset, path, true) {
+		t.Fatal("file with the preset hash does not match")
+	}
+	if err := os.WriteFile(path, []byte("other model weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if presetFileMatches(preset, path, true) {
+		t.Fatal("changed file still matches the cached hash")
+	}
+}
+
+func TestPresetFileMatchesWithoutVerifyDoesNotHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.safetensors")
+	if err := os.WriteFile(path, []byte("model weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const otherDigest = "5d35ab8c4f9ae1ae5a8d9e1c4d2c30ff0b2e2ba2a5d1a39bf5eb0a8b3b7dbd8f"
+	preset := config.PresetResource{URL: "https://example.com/model.safetensors", SHA256: otherDigest, Size: config.SizeInfo{Value: 13, Unit: "B"}}
+
+	// Without a cached digest the size decides
+	if !presetFileMatches(preset, path, false) {
+		t.Fatal("file of the catalog size does not match without verification")
+	}
+	fileDigestsMutex.Lock()
+	_, hashed := fileDigests[path]
+	fileDigestsMutex.Unlock()
+	if hashed {
+		t.Fatal("file was hashed without verification")
+	}
+
+	// Once hashed, the cached digest is used
+	if presetFileMatches(preset, path, true) {
+		t.Fatal("file with another hash matches")
+	}
+	if presetFileMatches(preset, path, false) {
+		t.Fatal("cached digest was ignored")
+	}
+}
+
+func TestDependentTaskAfterItsDependencyTaskIsGone(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed bool // the dependency has an install record
+		wantReady bool // otherwise the dependent task fails
+	}{
+		{name: "dependency installed", installed: true, wantReady: true},
+		{name: "dependency not installed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.db")
+			withInstallStore(t, path)
+			withInstallTasks(t)
+			destinations, err := config.GetDestinations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, body := range []string{
+				`{"id":"base-model","name":"Base","type":"checkpoint","url":"https://example.com/base.safetensors"}`,
+				`{"id":"my-lora","name":"LoRA","type":"lora","url":"https://example.com/lora.safetensors","depends_on":["base-model"]}`,
+			} {
+				if w := servePresetRequest(http.MethodPost, "/preset-resources", body); w.Code != http.StatusCreated {
+					t.Fatalf("POST = %d %s", w.Code, w.Body)
+				}
+			}
+
+			// The dependency finished and was forgotten before the server stopped
+			installTasksMutex.Lock()
+			dependent := &InstallTask{ID: "dependent", PresetID: "my-lora", Profile: destinations.Active, Status: StateQueued, DependsOnTasks: []string{"dependency"}, seq: 2, logs: newTaskLog()}
+			installTasks[dependent.ID] = dependent
+			persistTaskLocked(dependent)
+			installTasksMutex.Unlock()
+			if tt.installed {
+				if err := installStore.PutInstalled(store.InstalledResource{ID: receiptID("base-model", destinations.Active), PresetID: "base-model", Profile: destinations.Active}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Restart: reopen the store and restore the tasks
+			reopened, err := store.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			installStore = reopened
+			installTasksMutex.Lock()
+			defer installTasksMutex.Unlock()
+			installTasks = make(map[string]*InstallTask)
+			restoreTasksLocked()
+			restored, exists := installTasks["dependent"]
+			if !exists {
+				t.Fatal("dependent task was not restored")
+			}
+
+			if ready := nextReadyTaskLocked() == restored; ready != tt.wantReady {
+				t.Fatalf("ready = %v, want %v (status %s, error: %s)", ready, tt.wantReady, restored.Status, restored.Error)
+			}
+			if !tt.wantReady && restored.Status != StateFailed {
+				t.Fatalf("dependent task is %s, want %s", restored.Status, StateFailed)
+			}
+		})
+	}
+}
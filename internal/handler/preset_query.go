@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+// Maximum page size of the preset list
+const maxPresetPageSize = 500
+
+// sizeParamRegex matches a size such as 7.5GB, 512MB or 1048576
+var sizeParamRegex = regexp.MustCompile(`(?i)^\s*([0-9]+(?:\.[0-9]+)?)\s*(B|KB|MB|GB)?\s*$`)
+
+// presetQuery holds the parsed filter, sort and pagination parameters of the preset list
+type presetQuery struct {
+	terms      []string
+	tags       []string
+	allTags    bool
+	presetType string
+	license    string
+	maxBytes   int64 // 0 disables the size filter
+	installed  *bool
+	sortField  string // empty keeps the catalog order
+	descending bool
+	limit      int // 0 returns every remaining preset
+	// after is the last preset of the previous page, with only the sort field and ID set
+	after *config.PresetResource
+}
+
+// parsePresetQuery parses the preset list query parameters:
+//   - q: case-insensitive words that must all appear in the name, description or author
+//   - tags: comma separated tags; tagMatch=all requires every tag instead of any
+//   - type, license: exact match, case-insensitive
+//   - maxSize: largest size, in bytes or with a B/KB/MB/GB unit
+//   - installed: true or false
+//   - sort: name, size, author or type; prefix with "-" for descending
+//   - limit, cursor: page size and the nextCursor of the previous page
+func parsePresetQuery(values url.Values) (*presetQuery, error) {
+	query := &presetQuery{
+		terms:      strings.Fields(strings.ToLower(values.Get("q"))),
+		presetType: values.Get("type"),
+		license:    values.Get("license"),
+	}
+
+	if param := values.Get("tags"); param != "" {
+		for _, tag := range strings.Split(param, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				query.tags = append(query.tags, strings.ToLower(tag))
+			}
+		}
+	}
+
+	switch values.Get("tagMatch") {
+	case "", "any":
+	case "all":
+		query.allTags = true
+	default:
+		return nil, fmt.Errorf("tagMatch must be any or all")
+	}
+
+	if param := values.Get("maxSize"); param != "" {
+		match := sizeParamRegex.FindStringSubmatch(param)
+		if match == nil {
+			return nil, fmt.Errorf("maxSize must be a size in bytes or with a B, KB, MB or GB unit")
+		}
+		value, _ := strconv.ParseFloat(match[1], 64)
+		unit := match[2]
+		if unit == "" {
+			unit = "B"
+		}
+		query.maxBytes = config.SizeInfo{Value: value, Unit: unit}.Bytes()
+		if query.maxBytes <= 0 {
+			return nil, fmt.Errorf("maxSize must be greater than zero")
+		}
+	}
+
+	if param := values.Get("installed"); param != "" {
+		installed, err := strconv.ParseBool(param)
+		if err != nil {
+			return nil, fmt.Errorf("installed must be true or false")
+		}
+		query.installed = &installed
+	}
+
+	if param := values.Get("sort"); param != "" {
+		query.descending = strings.HasPrefix(param, "-")
+		query.sortField = strings.TrimPrefix(param, "-")
+		switch query.sortField {
+		case "name", "size", "author", "type":
+		default:
+			return nil, fmt.Errorf("sort must be one of: name, size, author, type")
+		}
+	}
+
+	if param := values.Get("limit"); param != "" {
+		limit, err := strconv.Atoi(param)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		query.limit = min(limit, maxPresetPageSize)
+	}
+
+	if param := values.Get("cursor"); param != "" {
+		cursor, err := decodeListCursor(param)
+		if err != nil || cursor.Sort != query.sortParam() {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if query.after, err = query.cursorPreset(cursor); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	return query, nil
+}
+
+// sortParam returns the sort parameter the query was made with
+func (q *presetQuery) sortParam() string {
+	if q.descending {
+		return "-" + q.sortField
+	}
+	return q.sortField
+}
+
+// sortKey returns the value of the sort field of a preset as stored in a cursor
+func (q *presetQuery) sortKey(preset *config.PresetResource) string {
+	switch q.sortField {
+	case "size":
+		return strconv.FormatInt(preset.Size.Bytes(), 10)
+	case "author":
+		return preset.Author
+	case "type":
+		return preset.Type
+	case "name":
+		return preset.Name
+	default:
+		return "" // catalog order is kept by the position of the ID
+	}
+}
+
+// cursorPreset rebuilds the position of a cursor as a preset that sorts like
+// the last preset of the previous page
+func (q *presetQuery) cursorPreset(cursor listCursor) (*config.PresetResource, error) {
+	preset := &config.PresetResource{ID: cursor.ID}
+	switch q.sortField {
+	case "size":
+		size, err := strconv.ParseInt(cursor.Key, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		preset.Size = config.SizeInfo{Value: float64(size), Unit: "B"}
+	case "author":
+		preset.Author = cursor.Key
+	case "type":
+		preset.Type = cursor.Key
+	case "name":
+		preset.Name = cursor.Key
+	}
+	return preset, nil
+}
+
+// matches reports whether a preset passes the query filters other than installed
+func (q *presetQuery) matches(preset config.PresetResource) bool {
+	if q.presetType != "" && !strings.EqualFold(preset.Type, q.presetType) {
+		return false
+	}
+	if q.license != "" && !strings.EqualFold(preset.License, q.license) {
+		return false
+	}
+	if q.maxBytes > 0 {
+		size := preset.Size.Bytes()
+		// A preset of unknown size cannot be shown to fit
+		if size <= 0 || size > q.maxBytes {
+			return false
+		}
+	}
+
+	text := strings.ToLower(preset.Name + "\n" + preset.Description + "\n" + preset.Author)
+	for _, term := range q.terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+
+	if len(q.tags) > 0 {
+		presetTags := make(map[string]bool, len(preset.Tags))
+		for _, tag := range preset.Tags {
+			presetTags[strings.ToLower(tag)] = true
+		}
+		found := 0
+		for _, tag := range q.tags {
+			if presetTags[tag] {
+				found++
+			}
+		}
+		if found == 0 || (q.allTags && found < len(q.tags)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortPresets orders presets by the query sort field; ties are broken by ID
+// so the order is deterministic across requests
+func (q *presetQuery) sortPresets(presets []config.PresetResource) {
+	if q.sortField == "" {
+		return
+	}
+
+	sort.SliceStable(presets, func(i, j int) bool {
+		return q.less(&presets[i], &presets[j])
+	})
+}
+
+// less reports whether preset a comes before preset b in the query sort order
+func (q *presetQuery) less(a, b *config.PresetResource) bool {
+	if q.descending {
+		a, b = b, a
+	}
+
+	switch q.sortField {
+	case "size":
+		if a.Size.Bytes() != b.Size.Bytes() {
+			return a.Size.Bytes() < b.Size.Bytes()
+		}
+	case "author":
+		if !strings.EqualFold(a.Author, b.Author) {
+			return strings.ToLower(a.Author) < strings.ToLower(b.Author)
+		}
+	case "type":
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+	default:
+		if !strings.EqualFold(a.Name, b.Name) {
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+	}
+	return a.ID < b.ID
+}
+
+// paginate returns the page after the cursor and the cursor of the next page,
+// if any. Pages continue after the last preset of the previous page; in
+// catalog order that is its position in the catalog, and a cursor whose preset
+// has left the catalog is rejected.
+func (q *presetQuery) paginate(presets, catalog []config.PresetResource) ([]config.PresetResource, string, error) {
+	start := 0
+	if q.after != nil && q.sortField == "" {
+		positions := make(map[string]int, len(catalog))
+		for i, preset := range catalog {
+			positions[preset.ID] = i
+		}
+		after, exists := positions[q.after.ID]
+		if !exists {
+			return nil, "", fmt.Errorf("invalid cursor: preset %s is no longer in the catalog", q.after.ID)
+		}
+		start = sort.Search(len(presets), func(i int) bool { return positions[presets[i].ID] > after })
+	} else if q.after != nil {
+		start = sort.Search(len(presets), func(i int) bool { return q.less(q.after, &presets[i]) })
+	}
+
+	end := len(presets)
+	if q.limit > 0 {
+		end = min(start+q.limit, len(presets))
+	}
+	nextCursor := ""
+	if end < len(presets) && end > start {
+		last := &presets[end-1]
+		nextCursor = encodeListCursor(listCursor{Sort: q.sortParam(), Key: q.sortKey(last), ID: last.ID})
+	}
+	return presets[start:end], nextCursor, nil
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+func testPresets() []config.PresetResource {
+	return []config.PresetResource{
+		{ID: "p-c", Name: "Charlie", Type: "lora", Author: "bob", Size: config.SizeInfo{Value: 2, Unit: "GB"}},
+		{ID: "p-a", Name: "alpha", Type: "Checkpoint", Author: "Alice", Size: config.SizeInfo{Value: 512, Unit: "MB"}},
+		{ID: "p-d", Name: "delta", Type: "lora", Author: "alice", Size: config.SizeInfo{Value: 2, Unit: "GB"}},
+		{ID: "p-b", Name: "Bravo", Type: "vae", Author: "carol", Size: config.SizeInfo{Value: 300, Unit: "MB"}},
+		{ID: "p-e", Name: "echo", Type: "lora", Author: "Bob", Size: config.SizeInfo{Value: 1, Unit: "KB"}},
+	}
+}
+
+// pagePresets collects every page of a preset list query, applying change to
+// the catalog between the first and the second page
+func pagePresets(t *testing.T, params url.Values, catalog []config.PresetResource, change func([]config.PresetResource) []config.PresetResource) []string {
+	t.Helper()
+	seen := make([]string, 0)
+	for page := 0; page < 20; page++ {
+		query, err := parsePresetQuery(params)
+		if err != nil {
+			t.Fatalf("parsePresetQuery(%v) error = %v", params, err)
+		}
+		matched := make([]config.PresetResource, 0, len(catalog))
+		for _, preset := range catalog {
+			if query.matches(preset) {
+				matched = append(matched, preset)
+			}
+		}
+		query.sortPresets(matched)
+		result, next, err := query.paginate(matched, catalog)
+		if err != nil {
+			t.Fatalf("paginate() error = %v", err)
+		}
+		for _, preset := range result {
+			seen = append(seen, preset.ID)
+		}
+		if next == "" {
+			return seen
+		}
+		if page == 0 && change != nil {
+			catalog = change(catalog)
+		}
+		params.Set("cursor", next)
+	}
+	t.Fatal("pagination did not end")
+	return nil
+}
+
+func TestPresetListCursorPaging(t *testing.T) {
+	tests := []struct {
+		name   string
+		params url.Values
+		change func([]config.PresetResource) []config.PresetResource
+		want   []string
+	}{
+		{
+			name: "catalog order",
+			want: []string{"p-c", "p-a", "p-d", "p-b", "p-e"},
+		},
+		{
+			name:   "name ignores case",
+			params: url.Values{"sort": {"name"}},
+			want:   []string{"p-a", "p-b", "p-c", "p-d", "p-e"},
+		},
+		{
+			name:   "size with ties broken by id",
+			params: url.Values{"sort": {"size"}},
+			want:   []string{"p-e", "p-b", "p-a", "p-c", "p-d"},
+		},
+		{
+			name:   "descending author",
+			params: url.Values{"sort": {"-author"}},
+			want:   []string{"p-b", "p-e", "p-c", "p-d", "p-a"},
+		},
+		{
+			name:   "type filter ignores case",
+			params: url.Values{"type": {"LORA"}},
+			want:   []string{"p-c", "p-d", "p-e"},
+		},
+		{
+			name: "preset removed from an earlier page in catalog order",
+			change: func(catalog []config.PresetResource) []config.PresetResource {
+				return catalog[1:]
+			},
+			want: []string{"p-c", "p-a", "p-d", "p-b", "p-e"},
+		},
+		{
+			name:   "preset added before the cursor",
+			params: url.Values{"sort": {"name"}},
+			change: func(catalog []config.PresetResource) []config.PresetResource {
+				return append(catalog, config.PresetResource{ID: "p-0", Name: "aardvark"})
+			},
+			want: []string{"p-a", "p-b", "p-c", "p-d", "p-e"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := url.Values{"limit": {"2"}}
+			for key, values := range tt.params {
+				params[key] = values
+			}
+			got := pagePresets(t, params, testPresets(), tt.change)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetListRejectsCursorOfRemovedPreset(t *testing.T) {
+	catalog := testPresets()
+	query, err := parsePresetQuery(url.Values{"limit": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, next, _ := query.paginate(catalog, catalog)
+
+	query, err = parsePresetQuery(url.Values{"limit": {"2"}, "cursor": {next}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining := append([]config.PresetResource{catalog[0]}, catalog[2:]...)
+	if _, _, err := query.paginate(remaining, remaining); err == nil {
+		t.Fatal("cursor of a removed preset was accepted")
+	}
+}
+
+func TestParsePresetQueryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantErr bool
+	}{
+		{name: "size with unit", params: url.Values{"maxSize": {"7.5GB"}}},
+		{name: "size in bytes", params: url.Values{"maxSize": {"1048576"}}},
+		{name: "zero size", params: url.Values{"maxSize": {"0"}}, wantErr: true},
+		{name: "size under a byte", params: url.Values{"maxSize": {"0.5B"}}, wantErr: true},
+		{name: "negative size", params: url.Values{"maxSize": {"-1GB"}}, wantErr: true},
+		{name: "unknown sort", params: url.Values{"sort": {"date"}}, wantErr: true},
+		{name: "unknown tag match", params: url.Values{"tagMatch": {"some"}}, wantErr: true},
+		{name: "zero limit", params: url.Values{"limit": {"0"}}, wantErr: true},
+		{name: "cursor of another sort", params: url.Values{
+			"sort":   {"size"},
+			"cursor": {encodeListCursor(listCursor{Sort: "name", Key: "alpha", ID: "p-a"})},
+		}, wantErr: true},
+		{name: "malformed cursor", params: url.Values{"cursor": {"!!"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePresetQuery(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePresetQuery(%v) error = %v, wantErr %v", tt.params, err, tt.wantErr)
+			}
+		})
+	}
+}
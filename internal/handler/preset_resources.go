@@ -8,7 +8,8 @@ import (
 	"paperspace-stable-diffusion-station/internal/config"
 )
 
-// GetPresetResourcesHandler returns the list of preset resources
+// GetPresetResourcesHandler returns the list of preset resources, filtered,
+// sorted and paginated by the query parameters described at parsePresetQuery
 func GetPresetResourcesHandler(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET requests
 	if r.Method != "GET" {
@@ -16,6 +17,12 @@ func GetPresetResourcesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, err := parsePresetQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get preset resources from config
 	resources, err := config.GetPresetResources()
 	if err != nil {
@@ -23,9 +30,66 @@ func GetPresetResourcesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	destinations, err := config.GetDestinations()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load installation destinations: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The installed filter needs the file of each preset, checked before taking the lock
+	var existing existingFiles
+	targets := make(map[string]presetTarget)
+	if query.installed != nil {
+		// Installed means installed in the active profile
+		list := make([]presetTarget, 0, len(resources))
+		for _, resource := range resources {
+			if !query.matches(resource) {
+				continue
+			}
+			if path, err := destinations.PresetPath(resource, destinations.Active); err == nil {
+				target := presetTarget{PresetResource: resource, Path: path}
+				if resource.DestinationType() != "" {
+					target.Profile = destinations.Active
+				}
+				targets[resource.ID] = target
+				list = append(list, target)
+			}
+		}
+		existing = checkExistingFiles(list, false)
+	}
+
+	// Filtering copies the presets, so sorting never reorders the active catalog
+	matched := make([]config.PresetResource, 0, len(resources))
+	installTasksMutex.RLock()
+	for _, resource := range resources {
+		if !query.matches(resource) {
+			continue
+		}
+		if query.installed != nil {
+			installed := false
+			if target, ok := targets[resource.ID]; ok {
+				installed = planPresetStepLocked(target, existing).Action == planActionSkip
+			}
+			if installed != *query.installed {
+				continue
+			}
+		}
+		matched = append(matched, resource)
+	}
+	installTasksMutex.RUnlock()
+
+	query.sortPresets(matched)
+	page, nextCursor, err := query.paginate(matched, resources)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Create response
 	response := PresetResourcesResponse{
-		Resources: resources,
+		Resources:  page,
+		Total:      len(matched),
+		NextCursor: nextCursor,
 	}
 
 	// Encode and send response
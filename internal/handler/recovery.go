@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// What to do with tasks that were running when the server stopped
+const (
+	// RecoveryResume puts interrupted tasks back into the queue
+	RecoveryResume = "resume"
+	// RecoveryHold keeps interrupted tasks until the user resumes them
+	RecoveryHold = "hold"
+)
+
+var interruptedTaskPolicy = RecoveryResume
+
+// persistedTask is the stored form of an unfinished task
+type persistedTask struct {
+	*InstallTask
+	Seq            int64 `json:"seq"`
+	ResumeDownload bool  `json:"resumeDownload,omitempty"`
+}
+
+// persistTaskLocked saves a snapshot of an unfinished task, or forgets a finished one.
+// installTasksMutex must be held by the caller.
+func persistTaskLocked(task *InstallTask) {
+	var err error
+	if task.Status.IsTerminal() {
+		err = installStore.DeleteTask(task.ID)
+	} else {
+		err = installStore.PutTask(task.ID, persistedTask{
+			InstallTask:    task,
+			Seq:            task.seq,
+			ResumeDownload: task.resumeDownload,
+		})
+	}
+	if err != nil {
+		logger.Error(err, "Failed to persist task %s", task.ID)
+	}
+}
+
+// restoreTasksLocked reloads unfinished tasks saved before the server stopped.
+// Tasks that were running are marked interrupted and, depending on the
+// policy, put back into the queue.
+// installTasksMutex must be held by the caller.
+func restoreTasksLocked() {
+	for id, content := range installStore.Tasks() {
+		stored := persistedTask{InstallTask: &InstallTask{}}
+		if err := json.Unmarshal(content, &stored); err != nil {
+			logger.Error(err, "Failed to restore task %s, discarding it", id)
+			installStore.DeleteTask(id)
+			continue
+		}
+
+		task := stored.InstallTask
+		if _, exists := installTasks[task.ID]; exists || task.Status.IsTerminal() {
+			continue
+		}
+		task.seq = stored.Seq
+		task.resumeDownload = stored.ResumeDownload
+		task.logs = newTaskLog()
+		if task.Window != "" {
+			if window, err := parseInstallWindow(task.Window); err == nil {
+				task.window = window
+			}
+		}
+		if task.seq > installSeq {
+			installSeq = task.seq
+		}
+
+		installTasks[task.ID] = task
+		task.logs.Add("installer", "Restored %s task after restart", task.Status)
+		logger.Info("Restored %s task %s (%s)", task.Status, task.ID, task.Name)
+
+		if task.Status.IsRunning() {
+			recoverInterruptedTaskLocked(task)
+		}
+	}
+}
+
+// recoverInterruptedTaskLocked checks the partial output of a task that was
+// running when the server stopped and applies the recovery policy.
+// installTasksMutex must be held by the caller.
+func recoverInterruptedTaskLocked(task *InstallTask) {
+	message := "server restarted"
+	if info, err := os.Stat(task.OutputPath); err == nil && !info.IsDir() && info.Size() > 0 {
+		// wget continues the partial file instead of starting over
+		task.resumeDownload = true
+		task.DownloadedBytes = info.Size()
+		if total := task.expectedBytes(); total > 0 {
+			task.Progress = float64(info.Size()) / float64(total) * 100
+		}
+		message = fmt.Sprintf("server restarted with %d bytes downloaded", info.Size())
+	}
+	task.logs.Add("installer", "Task was interrupted: %s", message)
+
+	if !task.transitionLocked(StateInterrupted, message) {
+		return
+	}
+	if interruptedTaskPolicy == RecoveryResume {
+		task.transitionLocked(StateQueued, "resumed after restart")
+	}
+}
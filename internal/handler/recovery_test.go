@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"paperspace-stable-diffusion-station/internal/store"
+)
+
+func TestRestoreTasksAfterRestart(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       TaskState
+		policy       string
+		partial      bool // a partial download exists at the output path
+		wantStatus   TaskState
+		wantResume   bool
+		wantRestored bool
+	}{
+		{name: "queued task", status: StateQueued, policy: RecoveryResume, wantStatus: StateQueued, wantRestored: true},
+		{name: "paused task", status: StatePaused, policy: RecoveryResume, wantStatus: StatePaused, wantRestored: true},
+		{name: "running task is resumed", status: StateDownloading, policy: RecoveryResume, wantStatus: StateQueued, wantRestored: true},
+		{name: "running task is held", status: StateDownloading, policy: RecoveryHold, wantStatus: StateInterrupted, wantRestored: true},
+		{name: "partial download continues", status: StateDownloading, policy: RecoveryResume, partial: true, wantStatus: StateQueued, wantResume: true, wantRestored: true},
+		{name: "finished task is not restored", status: StateCompleted, policy: RecoveryResume},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.db")
+			withInstallStore(t, path)
+			withInstallTasks(t)
+			savedPolicy := interruptedTaskPolicy
+			interruptedTaskPolicy = tt.policy
+			t.Cleanup(func() { interruptedTaskPolicy = savedPolicy })
+
+			dir := t.TempDir()
+			task := &InstallTask{
+				ID:         "task",
+				URL:        "https://example.com/model.safetensors",
+				Name:       "model.safetensors",
+				Path:       dir,
+				OutputPath: filepath.Join(dir, "model.safetensors"),
+				Size:       100,
+				Status:     tt.status,
+				Window:     "01:00-06:00",
+				seq:        7,
+				logs:       newTaskLog(),
+			}
+			if tt.partial {
+				if err := os.WriteFile(task.OutputPath, make([]byte, 40), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Terminal tasks are never persisted, so store the snapshot directly
+			if err := installStore.PutTask(task.ID, persistedTask{InstallTask: task, Seq: task.seq}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Restart: reopen the store and restore the tasks
+			reopened, err := store.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			installStore = reopened
+			installTasksMutex.Lock()
+			defer installTasksMutex.Unlock()
+			installTasks = make(map[string]*InstallTask)
+			savedSeq := installSeq
+			installSeq = 0
+			t.Cleanup(func() { installSeq = savedSeq })
+			restoreTasksLocked()
+
+			restored, exists := installTasks[task.ID]
+			if exists != tt.wantRestored {
+				t.Fatalf("restored = %v, want %v", exists, tt.wantRestored)
+			}
+			if !exists {
+				return
+			}
+			if restored.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", restored.Status, tt.wantStatus)
+			}
+			if restored.resumeDownload != tt.wantResume {
+				t.Fatalf("resume download = %v, want %v", restored.resumeDownload, tt.wantResume)
+			}
+			if tt.wantResume && (restored.DownloadedBytes != 40 || restored.Progress != 40) {
+				t.Fatalf("downloaded %d bytes (%.0f%%), want 40 (40%%)", restored.DownloadedBytes, restored.Progress)
+			}
+			if restored.seq != 7 || installSeq != 7 {
+				t.Fatalf("seq = %d, installSeq = %d, want 7", restored.seq, installSeq)
+			}
+			if restored.window == nil || restored.logs == nil {
+				t.Fatal("window or log not rebuilt")
+			}
+		})
+	}
+}
+
+func TestRestoreTasksDiscardsUnreadableSnapshots(t *testing.T) {
+	withInstallStore(t, "")
+	withInstallTasks(t)
+	if err := installStore.PutTask("broken", "not a task"); err != nil {
+		t.Fatal(err)
+	}
+
+	installTasksMutex.Lock()
+	restoreTasksLocked()
+	installTasksMutex.Unlock()
+
+	if _, exists := installStore.Tasks()["broken"]; exists {
+		t.Fatal("unreadable snapshot kept")
+	}
+}
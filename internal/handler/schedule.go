@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How often scheduled tasks are checked against their start time and window
+const scheduleCheckInterval = 30 * time.Second
+
+// installWindow is a daily time window such as "01:00-06:00" in server local time.
+// A window whose end is before its start spans midnight.
+type installWindow struct {
+	start time.Duration // offset from midnight
+	end   time.Duration
+}
+
+// parseInstallWindow parses a window in "HH:MM-HH:MM" format
+func parseInstallWindow(value string) (*installWindow, error) {
+	startValue, endValue, found := strings.Cut(value, "-")
+	if !found {
+		return nil, fmt.Errorf("window must be in HH:MM-HH:MM format")
+	}
+	// time.Parse rejects out-of-range hours and minutes and any leftover text
+	start, err := time.Parse("15:04", startValue)
+	if err != nil {
+		return nil, fmt.Errorf("window must be in HH:MM-HH:MM format with hours 00-23 and minutes 00-59")
+	}
+	end, err := time.Parse("15:04", endValue)
+	if err != nil {
+		return nil, fmt.Errorf("window must be in HH:MM-HH:MM format with hours 00-23 and minutes 00-59")
+	}
+
+	window := &installWindow{
+		start: time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+		end:   time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+	}
+	if window.start == window.end {
+		return nil, fmt.Errorf("window start and end must differ")
+	}
+	return window, nil
+}
+
+// contains reports whether t falls inside the window
+func (w *installWindow) contains(t time.Time) bool {
+	offset := t.Sub(midnight(t))
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// nextOpen returns t if it is inside the window, otherwise the next time the window opens
+func (w *installWindow) nextOpen(t time.Time) time.Time {
+	if w.contains(t) {
+		return t
+	}
+	open := midnight(t).Add(w.start)
+	if !open.After(t) {
+		open = midnight(t).AddDate(0, 0, 1).Add(w.start)
+	}
+	return open
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// earliestStartLocked returns the earliest time the task may start at or after now.
+// installTasksMutex must be held by the caller.
+func (t *InstallTask) earliestStartLocked(now time.Time) time.Time {
+	start := now
+	if t.NotBefore != nil && t.NotBefore.After(start) {
+		start = *t.NotBefore
+	}
+	if t.window != nil {
+		start = t.window.nextOpen(start)
+	}
+	return start
+}
+
+// startScheduleTicker periodically moves scheduled tasks into the queue
+func startScheduleTicker() {
+	go func() {
+		ticker := time.NewTicker(scheduleCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			installTasksMutex.Lock()
+			updateScheduledTasksLocked(time.Now())
+			installTasksMutex.Unlock()
+		}
+	}()
+}
+
+// updateScheduledTasksLocked queues scheduled tasks whose start time has come
+// and sends queued tasks whose window has closed back to the schedule.
+// installTasksMutex must be held by the caller.
+func updateScheduledTasksLocked(now time.Time) {
+	for _, task := range installTasks {
+		if task.Status != StateScheduled && task.Status != StateQueued {
+			continue
+		}
+
+		start := task.earliestStartLocked(now)
+		switch {
+		case task.Status == StateScheduled && !start.After(now):
+			task.ScheduledFor = nil
+			task.transitionLocked(StateQueued, "start time reached")
+		case task.Status == StateQueued && start.After(now):
+			task.ScheduledFor = &start
+			task.transitionLocked(StateScheduled, "install window closed")
+		case task.Status == StateScheduled:
+			task.ScheduledFor = &start
+		}
+	}
+	scheduleInstallsLocked()
+}
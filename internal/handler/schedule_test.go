@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInstallWindow(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantStart time.Duration
+		wantEnd   time.Duration
+		wantErr   bool
+	}{
+		{value: "01:00-06:00", wantStart: time.Hour, wantEnd: 6 * time.Hour},
+		{value: "22:30-05:15", wantStart: 22*time.Hour + 30*time.Minute, wantEnd: 5*time.Hour + 15*time.Minute},
+		{value: "00:00-23:59", wantEnd: 23*time.Hour + 59*time.Minute},
+		{value: "1:00-6:00", wantStart: time.Hour, wantEnd: 6 * time.Hour},
+		{value: "01:00-06:00x", wantErr: true},
+		{value: "01:00-06:00-07:00", wantErr: true},
+		{value: "01:00 - 06:00", wantErr: true},
+		{value: "01:0-06:00", wantErr: true},
+		{value: "24:00-06:00", wantErr: true},
+		{value: "01:60-06:00", wantErr: true},
+		{value: "-1:00-06:00", wantErr: true},
+		{value: "01:00", wantErr: true},
+		{value: "01:00-01:00", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			window, err := parseInstallWindow(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseInstallWindow(%q) = %+v, want an error", tt.value, window)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseInstallWindow(%q) error = %v", tt.value, err)
+			}
+			if window.start != tt.wantStart || window.end != tt.wantEnd {
+				t.Fatalf("parseInstallWindow(%q) = %v-%v, want %v-%v", tt.value, window.start, window.end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestInstallWindowNextOpen(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		window string
+		at     time.Duration
+		want   time.Duration // offset from the same midnight
+	}{
+		{window: "01:00-06:00", at: 3 * time.Hour, want: 3 * time.Hour},
+		{window: "01:00-06:00", at: 30 * time.Minute, want: time.Hour},
+		{window: "01:00-06:00", at: 6 * time.Hour, want: 25 * time.Hour},
+		{window: "22:00-02:00", at: 23 * time.Hour, want: 23 * time.Hour},
+		{window: "22:00-02:00", at: time.Hour, want: time.Hour},
+		{window: "22:00-02:00", at: 12 * time.Hour, want: 22 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		window, err := parseInstallWindow(tt.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := window.nextOpen(day.Add(tt.at)); !got.Equal(day.Add(tt.want)) {
+			t.Errorf("%s nextOpen(%v) = %v, want %v", tt.window, tt.at, got, day.Add(tt.want))
+		}
+	}
+}
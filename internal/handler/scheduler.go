@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/internal/postinstall"
+	"paperspace-stable-diffusion-station/internal/store"
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// Scheduler state for installation tasks (guarded by installTasksMutex)
+var (
+	maxConcurrentInstalls = config.DefaultMaxConcurrentInstalls
+	runningInstalls       int
+	installSeq            int64
+)
+
+// startBackground starts the background goroutines once, however often Init runs
+var startBackground sync.Once
+
+// Init applies server configuration to the installer
+func Init(cfg *config.Config) {
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	if cfg.MaxConcurrentInstalls > 0 {
+		maxConcurrentInstalls = cfg.MaxConcurrentInstalls
+	}
+	taskRetentionMaxAge = cfg.TaskRetentionMaxAge
+	taskRetentionMaxCount = cfg.TaskRetentionMaxCount
+	config.SetCatalogDir(cfg.CatalogDir)
+	subscriptions, err := config.ParseCatalogSubscriptions(cfg.CatalogSubscriptions)
+	if err != nil {
+		logger.Error(err, "Invalid catalog subscriptions, ignoring them")
+	}
+	config.SetCatalogSubscriptions(subscriptions, cfg.CatalogCacheDir)
+	config.SetDestinationsFile(cfg.DestinationsFile)
+	config.SetActiveProfile(cfg.DestinationProfile)
+	profileRoots, err := config.ParseProfileRoots(cfg.ProfileRoots)
+	if err != nil {
+		logger.Error(err, "Invalid profile roots, ignoring them")
+	}
+	config.SetProfileRoots(profileRoots)
+	config.SetPathVariables(cfg.PathVariables)
+
+	if err := downloader.SetBackend(cfg.DownloadBackend); err != nil {
+		logger.Error(err, "Invalid download backend, using %s", downloader.CurrentBackend())
+	}
+	credentials := downloader.Credentials{}
+	if credentials.HuggingFace, err = config.ResolveCredential(cfg.HuggingFaceToken); err != nil {
+		logger.Error(err, "Failed to read the Hugging Face token")
+	}
+	if credentials.Civitai, err = config.ResolveCredential(cfg.CivitaiToken); err != nil {
+		logger.Error(err, "Failed to read the Civitai API key")
+	}
+	downloader.SetCredentials(credentials)
+	postinstall.SetCommandStepsAllowed(cfg.AllowCommandSteps)
+
+	if cfg.DBPath != "" {
+		opened, err := store.Open(cfg.DBPath)
+		if err != nil {
+			logger.Error(err, "Failed to open install records at %s; earlier installs, tasks and custom presets are not loaded", cfg.DBPath)
+		}
+		installStore = opened
+	}
+	config.SetCustomPresets(customPresetEntries("", nil))
+
+	// A broken catalog or destinations file stops the server instead of failing every request
+	if _, err := reloadConfig("startup"); err != nil {
+		logger.Fatal(err, "Invalid catalog or destinations configuration, run `server catalog lint` for details")
+	}
+
+	switch cfg.InterruptedTaskPolicy {
+	case RecoveryResume, RecoveryHold:
+		interruptedTaskPolicy = cfg.InterruptedTaskPolicy
+	default:
+		logger.Warn("Unknown interrupted task policy %q, using %q", cfg.InterruptedTaskPolicy, RecoveryResume)
+		interruptedTaskPolicy = RecoveryResume
+	}
+	restoreTasksLocked()
+	migrateLegacyReceiptsLocked()
+	updateScheduledTasksLocked(time.Now())
+
+	startBackground.Do(func() {
+		startTaskPruner()
+		startScheduleTicker()
+		startUpdateChecker(cfg.UpdateCheckInterval)
+		startSubscriptionRefresher(cfg.CatalogRefreshInterval)
+		startConfigWatcher(cfg.ConfigWatchInterval)
+	})
+}
+
+// enqueueTaskLocked registers a pending task and wakes up the scheduler.
+// installTasksMutex must be held by the caller.
+func enqueueTaskLocked(task *InstallTask) {
+	installSeq++
+	task.seq = installSeq
+	installTasks[task.ID] = task
+	persistTaskLocked(task)
+	scheduleInstallsLocked()
+}
+
+// scheduleInstallsLocked starts pending tasks while worker slots are free.
+// installTasksMutex must be held by the caller.
+func scheduleInstallsLocked() {
+	for runningInstalls < maxConcurrentInstalls {
+		next := nextReadyTaskLocked()
+		if next == nil {
+			break
+		}
+
+		if !next.transitionLocked(StateProbing, "") {
+			break
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		next.cancel = cancel
+		next.running = true
+		runningInstalls++
+		go runInstallation(ctx, cancel, next)
+	}
+	updateQueuePositionsLocked()
+}
+
+// runInstallation runs a scheduled task and releases its worker slot afterwards.
+// cancel belongs to this run; a resumed task is not started again before the
+// previous run has exited, so nothing else can have replaced task.cancel.
+func runInstallation(ctx context.Context, cancel context.CancelFunc, task *InstallTask) {
+	processInstallation(ctx, task)
+
+	installTasksMutex.Lock()
+	cancel()
+	task.cancel = nil
+	task.running = false
+	runningInstalls--
+	scheduleInstallsLocked()
+	installTasksMutex.Unlock()
+}
+
+// nextReadyTaskLocked returns the first pending task whose dependencies have completed.
+// Tasks whose dependencies failed, were cancelled or are gone without being
+// installed are failed on the way, and
+// tasks resumed while their previous run is still stopping are skipped.
+// installTasksMutex must be held by the caller.
+func nextReadyTaskLocked() *InstallTask {
+	for _, task := range pendingTasksLocked() {
+		if task.running {
+			continue
+		}
+		ready, message := task.dependenciesReadyLocked()
+		if message != "" {
+			task.logs.Add("installer", "%s", message)
+			if task.transitionLocked(StateFailed, message) {
+				task.Error = message
+			}
+			continue
+		}
+		if ready {
+			return task
+		}
+	}
+	return nil
+}
+
+// pendingTasksLocked returns pending tasks in the order they will be started:
+// highest priority first, FIFO within the same priority.
+func pendingTasksLocked() []*InstallTask {
+	queue := make([]*InstallTask, 0)
+	for _, task := range installTasks {
+		if task.Status == StateQueued {
+			queue = append(queue, task)
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if queue[i].Priority != queue[j].Priority {
+			return queue[i].Priority > queue[j].Priority
+		}
+		return queue[i].seq < queue[j].seq
+	})
+	return queue
+}
+
+// updateQueuePositionsLocked refreshes QueuePosition for every task
+func updateQueuePositionsLocked() {
+	for _, task := range installTasks {
+		task.QueuePosition = 0
+	}
+	for i, task := range pendingTasksLocked() {
+		task.QueuePosition = i + 1
+	}
+}
+
+// MoveInstallTaskHandler moves a pending task up, down or to the front of the queue
+func MoveInstallTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+
+	var req MoveTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	task, exists := installTasks[taskID]
+	if !exists {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if task.Status != StateQueued {
+		http.Error(w, "Only queued tasks can be moved", http.StatusConflict)
+		return
+	}
+
+	queue := pendingTasksLocked()
+	index := 0
+	for i, queued := range queue {
+		if queued == task {
+			index = i
+			break
+		}
+	}
+
+	// Neighbouring tasks swap priority and sequence so that they exchange places
+	// even when they belong to different priority levels.
+	switch req.Direction {
+	case "up":
+		if index > 0 {
+			swapQueuePositions(task, queue[index-1])
+			persistTaskLocked(queue[index-1])
+		}
+	case "down":
+		if index < len(queue)-1 {
+			swapQueuePositions(task, queue[index+1])
+			persistTaskLocked(queue[index+1])
+		}
+	case "front":
+		if index > 0 {
+			head := queue[0]
+			task.Priority = head.Priority
+			task.seq = head.seq - 1
+		}
+	default:
+		http.Error(w, "direction must be one of: up, down, front", http.StatusBadRequest)
+		return
+	}
+	persistTaskLocked(task)
+	updateQueuePositionsLocked()
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PauseInstallTaskHandler holds a queued task or stops a running one so it can be resumed later
+func PauseInstallTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	task, exists := installTasks[taskID]
+	if !exists {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	wasDownloading := task.Status == StateDownloading
+	if !task.transitionLocked(StatePaused, "paused by user") {
+		http.Error(w, fmt.Sprintf("Task cannot be paused in state %s", task.Status), http.StatusConflict)
+		return
+	}
+	// Keep the partial file so the download continues where it stopped
+	if wasDownloading {
+		task.resumeDownload = true
+	}
+	if task.cancel != nil {
+		task.cancel()
+	}
+	updateQueuePositionsLocked()
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ResumeInstallTaskHandler puts a paused task back into the queue
+func ResumeInstallTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	task, exists := installTasks[taskID]
+	if !exists {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !task.transitionLocked(StateQueued, "resumed by user") {
+		http.Error(w, fmt.Sprintf("Task cannot be resumed in state %s", task.Status), http.StatusConflict)
+		return
+	}
+	scheduleInstallsLocked()
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// swapQueuePositions exchanges the queue positions of two pending tasks
+func swapQueuePositions(a, b *InstallTask) {
+	a.Priority, b.Priority = b.Priority, a.Priority
+	a.seq, b.seq = b.seq, a.seq
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/downloader"
+)
+
+// slowFileServer serves a file in two halves; the second half is only sent
+// once release is closed, so tests can act while a download is running
+func slowFileServer(t *testing.T, size int) (*httptest.Server, func()) {
+	t.Helper()
+	content := make([]byte, size)
+	release := make(chan struct{})
+	var once sync.Once
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(content[:size/2])
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+			w.Write(content[size/2:])
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { once.Do(func() { close(release) }) })
+	return server, func() { once.Do(func() { close(release) }) }
+}
+
+// waitForState polls a task until it reaches the state or the test times out
+func waitForState(t *testing.T, task *InstallTask, state TaskState) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		installTasksMutex.RLock()
+		current := task.Status
+		installTasksMutex.RUnlock()
+		if current == state {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	installTasksMutex.RLock()
+	defer installTasksMutex.RUnlock()
+	t.Fatalf("task %s is %s, want %s (error: %s)", task.ID, task.Status, state, task.Error)
+}
+
+// callTaskHandler calls a task handler with the task ID as path value
+func callTaskHandler(handler http.HandlerFunc, taskID string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/installer/tasks/"+taskID, nil)
+	r.SetPathValue("id", taskID)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestPauseThenResumeRestartsTheTask(t *testing.T) {
+	if err := downloader.SetBackend(downloader.BackendHTTP); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { downloader.SetBackend(downloader.BackendWget) })
+
+	server, release := slowFileServer(t, 64*1024)
+	task, _, err := newInstallTask(InstallRequest{
+		URL:  server.URL + "/model.safetensors",
+		Name: "model.safetensors",
+		Path: filepath.Join(t.TempDir(), "models"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	installTasksMutex.Lock()
+	enqueueTaskLocked(task)
+	installTasksMutex.Unlock()
+	waitForState(t, task, StateDownloading)
+
+	// Resume right after pausing, before the first run has unwound
+	if w := callTaskHandler(PauseInstallTaskHandler, task.ID); w.Code != http.StatusOK {
+		t.Fatalf("pause: %d %s", w.Code, w.Body)
+	}
+	if w := callTaskHandler(ResumeInstallTaskHandler, task.ID); w.Code != http.StatusOK {
+		t.Fatalf("resume: %d %s", w.Code, w.Body)
+	}
+
+	release()
+	waitForState(t, task, StateCompleted)
+
+	installTasksMutex.RLock()
+	defer installTasksMutex.RUnlock()
+	if task.running || task.cancel != nil {
+		t.Errorf("worker state not cleared: running=%v cancel=%v", task.running, task.cancel != nil)
+	}
+}
+
+func TestNextReadyTaskSkipsTasksWithARunningWorker(t *testing.T) {
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	saved := installTasks
+	installTasks = make(map[string]*InstallTask)
+	defer func() { installTasks = saved }()
+
+	stopping := &InstallTask{ID: "stopping", Status: StateQueued, Priority: 1, running: true, logs: newTaskLog()}
+	waiting := &InstallTask{ID: "waiting", Status: StateQueued, logs: newTaskLog()}
+	installTasks[stopping.ID] = stopping
+	installTasks[waiting.ID] = waiting
+
+	if next := nextReadyTaskLocked(); next != waiting {
+		t.Fatalf("next ready task = %v, want waiting", next)
+	}
+}
+
+func TestPendingTasksOrderAndMoves(t *testing.T) {
+	tests := []struct {
+		name      string
+		move      string // task to move, empty for none
+		direction string
+		want      string
+		wantCode  int
+	}{
+		{name: "priority first, then FIFO", want: "high low-1 low-2 low-3"},
+		{name: "up within a priority", move: "low-3", direction: "up", want: "high low-1 low-3 low-2"},
+		{name: "up across priorities", move: "low-1", direction: "up", want: "low-1 high low-2 low-3"},
+		{name: "down", move: "high", direction: "down", want: "low-1 high low-2 low-3"},
+		{name: "down at the end", move: "low-3", direction: "down", want: "high low-1 low-2 low-3"},
+		{name: "front", move: "low-3", direction: "front", want: "low-3 high low-1 low-2"},
+		{name: "unknown direction", move: "low-2", direction: "sideways", want: "high low-1 low-2 low-3", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInstallStore(t, "")
+			withInstallTasks(t)
+
+			installTasksMutex.Lock()
+			for i, spec := range []struct {
+				id       string
+				priority int
+			}{{"low-1", 0}, {"high", 5}, {"low-2", 0}, {"low-3", 0}} {
+				installTasks[spec.id] = &InstallTask{ID: spec.id, Status: StateQueued, Priority: spec.priority, seq: int64(i + 1), logs: newTaskLog()}
+			}
+			installTasksMutex.Unlock()
+
+			if tt.move != "" {
+				r := httptest.NewRequest(http.MethodPost, "/installer/tasks/"+tt.move+"/move", strings.NewReader(`{"direction":"`+tt.direction+`"}`))
+				r.SetPathValue("id", tt.move)
+				w := httptest.NewRecorder()
+				MoveInstallTaskHandler(w, r)
+				want := tt.wantCode
+				if want == 0 {
+					want = http.StatusOK
+				}
+				if w.Code != want {
+					t.Fatalf("move = %d %s, want %d", w.Code, w.Body, want)
+				}
+			}
+
+			installTasksMutex.Lock()
+			defer installTasksMutex.Unlock()
+			updateQueuePositionsLocked()
+			ids := make([]string, 0)
+			for i, task := range pendingTasksLocked() {
+				ids = append(ids, task.ID)
+				if task.QueuePosition != i+1 {
+					t.Errorf("%s queue position = %d, want %d", task.ID, task.QueuePosition, i+1)
+				}
+			}
+			if got := strings.Join(ids, " "); got != tt.want {
+				t.Fatalf("queue = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerRespectsTheConcurrencyLimit(t *testing.T) {
+	withInstallStore(t, "")
+	withInstallTasks(t)
+
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+	saved := maxConcurrentInstalls
+	maxConcurrentInstalls = 2
+	defer func() { maxConcurrentInstalls = saved }()
+
+	// Workers already hold every slot, so nothing new may start
+	runningInstalls = 2
+	installTasks["queued"] = &InstallTask{ID: "queued", Status: StateQueued, seq: 1, logs: newTaskLog()}
+	scheduleInstallsLocked()
+	if status := installTasks["queued"].Status; status != StateQueued {
+		t.Fatalf("task started with every slot taken: %s", status)
+	}
+	if position := installTasks["queued"].QueuePosition; position != 1 {
+		t.Fatalf("queue position = %d, want 1", position)
+	}
+}
+
+func TestMoveRejectsTasksThatAreNotQueued(t *testing.T) {
+	withInstallStore(t, "")
+	withInstallTasks(t)
+	installTasksMutex.Lock()
+	installTasks["paused"] = &InstallTask{ID: "paused", Status: StatePaused, logs: newTaskLog()}
+	installTasksMutex.Unlock()
+
+	r := httptest.NewRequest(http.MethodPost, "/installer/tasks/paused/move", strings.NewReader(`{"direction":"up"}`))
+	r.SetPathValue("id", "paused")
+	w := httptest.NewRecorder()
+	MoveInstallTaskHandler(w, r)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("move = %d, want 409", w.Code)
+	}
+}
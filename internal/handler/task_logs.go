@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Maximum number of log entries kept per task; older entries are dropped
+const taskLogCapacity = 1000
+
+// How often a followed log is checked for new entries
+const taskLogFollowInterval = 500 * time.Millisecond
+
+// TaskLogEntry is a single captured log line of an install task
+type TaskLogEntry struct {
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // installer, wget, http, git, validation
+	Message string    `json:"message"`
+}
+
+// TaskLogsResponse is the response of the task log endpoint
+type TaskLogsResponse struct {
+	TaskID  string         `json:"taskId"`
+	Entries []TaskLogEntry `json:"entries"`
+	Dropped int64          `json:"dropped"` // entries discarded because of the capacity limit
+}
+
+// taskLog is a bounded, concurrency-safe log buffer.
+// A nil *taskLog discards writes and reads as empty.
+type taskLog struct {
+	mu      sync.Mutex
+	entries []TaskLogEntry
+	nextSeq int64
+}
+
+func newTaskLog() *taskLog {
+	return &taskLog{nextSeq: 1}
+}
+
+// Add appends a formatted entry, dropping the oldest entry when full
+func (l *taskLog) Add(source, format string, args ...interface{}) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := TaskLogEntry{
+		Seq:     l.nextSeq,
+		Time:    time.Now(),
+		Source:  source,
+		Message: fmt.Sprintf(format, args...),
+	}
+	l.nextSeq++
+
+	if len(l.entries) >= taskLogCapacity {
+		l.entries = append(l.entries[:0], l.entries[1:]...)
+	}
+	l.entries = append(l.entries, entry)
+}
+
+// Since returns the entries with a sequence number greater than seq
+func (l *taskLog) Since(seq int64) []TaskLogEntry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]TaskLogEntry, 0)
+	for _, entry := range l.entries {
+		if entry.Seq > seq {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Dropped returns how many entries were discarded because of the capacity limit
+func (l *taskLog) Dropped() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.nextSeq - 1 - int64(len(l.entries))
+}
+
+// GetInstallTaskLogsHandler returns the captured log of a task.
+// Query parameters:
+//   - tail: only return the last N entries
+//   - follow: keep the connection open and stream new entries as NDJSON until the task finishes
+func GetInstallTaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+
+	installTasksMutex.RLock()
+	task, exists := installTasks[taskID]
+	var logs *taskLog
+	if exists {
+		logs = task.logs
+	}
+	installTasksMutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	tail := -1
+	if param := r.URL.Query().Get("tail"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 0 {
+			http.Error(w, "tail must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		tail = parsed
+	}
+
+	entries := logs.Since(0)
+	if tail >= 0 && len(entries) > tail {
+		entries = entries[len(entries)-tail:]
+	}
+
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+	if !follow {
+		response := TaskLogsResponse{
+			TaskID:  taskID,
+			Entries: entries,
+			Dropped: logs.Dropped(),
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	followTaskLog(w, r, task, logs, entries)
+}
+
+// followTaskLog streams log entries as NDJSON until the task reaches a
+// terminal status or the client disconnects
+func followTaskLog(w http.ResponseWriter, r *http.Request, task *InstallTask, logs *taskLog, initial []TaskLogEntry) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	lastSeq := int64(0)
+	write := func(entries []TaskLogEntry) bool {
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return false
+			}
+			lastSeq = entry.Seq
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if len(initial) > 0 {
+		if !write(initial) {
+			return
+		}
+	} else if all := logs.Since(0); len(all) > 0 {
+		lastSeq = all[len(all)-1].Seq
+	}
+
+	ticker := time.NewTicker(taskLogFollowInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		installTasksMutex.RLock()
+		finished := task.Status.IsTerminal()
+		installTasksMutex.RUnlock()
+
+		if !write(logs.Since(lastSeq)) || finished {
+			return
+		}
+	}
+}
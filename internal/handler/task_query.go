@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum page size of the task list
+const maxTaskPageSize = 500
+
+// taskQuery holds the parsed filter, sort and pagination parameters of the task list
+type taskQuery struct {
+	statuses   map[TaskState]bool
+	taskType   string
+	search     string
+	since      time.Time
+	sortField  string
+	descending bool
+	limit      int // 0 returns every remaining task
+	// after is the last task of the previous page, with only the sort field and ID set
+	after *InstallTask
+}
+
+// parseTaskQuery parses the task list query parameters:
+//   - status: comma separated statuses
+//   - type: resource type
+//   - q: case-insensitive substring of the task name
+//   - since: RFC 3339 timestamp, only tasks started at or after it
+//   - sort: startTime (default), progress or name; prefix with "-" for descending
+//   - limit, cursor: page size and the nextCursor of the previous page
+func parseTaskQuery(values url.Values) (*taskQuery, error) {
+	query := &taskQuery{
+		statuses:  make(map[TaskState]bool),
+		taskType:  values.Get("type"),
+		search:    strings.ToLower(values.Get("q")),
+		sortField: "startTime",
+	}
+
+	if param := values.Get("status"); param != "" {
+		for _, status := range strings.Split(param, ",") {
+			query.statuses[TaskState(strings.TrimSpace(status))] = true
+		}
+	}
+
+	if param := values.Get("since"); param != "" {
+		since, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			return nil, fmt.Errorf("since must be an RFC 3339 timestamp")
+		}
+		query.since = since
+	}
+
+	if param := values.Get("sort"); param != "" {
+		query.descending = strings.HasPrefix(param, "-")
+		query.sortField = strings.TrimPrefix(param, "-")
+		switch query.sortField {
+		case "startTime", "progress", "name":
+		default:
+			return nil, fmt.Errorf("sort must be one of: startTime, progress, name")
+		}
+	}
+
+	if param := values.Get("limit"); param != "" {
+		limit, err := strconv.Atoi(param)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		query.limit = min(limit, maxTaskPageSize)
+	}
+
+	if param := values.Get("cursor"); param != "" {
+		cursor, err := decodeListCursor(param)
+		if err != nil || cursor.Sort != query.sortParam() {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if query.after, err = query.cursorTask(cursor); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	return query, nil
+}
+
+// sortParam returns the sort parameter the query was made with
+func (q *taskQuery) sortParam() string {
+	if q.descending {
+		return "-" + q.sortField
+	}
+	return q.sortField
+}
+
+// sortKey returns the value of the sort field of a task as stored in a cursor
+func (q *taskQuery) sortKey(task *InstallTask) string {
+	switch q.sortField {
+	case "progress":
+		return strconv.FormatFloat(task.Progress, 'g', -1, 64)
+	case "name":
+		return task.Name
+	default:
+		return task.StartTime.Format(time.RFC3339Nano)
+	}
+}
+
+// cursorTask rebuilds the position of a cursor as a task that sorts like the
+// last task of the previous page
+func (q *taskQuery) cursorTask(cursor listCursor) (*InstallTask, error) {
+	task := &InstallTask{ID: cursor.ID}
+	var err error
+	switch q.sortField {
+	case "progress":
+		task.Progress, err = strconv.ParseFloat(cursor.Key, 64)
+	case "name":
+		task.Name = cursor.Key
+	default:
+		task.StartTime, err = time.Parse(time.RFC3339Nano, cursor.Key)
+	}
+	return task, err
+}
+
+// matches reports whether a task passes the query filters
+func (q *taskQuery) matches(task *InstallTask) bool {
+	if len(q.statuses) > 0 && !q.statuses[task.Status] {
+		return false
+	}
+	if q.taskType != "" && task.Type != q.taskType {
+		return false
+	}
+	if q.search != "" && !strings.Contains(strings.ToLower(task.Name), q.search) {
+		return false
+	}
+	if !q.since.IsZero() && task.StartTime.Before(q.since) {
+		return false
+	}
+	return true
+}
+
+// sortTasks orders tasks by the query sort field; ties are broken by task ID
+// so the order is deterministic across requests
+func (q *taskQuery) sortTasks(tasks []InstallTask) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return q.less(&tasks[i], &tasks[j])
+	})
+}
+
+// less reports whether task a comes before task b in the query order
+func (q *taskQuery) less(a, b *InstallTask) bool {
+	if q.descending {
+		a, b = b, a
+	}
+
+	switch q.sortField {
+	case "progress":
+		if a.Progress != b.Progress {
+			return a.Progress < b.Progress
+		}
+	case "name":
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+	default:
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+	}
+	return a.ID < b.ID
+}
+
+// paginate returns the page after the cursor and the cursor of the next page, if any.
+// Pages continue after the last task of the previous page, so tasks added or
+// removed in the meantime neither repeat nor skip tasks.
+func (q *taskQuery) paginate(tasks []InstallTask) ([]InstallTask, string) {
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(tasks), func(i int) bool { return q.less(q.after, &tasks[i]) })
+	}
+
+	end := len(tasks)
+	if q.limit > 0 {
+		end = min(start+q.limit, len(tasks))
+	}
+	nextCursor := ""
+	if end < len(tasks) && end > start {
+		last := &tasks[end-1]
+		nextCursor = encodeListCursor(listCursor{Sort: q.sortParam(), Key: q.sortKey(last), ID: last.ID})
+	}
+	return tasks[start:end], nextCursor
+}
+
+// listCursor marks the last item of a page by its sort key and ID
+type listCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+func encodeListCursor(cursor listCursor) string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeListCursor(value string) (listCursor, error) {
+	var cursor listCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.ID == "" {
+		return cursor, fmt.Errorf("cursor without id")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// pageThrough collects every page of a task list query, applying change
+// between the first and the second page
+func pageThrough(t *testing.T, params url.Values, tasks []InstallTask, change func([]InstallTask) []InstallTask) []string {
+	t.Helper()
+	seen := make([]string, 0)
+	for page := 0; page < 20; page++ {
+		query, err := parseTaskQuery(params)
+		if err != nil {
+			t.Fatalf("parseTaskQuery(%v) error = %v", params, err)
+		}
+		sorted := append([]InstallTask{}, tasks...)
+		query.sortTasks(sorted)
+		result, next := query.paginate(sorted)
+		for _, task := range result {
+			seen = append(seen, task.ID)
+		}
+		if next == "" {
+			return seen
+		}
+		if page == 0 && change != nil {
+			tasks = change(tasks)
+		}
+		params.Set("cursor", next)
+	}
+	t.Fatal("pagination did not end")
+	return nil
+}
+
+func TestTaskListCursorPaging(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tasks := make([]InstallTask, 0)
+	for i := 0; i < 7; i++ {
+		tasks = append(tasks, InstallTask{
+			ID:        fmt.Sprintf("task-%d", i),
+			Name:      fmt.Sprintf("model-%d", i%3),
+			Progress:  float64(i%2) * 50,
+			StartTime: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	tests := []struct {
+		name   string
+		sort   string
+		change func([]InstallTask) []InstallTask
+		want   []string
+	}{
+		{
+			name: "start time",
+			want: []string{"task-0", "task-1", "task-2", "task-3", "task-4", "task-5", "task-6"},
+		},
+		{
+			name: "descending start time",
+			sort: "-startTime",
+			want: []string{"task-6", "task-5", "task-4", "task-3", "task-2", "task-1", "task-0"},
+		},
+		{
+			name: "name with ties broken by id",
+			sort: "name",
+			want: []string{"task-0", "task-3", "task-6", "task-1", "task-4", "task-2", "task-5"},
+		},
+		{
+			name: "descending progress reverses ties too",
+			sort: "-progress",
+			want: []string{"task-5", "task-3", "task-1", "task-6", "task-4", "task-2", "task-0"},
+		},
+		{
+			name: "task removed from an earlier page",
+			change: func(tasks []InstallTask) []InstallTask {
+				return tasks[1:]
+			},
+			want: []string{"task-0", "task-1", "task-2", "task-3", "task-4", "task-5", "task-6"},
+		},
+		{
+			name: "task added before the cursor",
+			change: func(tasks []InstallTask) []InstallTask {
+				return append(tasks, InstallTask{ID: "task-early", StartTime: base.Add(-time.Hour)})
+			},
+			want: []string{"task-0", "task-1", "task-2", "task-3", "task-4", "task-5", "task-6"},
+		},
+		{
+			name: "task added after the cursor",
+			change: func(tasks []InstallTask) []InstallTask {
+				return append(tasks, InstallTask{ID: "task-late", StartTime: base.Add(time.Hour)})
+			},
+			want: []string{"task-0", "task-1", "task-2", "task-3", "task-4", "task-5", "task-6", "task-late"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := url.Values{"limit": {"3"}}
+			if tt.sort != "" {
+				params.Set("sort", tt.sort)
+			}
+			got := pageThrough(t, params, tasks, tt.change)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskListRejectsInvalidCursors(t *testing.T) {
+	other := encodeListCursor(listCursor{Sort: "name", Key: "a", ID: "task-1"})
+	for _, cursor := range []string{"not-base64!", "bnVsbA", other} {
+		if _, err := parseTaskQuery(url.Values{"cursor": {cursor}}); err == nil {
+			t.Errorf("cursor %q was accepted", cursor)
+		}
+	}
+}
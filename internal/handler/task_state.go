@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"time"
+
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// TaskState is the lifecycle state of an install task
+type TaskState string
+
+const (
+	StateScheduled   TaskState = "scheduled"
+	StateQueued      TaskState = "queued"
+	StateProbing     TaskState = "probing"
+	StateDownloading TaskState = "downloading"
+	StateVerifying   TaskState = "verifying"
+	StateExtracting  TaskState = "extracting"
+	StatePostInstall TaskState = "post-install"
+	StateCompleted   TaskState = "completed"
+	StateFailed      TaskState = "failed"
+	StateCancelled   TaskState = "cancelled"
+	StatePaused      TaskState = "paused"
+	StateInterrupted TaskState = "interrupted" // was running when the server stopped
+)
+
+// taskTransitions lists the states each state may move to.
+// Terminal states have no outgoing transitions.
+var taskTransitions = map[TaskState][]TaskState{
+	StateScheduled:   {StateQueued, StateCancelled},
+	StateQueued:      {StateProbing, StateScheduled, StatePaused, StateCancelled, StateFailed},
+	StateProbing:     {StateDownloading, StateCompleted, StatePaused, StateCancelled, StateFailed, StateInterrupted},
+	StateDownloading: {StateVerifying, StatePaused, StateCancelled, StateFailed, StateInterrupted},
+	StateVerifying:   {StateExtracting, StatePostInstall, StateCompleted, StateCancelled, StateFailed, StateInterrupted},
+	StateExtracting:  {StateExtracting, StatePostInstall, StateCompleted, StateCancelled, StateFailed, StateInterrupted},
+	StatePostInstall: {StatePostInstall, StateExtracting, StateCompleted, StateCancelled, StateFailed, StateInterrupted},
+	StatePaused:      {StateQueued, StateCancelled},
+	StateInterrupted: {StateQueued, StateCancelled},
+}
+
+// TaskPhase records when a task entered a state
+type TaskPhase struct {
+	State   TaskState `json:"state"`
+	At      time.Time `json:"at"`
+	Message string    `json:"message,omitempty"`
+}
+
+// IsTerminal reports whether the state is final
+func (s TaskState) IsTerminal() bool {
+	return s == StateCompleted || s == StateFailed || s == StateCancelled
+}
+
+// IsRunning reports whether a task in this state occupies a worker slot
+func (s TaskState) IsRunning() bool {
+	switch s {
+	case StateProbing, StateDownloading, StateVerifying, StateExtracting, StatePostInstall:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether moving from s to next is allowed
+func (s TaskState) CanTransitionTo(next TaskState) bool {
+	for _, allowed := range taskTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionLocked moves the task to the next state and records it in the
+// timeline. Illegal transitions are rejected and logged.
+// installTasksMutex must be held by the caller.
+func (t *InstallTask) transitionLocked(next TaskState, message string) bool {
+	if !t.Status.CanTransitionTo(next) {
+		logger.Warn("Rejected illegal state transition of task %s: %s -> %s", t.ID, t.Status, next)
+		t.logs.Add("installer", "Rejected illegal state transition %s -> %s", t.Status, next)
+		return false
+	}
+
+	if t.Status == StateDownloading {
+		t.resetTransferRateLocked()
+	}
+
+	now := time.Now()
+	t.Status = next
+	t.Timeline = append(t.Timeline, TaskPhase{State: next, At: now, Message: message})
+	if next.IsTerminal() {
+		t.EndTime = &now
+	}
+	t.logs.Add("installer", "State changed to %s", next)
+	persistTaskLocked(t)
+	return true
+}
+
+// setTaskState locks the task list and transitions the task
+func setTaskState(task *InstallTask, next TaskState, message string) bool {
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	return task.transitionLocked(next, message)
+}
+
+// failTask moves the task to the failed state with the given error message
+func failTask(task *InstallTask, message string) {
+	task.logs.Add("installer", "%s", message)
+
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	if task.transitionLocked(StateFailed, message) {
+		task.Error = message
+	}
+}
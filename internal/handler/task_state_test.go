@@ -0,0 +1,83 @@
+package handler
+
+import "testing"
+
+func TestTaskStateTransitions(t *testing.T) {
+	tests := []struct {
+		from, to TaskState
+		want     bool
+	}{
+		{StateScheduled, StateQueued, true},
+		{StateScheduled, StateDownloading, false},
+		{StateQueued, StateProbing, true},
+		{StateQueued, StateCompleted, false},
+		{StateProbing, StateCompleted, true}, // existing file kept
+		{StateDownloading, StateVerifying, true},
+		{StateDownloading, StateCompleted, false},
+		{StateDownloading, StatePaused, true},
+		{StateVerifying, StatePaused, false},
+		{StateExtracting, StateExtracting, true},
+		{StatePostInstall, StateExtracting, true},
+		{StatePaused, StateQueued, true},
+		{StatePaused, StateDownloading, false},
+		{StateInterrupted, StateQueued, true},
+		{StateCompleted, StateQueued, false},
+		{StateFailed, StateQueued, false},
+		{StateCancelled, StateQueued, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s allowed = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTerminalStatesHaveNoTransitions(t *testing.T) {
+	for state, next := range taskTransitions {
+		if state.IsTerminal() {
+			t.Errorf("terminal state %s has transitions %v", state, next)
+		}
+		if state.IsRunning() && !state.CanTransitionTo(StateInterrupted) {
+			t.Errorf("running state %s cannot be interrupted", state)
+		}
+	}
+}
+
+func TestTransitionLockedRecordsTimeline(t *testing.T) {
+	withInstallStore(t, "")
+	task := &InstallTask{ID: "task", Status: StateQueued, logs: newTaskLog()}
+
+	installTasksMutex.Lock()
+	defer installTasksMutex.Unlock()
+
+	steps := []struct {
+		next TaskState
+		want bool
+	}{
+		{StateProbing, true},
+		{StateDownloading, true},
+		{StateCompleted, false}, // must be verified first
+		{StateVerifying, true},
+		{StateCompleted, true},
+		{StateQueued, false},
+	}
+	for _, step := range steps {
+		if got := task.transitionLocked(step.next, ""); got != step.want {
+			t.Fatalf("transition to %s = %v, want %v", step.next, got, step.want)
+		}
+	}
+
+	if task.Status != StateCompleted || task.EndTime == nil {
+		t.Fatalf("status = %s, end time = %v", task.Status, task.EndTime)
+	}
+	want := []TaskState{StateProbing, StateDownloading, StateVerifying, StateCompleted}
+	if len(task.Timeline) != len(want) {
+		t.Fatalf("timeline = %v, want states %v", task.Timeline, want)
+	}
+	for i, phase := range task.Timeline {
+		if phase.State != want[i] {
+			t.Fatalf("timeline = %v, want states %v", task.Timeline, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Weight of the newest sample in the transfer speed moving average
+const speedSmoothingFactor = 0.3
+
+// Samples closer together than this are merged into the next one
+const minSpeedSampleInterval = 500 * time.Millisecond
+
+// InstallQueueSummary aggregates transfer statistics of all unfinished tasks
+type InstallQueueSummary struct {
+	RunningTasks     int     `json:"runningTasks"`
+	QueuedTasks      int     `json:"queuedTasks"`
+	ScheduledTasks   int     `json:"scheduledTasks"`
+	PausedTasks      int     `json:"pausedTasks"`
+	InterruptedTasks int     `json:"interruptedTasks"`
+	DownloadedBytes  int64   `json:"downloadedBytes"`
+	TotalBytes       int64   `json:"totalBytes"`
+	BytesPerSecond   float64 `json:"bytesPerSecond"`
+	ETASeconds       int64   `json:"etaSeconds,omitempty"`
+	UnknownSizeTasks int     `json:"unknownSizeTasks"` // tasks not counted in totalBytes
+}
+
+// updateTransferStatsLocked records a progress sample and refreshes the
+// smoothed speed and the ETA.
+// installTasksMutex must be held by the caller.
+func (t *InstallTask) updateTransferStatsLocked(downloaded, total int64, now time.Time) {
+	if total > 0 {
+		t.TotalBytes = total
+	}
+	t.DownloadedBytes = downloaded
+
+	if t.speedSampleTime.IsZero() {
+		t.speedSampleTime = now
+		t.speedSampleBytes = downloaded
+		return
+	}
+
+	elapsed := now.Sub(t.speedSampleTime)
+	if elapsed < minSpeedSampleInterval {
+		return
+	}
+
+	rate := math.Max(0, float64(downloaded-t.speedSampleBytes)/elapsed.Seconds())
+	if t.BytesPerSecond == 0 {
+		t.BytesPerSecond = rate
+	} else {
+		t.BytesPerSecond = speedSmoothingFactor*rate + (1-speedSmoothingFactor)*t.BytesPerSecond
+	}
+	t.speedSampleTime = now
+	t.speedSampleBytes = downloaded
+
+	t.ETASeconds = estimateSeconds(t.TotalBytes-t.DownloadedBytes, t.BytesPerSecond)
+}
+
+// resetTransferRateLocked clears the speed and ETA of a task that stopped transferring.
+// installTasksMutex must be held by the caller.
+func (t *InstallTask) resetTransferRateLocked() {
+	t.BytesPerSecond = 0
+	t.ETASeconds = 0
+	t.speedSampleTime = time.Time{}
+}
+
+// expectedBytes returns the best known total size of a task, or 0 if unknown
+func (t *InstallTask) expectedBytes() int64 {
+	if t.TotalBytes > 0 {
+		return t.TotalBytes
+	}
+	return t.Size
+}
+
+// estimateSeconds returns the time needed for the remaining bytes, or 0 if unknown
+func estimateSeconds(remaining int64, bytesPerSecond float64) int64 {
+	if remaining <= 0 || bytesPerSecond <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(remaining) / bytesPerSecond))
+}
+
+// GetInstallSummaryHandler returns aggregated progress of all unfinished tasks
+func GetInstallSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	var summary InstallQueueSummary
+
+	installTasksMutex.RLock()
+	for _, task := range installTasks {
+		switch {
+		case task.Status.IsTerminal():
+			continue
+		case task.Status == StateQueued:
+			summary.QueuedTasks++
+		case task.Status == StateScheduled:
+			summary.ScheduledTasks++
+		case task.Status == StatePaused:
+			summary.PausedTasks++
+		case task.Status == StateInterrupted:
+			summary.InterruptedTasks++
+		default:
+			summary.RunningTasks++
+		}
+
+		expected := task.expectedBytes()
+		if expected <= 0 {
+			summary.UnknownSizeTasks++
+		}
+		summary.TotalBytes += expected
+		summary.DownloadedBytes += task.DownloadedBytes
+		summary.BytesPerSecond += task.BytesPerSecond
+	}
+	installTasksMutex.RUnlock()
+
+	summary.ETASeconds = estimateSeconds(summary.TotalBytes-summary.DownloadedBytes, summary.BytesPerSecond)
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
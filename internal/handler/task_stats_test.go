@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUpdateTransferStats(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	type sample struct {
+		after      time.Duration
+		downloaded int64
+		total      int64
+	}
+	tests := []struct {
+		name      string
+		samples   []sample
+		wantSpeed float64
+		wantETA   int64
+		wantTotal int64
+	}{
+		{
+			name:      "first sample only sets the baseline",
+			samples:   []sample{{0, 100, 1000}},
+			wantSpeed: 0,
+			wantETA:   0,
+			wantTotal: 1000,
+		},
+		{
+			name:      "second sample sets the speed directly",
+			samples:   []sample{{0, 0, 1000}, {time.Second, 100, 0}},
+			wantSpeed: 100,
+			wantETA:   9,
+			wantTotal: 1000,
+		},
+		{
+			name:      "later samples are smoothed",
+			samples:   []sample{{0, 0, 1000}, {time.Second, 100, 0}, {2 * time.Second, 300, 0}},
+			wantSpeed: speedSmoothingFactor*200 + (1-speedSmoothingFactor)*100,
+			wantETA:   int64(math.Ceil(700 / (speedSmoothingFactor*200 + (1-speedSmoothingFactor)*100))),
+			wantTotal: 1000,
+		},
+		{
+			name:      "samples closer than the interval are merged",
+			samples:   []sample{{0, 0, 1000}, {100 * time.Millisecond, 50, 0}, {time.Second, 100, 0}},
+			wantSpeed: 100,
+			wantETA:   9,
+			wantTotal: 1000,
+		},
+		{
+			name:      "unknown total has no ETA",
+			samples:   []sample{{0, 0, 0}, {time.Second, 100, 0}},
+			wantSpeed: 100,
+			wantETA:   0,
+			wantTotal: 0,
+		},
+		{
+			name:      "restarted download does not report a negative speed",
+			samples:   []sample{{0, 500, 1000}, {time.Second, 0, 0}},
+			wantSpeed: 0,
+			wantETA:   0,
+			wantTotal: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &InstallTask{}
+			for _, s := range tt.samples {
+				task.updateTransferStatsLocked(s.downloaded, s.total, start.Add(s.after))
+			}
+			if math.Abs(task.BytesPerSecond-tt.wantSpeed) > 1e-9 {
+				t.Errorf("speed = %v, want %v", task.BytesPerSecond, tt.wantSpeed)
+			}
+			if task.ETASeconds != tt.wantETA {
+				t.Errorf("eta = %d, want %d", task.ETASeconds, tt.wantETA)
+			}
+			if task.TotalBytes != tt.wantTotal {
+				t.Errorf("total = %d, want %d", task.TotalBytes, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestResetTransferRateStartsANewBaseline(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	task := &InstallTask{}
+	task.updateTransferStatsLocked(0, 1000, start)
+	task.updateTransferStatsLocked(100, 0, start.Add(time.Second))
+
+	task.resetTransferRateLocked()
+	if task.BytesPerSecond != 0 || task.ETASeconds != 0 {
+		t.Fatalf("after reset: speed = %v, eta = %d", task.BytesPerSecond, task.ETASeconds)
+	}
+	if task.DownloadedBytes != 100 {
+		t.Fatalf("downloaded bytes = %d, want them kept", task.DownloadedBytes)
+	}
+
+	// The pause must not count as slow transfer once the download continues
+	task.updateTransferStatsLocked(100, 0, start.Add(time.Minute))
+	task.updateTransferStatsLocked(300, 0, start.Add(time.Minute+time.Second))
+	if task.BytesPerSecond != 200 {
+		t.Fatalf("speed after resume = %v, want 200", task.BytesPerSecond)
+	}
+}
+
+func TestInstallSummary(t *testing.T) {
+	withInstallTasks(t)
+
+	installTasksMutex.Lock()
+	for _, task := range []*InstallTask{
+		{ID: "downloading", Status: StateDownloading, TotalBytes: 1000, DownloadedBytes: 400, BytesPerSecond: 100},
+		{ID: "queued", Status: StateQueued, Size: 500},
+		{ID: "paused", Status: StatePaused, TotalBytes: 200, DownloadedBytes: 100},
+		{ID: "unknown", Status: StateQueued},
+		{ID: "done", Status: StateCompleted, TotalBytes: 9999, DownloadedBytes: 9999, BytesPerSecond: 50},
+	} {
+		installTasks[task.ID] = task
+	}
+	installTasksMutex.Unlock()
+
+	w := httptest.NewRecorder()
+	GetInstallSummaryHandler(w, httptest.NewRequest(http.MethodGet, "/installer/summary", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("summary = %d %s", w.Code, w.Body)
+	}
+
+	var summary InstallQueueSummary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	want := InstallQueueSummary{
+		RunningTasks:     1,
+		QueuedTasks:      2,
+		PausedTasks:      1,
+		DownloadedBytes:  500,
+		TotalBytes:       1700,
+		BytesPerSecond:   100,
+		ETASeconds:       12,
+		UnknownSizeTasks: 1,
+	}
+	if summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+}
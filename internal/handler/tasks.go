@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// How often finished tasks are checked against the retention policy
+const taskPruneInterval = time.Minute
+
+// Retention policy for finished tasks (guarded by installTasksMutex)
+var (
+	taskRetentionMaxAge   time.Duration
+	taskRetentionMaxCount int
+)
+
+// DeleteInstallTaskHandler removes a finished task from the task list
+func DeleteInstallTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+
+	installTasksMutex.Lock()
+	task, exists := installTasks[taskID]
+	if !exists {
+		installTasksMutex.Unlock()
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !task.Status.IsTerminal() {
+		installTasksMutex.Unlock()
+		http.Error(w, "Task is still active; cancel it before deleting", http.StatusConflict)
+		return
+	}
+	delete(installTasks, taskID)
+	installTasksMutex.Unlock()
+
+	response := map[string]string{
+		"status":  "deleted",
+		"message": "Task deleted successfully",
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ClearInstallTasksHandler removes all finished tasks with the given statuses.
+// Without a status parameter every finished task is removed.
+func ClearInstallTasksHandler(w http.ResponseWriter, r *http.Request) {
+	statuses := make(map[TaskState]bool)
+	if param := r.URL.Query().Get("status"); param != "" {
+		for _, value := range strings.Split(param, ",") {
+			status := TaskState(strings.TrimSpace(value))
+			if !status.IsTerminal() {
+				http.Error(w, "status must be a list of: completed, failed, cancelled", http.StatusBadRequest)
+				return
+			}
+			statuses[status] = true
+		}
+	}
+
+	installTasksMutex.Lock()
+	removed := 0
+	for id, task := range installTasks {
+		if !task.Status.IsTerminal() {
+			continue
+		}
+		if len(statuses) > 0 && !statuses[task.Status] {
+			continue
+		}
+		delete(installTasks, id)
+		removed++
+	}
+	installTasksMutex.Unlock()
+
+	response := map[string]int{"removed": removed}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// startTaskPruner periodically applies the retention policy
+func startTaskPruner() {
+	go func() {
+		ticker := time.NewTicker(taskPruneInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			installTasksMutex.Lock()
+			removed := pruneFinishedTasksLocked(time.Now())
+			installTasksMutex.Unlock()
+
+			if removed > 0 {
+				logger.Info("Pruned %d finished install tasks", removed)
+			}
+		}
+	}()
+}
+
+// pruneFinishedTasksLocked removes finished tasks older than the maximum age
+// and, beyond that, the oldest finished tasks above the maximum count.
+// installTasksMutex must be held by the caller.
+func pruneFinishedTasksLocked(now time.Time) int {
+	finished := make([]*InstallTask, 0)
+	for _, task := range installTasks {
+		if task.Status.IsTerminal() {
+			finished = append(finished, task)
+		}
+	}
+
+	// Newest first, so everything past the maximum count is the oldest
+	sort.Slice(finished, func(i, j int) bool {
+		return taskFinishTime(finished[i]).After(taskFinishTime(finished[j]))
+	})
+
+	removed := 0
+	for i, task := range finished {
+		expired := taskRetentionMaxAge > 0 && now.Sub(taskFinishTime(task)) > taskRetentionMaxAge
+		overflow := taskRetentionMaxCount > 0 && i >= taskRetentionMaxCount
+		if expired || overflow {
+			delete(installTasks, task.ID)
+			removed++
+		}
+	}
+	return removed
+}
+
+// taskFinishTime returns when a task finished, falling back to its start time
+func taskFinishTime(task *InstallTask) time.Time {
+	if task.EndTime != nil {
+		return *task.EndTime
+	}
+	return task.StartTime
+}
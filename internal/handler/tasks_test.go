@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// finishedTask returns a task that finished the given time before now
+func finishedTask(id string, status TaskState, now time.Time, ago time.Duration) *InstallTask {
+	end := now.Add(-ago)
+	return &InstallTask{ID: id, Status: status, StartTime: end.Add(-time.Minute), EndTime: &end, logs: newTaskLog()}
+}
+
+// remainingTaskIDs returns the IDs of the task list in sorted order
+func remainingTaskIDs() string {
+	installTasksMutex.RLock()
+	defer installTasksMutex.RUnlock()
+	ids := make([]string, 0, len(installTasks))
+	for id := range installTasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, " ")
+}
+
+func TestPruneFinishedTasks(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		maxCount    int
+		wantRemoved int
+		want        string
+	}{
+		{name: "disabled", want: "active done-1h done-3d failed-10d"},
+		{name: "max age", maxAge: 48 * time.Hour, wantRemoved: 2, want: "active done-1h"},
+		{name: "max count keeps the newest", maxCount: 2, wantRemoved: 1, want: "active done-1h done-3d"},
+		{name: "both", maxAge: 48 * time.Hour, maxCount: 1, wantRemoved: 2, want: "active done-1h"},
+		{name: "count of zero disables only the count", maxAge: 240 * time.Hour, wantRemoved: 0, want: "active done-1h done-3d failed-10d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInstallTasks(t)
+			savedAge, savedCount := taskRetentionMaxAge, taskRetentionMaxCount
+			taskRetentionMaxAge, taskRetentionMaxCount = tt.maxAge, tt.maxCount
+			defer func() { taskRetentionMaxAge, taskRetentionMaxCount = savedAge, savedCount }()
+
+			installTasksMutex.Lock()
+			// Active tasks are never pruned, however old
+			installTasks["active"] = &InstallTask{ID: "active", Status: StateQueued, StartTime: now.Add(-30 * 24 * time.Hour), logs: newTaskLog()}
+			installTasks["done-1h"] = finishedTask("done-1h", StateCompleted, now, time.Hour)
+			installTasks["done-3d"] = finishedTask("done-3d", StateCompleted, now, 72*time.Hour)
+			installTasks["failed-10d"] = finishedTask("failed-10d", StateFailed, now, 10*24*time.Hour)
+			removed := pruneFinishedTasksLocked(now)
+			installTasksMutex.Unlock()
+
+			if removed != tt.wantRemoved {
+				t.Errorf("removed = %d, want %d", removed, tt.wantRemoved)
+			}
+			if got := remainingTaskIDs(); got != tt.want {
+				t.Fatalf("remaining = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteAndClearTasks(t *testing.T) {
+	now := time.Now()
+	setup := func(t *testing.T) {
+		withInstallTasks(t)
+		installTasksMutex.Lock()
+		defer installTasksMutex.Unlock()
+		installTasks["active"] = &InstallTask{ID: "active", Status: StateDownloading, logs: newTaskLog()}
+		installTasks["done"] = finishedTask("done", StateCompleted, now, time.Hour)
+		installTasks["failed"] = finishedTask("failed", StateFailed, now, time.Hour)
+		installTasks["cancelled"] = finishedTask("cancelled", StateCancelled, now, time.Hour)
+	}
+
+	tests := []struct {
+		name     string
+		delete   string // task to delete; clears when empty
+		clear    string // status parameter of the clear request
+		wantCode int
+		want     string
+	}{
+		{name: "delete a finished task", delete: "done", wantCode: http.StatusOK, want: "active cancelled failed"},
+		{name: "delete an active task", delete: "active", wantCode: http.StatusConflict, want: "active cancelled done failed"},
+		{name: "delete an unknown task", delete: "missing", wantCode: http.StatusNotFound, want: "active cancelled done failed"},
+		{name: "clear every finished task", wantCode: http.StatusOK, want: "active"},
+		{name: "clear by status", clear: "failed, cancelled", wantCode: http.StatusOK, want: "active done"},
+		{name: "clear an active status", clear: "downloading", wantCode: http.StatusBadRequest, want: "active cancelled done failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			w := httptest.NewRecorder()
+			if tt.delete != "" {
+				r := httptest.NewRequest(http.MethodDelete, "/installer/tasks/"+tt.delete, nil)
+				r.SetPathValue("id", tt.delete)
+				DeleteInstallTaskHandler(w, r)
+			} else {
+				target := "/installer/tasks/clear"
+				if tt.clear != "" {
+					target += "?status=" + strings.ReplaceAll(tt.clear, " ", "%20")
+				}
+				ClearInstallTasksHandler(w, httptest.NewRequest(http.MethodPost, target, nil))
+			}
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d %s, want %d", w.Code, w.Body, tt.wantCode)
+			}
+			if got := remainingTaskIDs(); got != tt.want {
+				t.Fatalf("remaining = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
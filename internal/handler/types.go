@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"context"
 	"time"
 
 	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/internal/store"
 )
 
 // Dashboard-related data structures
@@ -55,41 +58,198 @@ type InstallDestination struct {
 
 // Installation destination response for API
 type InstallationDestination struct {
-	Type string `json:"type"`
-	Path string `json:"path"`
+	Type     string `json:"type"`
+	Path     string `json:"path"`               // expanded absolute path
+	Template string `json:"template,omitempty"` // path as configured, e.g. ${COMFYUI_ROOT}/models/loras
 }
 
 type InstallationDestinationsResponse struct {
+	Profile      string                    `json:"profile"` // active destination profile
 	Destinations []InstallationDestination `json:"destinations"`
+	Variables    []config.PathVariable     `json:"variables"` // values used to expand the paths
+}
+
+type DestinationProfilesResponse struct {
+	Active   string                      `json:"active"`
+	Profiles []config.DestinationProfile `json:"profiles"`
+}
+
+type ResourceTypesResponse struct {
+	Types []config.ResourceType `json:"types"`
 }
 
 type InstallRequest struct {
 	URL  string `json:"url"`
 	Name string `json:"name"`
-	Path string `json:"path"`
-	Type string `json:"type,omitempty"` // Optional: for display purposes
+	// Optional: resolved from Type and the profile when empty
+	Path     string `json:"path,omitempty"`
+	Type     string `json:"type,omitempty"`     // Optional: resource type, e.g. lora, or its destination type, e.g. loras
+	Priority int    `json:"priority,omitempty"` // Optional: higher runs first
+	SHA256   string `json:"sha256,omitempty"`   // Optional: expected file hash
+	Size     int64  `json:"size,omitempty"`     // Optional: expected file size in bytes
+	// Optional: preset the resource comes from, used to look up its post-install steps
+	PresetID string `json:"presetId,omitempty"`
+	// Optional: destination profile the install belongs to
+	Profile string `json:"profile,omitempty"`
+	// Optional: install to a destination of another type, e.g. a LoRA in checkpoints
+	AllowTypeMismatch bool `json:"allowTypeMismatch,omitempty"`
+	// Optional: do not start before this time
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	// Optional: only start inside this daily window, e.g. "01:00-06:00" (server local time)
+	Window string `json:"window,omitempty"`
 }
 
 type InstallResponse struct {
-	TaskID  string `json:"taskId"`
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	TaskID  string    `json:"taskId"`
+	Status  TaskState `json:"status"`
+	Message string    `json:"message"`
 }
 
 type InstallTask struct {
-	ID        string     `json:"id"`
-	URL       string     `json:"url"`
-	Name      string     `json:"name"`
-	Path      string     `json:"path"`
-	Type      string     `json:"type,omitempty"`
-	Status    string     `json:"status"` // pending, downloading, installing, completed, failed, cancelled
-	Progress  float64    `json:"progress"`
-	Error     string     `json:"error,omitempty"`
-	StartTime time.Time  `json:"startTime"`
-	EndTime   *time.Time `json:"endTime,omitempty"`
+	ID              string                   `json:"id"`
+	URL             string                   `json:"url"`
+	Name            string                   `json:"name"`
+	Path            string                   `json:"path"`
+	OutputPath      string                   `json:"outputPath"`
+	Type            string                   `json:"type,omitempty"`
+	SHA256          string                   `json:"sha256,omitempty"`
+	Size            int64                    `json:"size,omitempty"`
+	Status          TaskState                `json:"status"`
+	Progress        float64                  `json:"progress"`
+	DownloadedBytes int64                    `json:"downloadedBytes"`
+	TotalBytes      int64                    `json:"totalBytes"`
+	BytesPerSecond  float64                  `json:"bytesPerSecond"`       // smoothed moving average
+	ETASeconds      int64                    `json:"etaSeconds,omitempty"` // 0 when unknown
+	Skipped         bool                     `json:"skipped,omitempty"`    // true when an existing file was kept
+	Priority        int                      `json:"priority"`
+	QueuePosition   int                      `json:"queuePosition,omitempty"` // 1-based position among pending tasks
+	Error           string                   `json:"error,omitempty"`
+	StartTime       time.Time                `json:"startTime"`
+	EndTime         *time.Time               `json:"endTime,omitempty"`
+	Timeline        []TaskPhase              `json:"timeline"` // state transitions in order
+	NotBefore       *time.Time               `json:"notBefore,omitempty"`
+	Window          string                   `json:"window,omitempty"`
+	ScheduledFor    *time.Time               `json:"scheduledFor,omitempty"` // next start time of a scheduled task
+	PresetID        string                   `json:"presetId,omitempty"`
+	Profile         string                   `json:"profile,omitempty"`
+	PostInstall     []config.PostInstallStep `json:"postInstall,omitempty"`
+	InstalledPaths  []string                 `json:"installedPaths,omitempty"` // files and directories created by the install
+	DependsOnTasks  []string                 `json:"dependsOnTasks,omitempty"` // tasks that must complete before this one starts
+	UpdateOf        string                   `json:"updateOf,omitempty"`       // installed resource this task updates
+
+	// seq orders tasks of the same priority (FIFO)
+	seq int64
+	// logs captures backend output and installer events
+	logs *taskLog
+	// window is the parsed Window
+	window *installWindow
+	// cancel stops the running installation (set while a worker runs the task)
+	cancel context.CancelFunc
+	// running is set until the worker of the task has exited
+	running bool
+	// resumeDownload continues a partial download instead of restarting it
+	resumeDownload bool
+	// previousInstall is the receipt of the version an update replaced
+	previousInstall *store.InstalledResource
+	// remote holds the revision headers of the download response
+	remote downloader.RemoteInfo
+	// fileSHA256 is the digest of the downloaded file, computed while verifying
+	fileSHA256 string
+	// last sample used for the transfer speed average
+	speedSampleTime  time.Time
+	speedSampleBytes int64
+}
+
+// Queue move request for reordering pending tasks
+type MoveTaskRequest struct {
+	Direction string `json:"direction"` // up, down, front
+}
+
+// Preset install request; all fields are optional
+type PresetInstallRequest struct {
+	Priority int `json:"priority,omitempty"`
+	// Profiles to install to; the active profile if empty
+	Profiles []string `json:"profiles,omitempty"`
+}
+
+// A single preset in an install plan
+type InstallPlanStep struct {
+	PresetID  string   `json:"presetId"`
+	Name      string   `json:"name"`
+	Profile   string   `json:"profile,omitempty"` // empty for presets with an explicit destination_path
+	Path      string   `json:"path"`
+	Action    string   `json:"action"` // install, skip, in_progress
+	Reason    string   `json:"reason,omitempty"`
+	TaskID    string   `json:"taskId,omitempty"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// Install plan of a preset or bundle and its dependencies, in install order
+type InstallPlanResponse struct {
+	PresetID string            `json:"presetId,omitempty"`
+	BundleID string            `json:"bundleId,omitempty"`
+	Steps    []InstallPlanStep `json:"steps"`
+}
+
+// Bundle with the install status of its presets
+type PresetBundleStatus struct {
+	config.PresetBundle
+	// Presets of the bundle and their dependencies, in install order
+	Presets        []BundlePresetStatus `json:"presets"`
+	TotalBytes     int64                `json:"totalBytes"`
+	InstalledBytes int64                `json:"installedBytes"`
+	InstalledCount int                  `json:"installedCount"`
+	Error          string               `json:"error,omitempty"` // set when the bundle cannot be resolved
+}
+
+// Install status of a single preset of a bundle
+type BundlePresetStatus struct {
+	PresetID  string `json:"presetId"`
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Installed bool   `json:"installed"`
+	TaskID    string `json:"taskId,omitempty"` // task currently installing the preset
+}
+
+type PresetBundlesResponse struct {
+	Bundles []PresetBundleStatus `json:"bundles"`
+}
+
+// Installed resources response data structure
+type InstalledResourcesResponse struct {
+	Resources []store.InstalledResource `json:"resources"`
+}
+
+// State of the background check of installed resources for updates
+type UpdateCheckStatus struct {
+	Running          bool       `json:"running"`
+	UpdatesAvailable int        `json:"updatesAvailable"`     // as of the last finished check
+	StartedAt        *time.Time `json:"startedAt,omitempty"`  // of the running or last check
+	FinishedAt       *time.Time `json:"finishedAt,omitempty"` // of the last finished check
+}
+
+// Update check state with the last result of each installed resource
+type UpdateCheckResponse struct {
+	UpdateCheckStatus
+	Resources []store.InstalledResource `json:"resources"`
+}
+
+// Result of uninstalling a resource
+type UninstallResponse struct {
+	ID             string   `json:"id"`
+	RemovedPaths   []string `json:"removedPaths"`
+	MissingPaths   []string `json:"missingPaths"` // recorded paths that were already gone
+	ReclaimedBytes int64    `json:"reclaimedBytes"`
+}
+
+// Catalog subscriptions response data structure
+type CatalogSubscriptionsResponse struct {
+	Subscriptions []config.SubscriptionStatus `json:"subscriptions"`
 }
 
 // Preset resource response data structure
 type PresetResourcesResponse struct {
-	Resources []config.PresetResource `json:"resources"`
+	Resources  []config.PresetResource `json:"resources"`
+	Total      int                     `json:"total"`                // presets matching the filters
+	NextCursor string                  `json:"nextCursor,omitempty"` // cursor of the next page, if any
 }
@@ -0,0 +1,431 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/downloader"
+	"paperspace-stable-diffusion-station/internal/store"
+	"paperspace-stable-diffusion-station/pkg/logger"
+)
+
+// Delay before the first update check so that startup is not slowed down
+const initialUpdateCheckDelay = time.Minute
+
+// Timeout of a single git ls-remote
+const gitRemoteTimeout = time.Minute
+
+// Name prefixes and suffixes of update files kept next to the installed ones.
+// Hidden names keep ComfyUI from loading them as models or custom nodes.
+const (
+	updateStagingSuffix   = ".update"
+	previousVersionSuffix = ".previous"
+)
+
+// stagingPath returns where the new version of path is downloaded to
+func stagingPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+updateStagingSuffix)
+}
+
+// previousPath returns where the replaced version of path is kept
+func previousPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+previousVersionSuffix)
+}
+
+// originalPath reverses previousPath
+func originalPath(previous string) string {
+	base := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(previous), "."), previousVersionSuffix)
+	return filepath.Join(filepath.Dir(previous), base)
+}
+
+// startUpdateChecker periodically checks installed resources for updates
+func startUpdateChecker(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		time.Sleep(initialUpdateCheckDelay)
+		runUpdateCheck()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runUpdateCheck()
+		}
+	}()
+}
+
+// State of the update check, shared by the periodic checker and the API
+var (
+	updateCheckMutex sync.Mutex
+	updateCheck      UpdateCheckStatus
+)
+
+// startUpdateCheck runs an update check in the background unless one is
+// already running, and returns the state right after starting it
+func startUpdateCheck() UpdateCheckStatus {
+	updateCheckMutex.Lock()
+	defer updateCheckMutex.Unlock()
+
+	if beginUpdateCheckLocked() {
+		go finishUpdateCheck()
+	}
+	return updateCheck
+}
+
+// runUpdateCheck runs an update check unless one is already running
+func runUpdateCheck() {
+	updateCheckMutex.Lock()
+	started := beginUpdateCheckLocked()
+	updateCheckMutex.Unlock()
+
+	if started {
+		finishUpdateCheck()
+	}
+}
+
+// beginUpdateCheckLocked marks an update check as running and reports false
+// if one already was.
+// updateCheckMutex must be held by the caller.
+func beginUpdateCheckLocked() bool {
+	if updateCheck.Running {
+		return false
+	}
+	now := time.Now()
+	updateCheck.Running = true
+	updateCheck.StartedAt = &now
+	return true
+}
+
+// finishUpdateCheck checks every installed resource and records the result
+// of a check started by beginUpdateCheckLocked
+func finishUpdateCheck() {
+	available := checkAllForUpdates()
+	now := time.Now()
+
+	updateCheckMutex.Lock()
+	defer updateCheckMutex.Unlock()
+	updateCheck.Running = false
+	updateCheck.UpdatesAvailable = available
+	updateCheck.FinishedAt = &now
+}
+
+// currentUpdateCheck returns the state of the update check
+func currentUpdateCheck() UpdateCheckStatus {
+	updateCheckMutex.Lock()
+	defer updateCheckMutex.Unlock()
+
+	return updateCheck
+}
+
+// checkAllForUpdates compares every installed resource against its source
+func checkAllForUpdates() int {
+	available := 0
+	for _, resource := range installStore.Installed() {
+		status := checkForUpdate(resource)
+		if status.Error != "" {
+			logger.Debug("Update check of %s failed: %s", resource.ID, status.Error)
+		}
+		if status.Available {
+			available++
+		}
+		if err := installStore.SetUpdateStatus(resource.ID, status); err != nil {
+			logger.Error(err, "Failed to record update status of %s", resource.ID)
+		}
+	}
+	return available
+}
+
+// checkForUpdate compares an installed resource against its source: the latest
+// commit for git, newer version IDs for Civitai and the ETag or Last-Modified
+// of the file for other hosts such as Hugging Face
+func checkForUpdate(resource store.InstalledResource) store.UpdateStatus {
+	status := store.UpdateStatus{CheckedAt: time.Now()}
+
+	if downloader.IsGitURL(resource.URL) {
+		ctx, cancel := context.WithTimeout(context.Background(), gitRemoteTimeout)
+		defer cancel()
+
+		latest, err := downloader.GitRemoteRevision(ctx, resource.URL)
+		if err != nil {
+			status.Error = err.Error()
+			return status
+		}
+		status.LatestRevision = latest
+		status.Available = resource.Revision != "" && latest != resource.Revision
+		return status
+	}
+
+	if versionID, ok := downloader.CivitaiVersionID(resource.URL); ok {
+		latest, err := downloader.CivitaiLatestVersion(versionID)
+		if err != nil {
+			status.Error = err.Error()
+			return status
+		}
+		status.LatestRevision = latest
+		if newerVersionID(latest, versionID) {
+			status.Available = true
+			status.URL = downloader.CivitaiDownloadURL(latest)
+		}
+		return status
+	}
+
+	info, err := downloader.RemoteMetadata(resource.URL)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.LatestRevision = info.Revision
+	status.ETag = info.ETag
+	status.LastModified = info.LastModified
+
+	// The ETag identifies the file content (x-linked-etag on Hugging Face);
+	// the repository commit changes with any file and is not compared
+	switch {
+	case resource.ETag != "" && info.ETag != "":
+		status.Available = resource.ETag != info.ETag
+	case resource.LastModified != "" && info.LastModified != "":
+		status.Available = resource.LastModified != info.LastModified
+	}
+	return status
+}
+
+// newerVersionID reports whether the numeric version ID latest is above current
+func newerVersionID(latest, current string) bool {
+	if len(latest) != len(current) {
+		return len(latest) > len(current)
+	}
+	return latest > current
+}
+
+// swapInUpdate moves the installed version aside and the verified new version
+// into place. The replaced paths are kept for rollback. The renames are not
+// atomic as a whole: when one fails, the paths already moved aside are moved
+// back before the error is returned.
+func swapInUpdate(task *InstallTask) error {
+	current, exists := installStore.GetInstalled(task.UpdateOf)
+	if !exists {
+		return fmt.Errorf("installed resource %s not found", task.UpdateOf)
+	}
+	finalPath := downloader.GenerateOutputPath(task.Path, task.URL, task.Name)
+
+	// Only one previous version is kept
+	if current.Previous != nil {
+		for _, path := range current.Previous.Paths {
+			if isWithinDir(path, current.Previous.Path) {
+				os.RemoveAll(path)
+			}
+		}
+	}
+
+	previous := current
+	previous.Update = nil
+	previous.Previous = nil
+	previous.Paths = make([]string, 0, len(current.Paths))
+	for _, path := range current.Paths {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		backup := previousPath(path)
+		os.RemoveAll(backup)
+		if err := os.Rename(path, backup); err != nil {
+			return swapFailed(fmt.Errorf("failed to move %s aside: %v", path, err), previous.Paths)
+		}
+		previous.Paths = append(previous.Paths, backup)
+	}
+
+	if err := os.Rename(task.OutputPath, finalPath); err != nil {
+		return swapFailed(fmt.Errorf("failed to move new version into place: %v", err), previous.Paths)
+	}
+	task.logs.Add("installer", "Swapped in new version at %s, previous version kept for rollback", finalPath)
+
+	installTasksMutex.Lock()
+	task.OutputPath = finalPath
+	task.InstalledPaths = []string{finalPath}
+	task.previousInstall = &previous
+	installTasksMutex.Unlock()
+	return nil
+}
+
+// swapFailed moves the paths set aside by a failed swap back and reports
+// the paths that could not be restored along with the error
+func swapFailed(err error, backups []string) error {
+	if restored := restorePaths(backups); len(restored) < len(backups) {
+		return fmt.Errorf("%v; restored only %d of %d replaced paths, the rest are kept as %s files", err, len(restored), len(backups), previousVersionSuffix)
+	}
+	return err
+}
+
+// rollbackFailedUpdate restores the previous version after an update failed
+// once the new version had already been swapped in
+func rollbackFailedUpdate(task *InstallTask) {
+	installTasksMutex.RLock()
+	previous := task.previousInstall
+	newPaths := append([]string{}, task.InstalledPaths...)
+	installTasksMutex.RUnlock()
+	if previous == nil {
+		return
+	}
+
+	for _, path := range newPaths {
+		if isWithinDir(path, task.Path) {
+			os.RemoveAll(path)
+		}
+	}
+	restorePaths(previous.Paths)
+	task.logs.Add("installer", "Restored the previous version after the failed update")
+}
+
+// restorePaths moves kept previous versions back to their original paths
+func restorePaths(backups []string) []string {
+	restored := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		original := originalPath(backup)
+		if err := os.Rename(backup, original); err != nil {
+			logger.Error(err, "Failed to restore %s", original)
+			continue
+		}
+		restored = append(restored, original)
+	}
+	return restored
+}
+
+// CheckUpdatesHandler starts checking all installed resources for updates in
+// the background; GetUpdateCheckHandler reports when it is done
+func CheckUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	response := UpdateCheckResponse{UpdateCheckStatus: startUpdateCheck(), Resources: installStore.Installed()}
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetUpdateCheckHandler returns the state of the update check with the last
+// result recorded for each installed resource
+func GetUpdateCheckHandler(w http.ResponseWriter, r *http.Request) {
+	response := UpdateCheckResponse{UpdateCheckStatus: currentUpdateCheck(), Resources: installStore.Installed()}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateInstalledHandler downloads the new version of an installed resource
+// next to the current one; the task swaps it in once it is verified
+func UpdateInstalledHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	resource, exists := installStore.GetInstalled(id)
+	if !exists {
+		http.Error(w, "Installed resource not found", http.StatusNotFound)
+		return
+	}
+
+	url := resource.URL
+	if resource.Update != nil && resource.Update.URL != "" {
+		url = resource.Update.URL
+	}
+
+	task, statusCode, err := newInstallTask(InstallRequest{
+		URL:      url,
+		Name:     resource.Name,
+		Path:     resource.Path,
+		Type:     resource.Type,
+		PresetID: resource.PresetID,
+		Profile:  resource.Profile,
+		// The update replaces the resource where it is installed now
+		AllowTypeMismatch: true,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+	task.UpdateOf = id
+	task.OutputPath = stagingPath(task.OutputPath)
+
+	installTasksMutex.Lock()
+	if existing := findActiveDuplicateLocked(task.OutputPath); existing != nil {
+		installTasksMutex.Unlock()
+		writeInstallResponse(w, http.StatusConflict, InstallResponse{
+			TaskID:  existing.ID,
+			Status:  existing.Status,
+			Message: "An installation to the same output path is already in progress",
+		})
+		return
+	}
+
+	// A staging file left by an earlier failed update is not resumed
+	os.RemoveAll(task.OutputPath)
+	task.logs.Add("installer", "Created update task for %s: %s -> %s", id, task.URL, task.OutputPath)
+	enqueueTaskLocked(task)
+	installTasksMutex.Unlock()
+
+	writeInstallResponse(w, http.StatusAccepted, InstallResponse{
+		TaskID:  task.ID,
+		Status:  task.Status,
+		Message: "Update task created successfully",
+	})
+}
+
+// RollbackInstalledHandler brings back the version replaced by the last update
+func RollbackInstalledHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	resource, exists := installStore.GetInstalled(id)
+	if !exists {
+		http.Error(w, "Installed resource not found", http.StatusNotFound)
+		return
+	}
+	if resource.Previous == nil {
+		http.Error(w, "No previous version to roll back to", http.StatusConflict)
+		return
+	}
+
+	installTasksMutex.RLock()
+	for _, task := range installTasks {
+		if !task.Status.IsTerminal() && task.UpdateOf == id {
+			installTasksMutex.RUnlock()
+			http.Error(w, fmt.Sprintf("Task %s is updating %s", task.ID, id), http.StatusConflict)
+			return
+		}
+	}
+	installTasksMutex.RUnlock()
+
+	for _, path := range resource.Paths {
+		if !isWithinDir(path, resource.Path) {
+			http.Error(w, fmt.Sprintf("Refusing to remove %s outside of %s", path, resource.Path), http.StatusInternalServerError)
+			return
+		}
+		if err := os.RemoveAll(path); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to remove %s: %v", path, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	restored := *resource.Previous
+	restored.Paths = restorePaths(resource.Previous.Paths)
+	if err := installStore.PutInstalled(restored); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update install records: %v", err), http.StatusInternalServerError)
+		return
+	}
+	exportLockFile(restored)
+	logger.Info("Rolled back %s to its previous version", id)
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(restored); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/store"
+)
+
+// metadataServer answers HEAD requests with the given ETag and Last-Modified
+func metadataServer(t *testing.T, etag, lastModified string, status int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		if lastModified != "" {
+			w.Header().Set("Last-Modified", lastModified)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckForUpdate(t *testing.T) {
+	tests := []struct {
+		name          string
+		installed     store.InstalledResource
+		etag          string
+		lastModified  string
+		status        int
+		wantAvailable bool
+		wantErr       bool
+	}{
+		{name: "same ETag", installed: store.InstalledResource{ETag: "a"}, etag: `"a"`, status: http.StatusOK},
+		{name: "new ETag", installed: store.InstalledResource{ETag: "a"}, etag: `"b"`, status: http.StatusOK, wantAvailable: true},
+		{name: "new Last-Modified", installed: store.InstalledResource{LastModified: "Mon, 01 Jan 2024 00:00:00 GMT"}, lastModified: "Tue, 02 Jan 2024 00:00:00 GMT", status: http.StatusOK, wantAvailable: true},
+		{name: "nothing to compare", installed: store.InstalledResource{}, etag: `"b"`, status: http.StatusOK},
+		{name: "source gone", installed: store.InstalledResource{ETag: "a"}, status: http.StatusNotFound, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := metadataServer(t, tt.etag, tt.lastModified, tt.status)
+			resource := tt.installed
+			resource.URL = server.URL + "/model.safetensors"
+
+			status := checkForUpdate(resource)
+			if (status.Error != "") != tt.wantErr {
+				t.Fatalf("error = %q, wantErr %v", status.Error, tt.wantErr)
+			}
+			if status.Available != tt.wantAvailable {
+				t.Fatalf("available = %v, want %v", status.Available, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestNewerVersionID(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"101", "100", true},
+		{"100", "100", false},
+		{"99", "100", false},
+		{"1000", "999", true},
+	}
+	for _, tt := range tests {
+		if got := newerVersionID(tt.latest, tt.current); got != tt.want {
+			t.Errorf("newerVersionID(%s, %s) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestCheckUpdatesRunsInTheBackground(t *testing.T) {
+	withInstallStore(t, "")
+	updateCheckMutex.Lock()
+	saved := updateCheck
+	updateCheck = UpdateCheckStatus{}
+	updateCheckMutex.Unlock()
+	t.Cleanup(func() {
+		updateCheckMutex.Lock()
+		updateCheck = saved
+		updateCheckMutex.Unlock()
+	})
+
+	// The source answers only once the test lets it, so the check is still
+	// running when the handler returns
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("ETag", `"new"`)
+	}))
+	t.Cleanup(server.Close)
+	if err := installStore.PutInstalled(store.InstalledResource{ID: "model", URL: server.URL + "/model.safetensors", ETag: "old"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	CheckUpdatesHandler(w, httptest.NewRequest(http.MethodPost, "/installer/installed/check-updates", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST = %d %s, want 202", w.Code, w.Body)
+	}
+	var started UpdateCheckResponse
+	if err := json.NewDecoder(w.Body).Decode(&started); err != nil {
+		t.Fatal(err)
+	}
+	if !started.Running || started.StartedAt == nil {
+		t.Fatalf("check not reported as running: %+v", started.UpdateCheckStatus)
+	}
+
+	// A second request while the check runs does not start another one
+	w = httptest.NewRecorder()
+	CheckUpdatesHandler(w, httptest.NewRequest(http.MethodPost, "/installer/installed/check-updates", nil))
+	var again UpdateCheckResponse
+	if err := json.NewDecoder(w.Body).Decode(&again); err != nil {
+		t.Fatal(err)
+	}
+	if !again.StartedAt.Equal(*started.StartedAt) {
+		t.Fatal("a second check was started while the first was running")
+	}
+
+	close(release)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		w = httptest.NewRecorder()
+		GetUpdateCheckHandler(w, httptest.NewRequest(http.MethodGet, "/installer/installed/check-updates", nil))
+		var status UpdateCheckResponse
+		if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		if !status.Running {
+			if status.UpdatesAvailable != 1 || status.FinishedAt == nil {
+				t.Fatalf("finished check = %+v, want 1 update", status.UpdateCheckStatus)
+			}
+			if len(status.Resources) != 1 || status.Resources[0].Update == nil || !status.Resources[0].Update.Available {
+				t.Fatalf("update status not recorded: %+v", status.Resources)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("update check did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSwapInUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		staged     bool // the verified new version exists
+		wantErr    bool
+		wantActive string // content at the install path afterwards
+	}{
+		{name: "new version is swapped in", staged: true, wantActive: "new"},
+		{name: "failed swap restores the installed version", wantErr: true, wantActive: "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withInstallStore(t, "")
+			dir := t.TempDir()
+			installed := filepath.Join(dir, "model.safetensors")
+			if err := os.WriteFile(installed, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := installStore.PutInstalled(store.InstalledResource{ID: "model", Path: dir, Paths: []string{installed}}); err != nil {
+				t.Fatal(err)
+			}
+			task := &InstallTask{
+				ID:         "update",
+				URL:        "https://example.com/model.safetensors",
+				Name:       "model.safetensors",
+				Path:       dir,
+				OutputPath: stagingPath(installed),
+				UpdateOf:   "model",
+				logs:       newTaskLog(),
+			}
+			if tt.staged {
+				if err := os.WriteFile(task.OutputPath, []byte("new"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := swapInUpdate(task)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("swapInUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if content, _ := os.ReadFile(installed); string(content) != tt.wantActive {
+				t.Fatalf("installed content = %q, want %q", content, tt.wantActive)
+			}
+			_, backupErr := os.Stat(previousPath(installed))
+			if tt.wantErr {
+				if !os.IsNotExist(backupErr) {
+					t.Fatal("backup left behind after a failed swap")
+				}
+				return
+			}
+			if backupErr != nil {
+				t.Fatalf("previous version not kept: %v", backupErr)
+			}
+			if task.previousInstall == nil || len(task.previousInstall.Paths) != 1 || task.OutputPath != installed {
+				t.Fatalf("task after swap: output %s, previous %+v", task.OutputPath, task.previousInstall)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+// Exit codes of the catalog subcommand
+const (
+	exitOK     = 0
+	exitIssues = 1
+	exitUsage  = 2
+)
+
+// RunCatalogCommand runs `server catalog <command>` and returns the exit code
+func RunCatalogCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 || args[0] != "lint" {
+		fmt.Fprintln(os.Stderr, "Usage: server catalog lint [files...]")
+		return exitUsage
+	}
+	return lintCatalog(cfg, args[1:])
+}
+
+// lintCatalog validates the given catalog and destinations files, or the
+// files of the configured catalog directory if none are given
+func lintCatalog(cfg *config.Config, files []string) int {
+	config.SetDestinationsFile(cfg.DestinationsFile)
+	config.SetActiveProfile(cfg.DestinationProfile)
+	profileRoots, err := config.ParseProfileRoots(cfg.ProfileRoots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid PROFILE_ROOTS: %v\n", err)
+		return exitUsage
+	}
+	config.SetProfileRoots(profileRoots)
+	config.SetPathVariables(cfg.PathVariables)
+	if len(files) == 0 {
+		catalogFiles, err := config.CatalogFiles(cfg.CatalogDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list catalog files: %v\n", err)
+			return exitUsage
+		}
+		files = catalogFiles
+	}
+
+	issues := config.LintFiles(files...)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "%d issues found\n", len(issues))
+		return exitIssues
+	}
+	fmt.Printf("%d files OK\n", len(files))
+	return exitOK
+}
@@ -11,8 +11,15 @@ func ShowHelp() {
 	fmt.Println("")
 	fmt.Println("Usage:")
 	fmt.Println("  server [options]")
+	fmt.Println("  server [options] catalog lint [files...]")
 	fmt.Println("")
 	fmt.Println("Options:")
+	fmt.Println("  -config string")
+	fmt.Println("        Config file path (default: STATION_CONFIG env var)")
+	fmt.Println("  -print-config")
+	fmt.Println("        Show the effective configuration and where each value comes from")
+	fmt.Println("  -host string")
+	fmt.Println("        Address to listen on (default: all interfaces or STATION_HOST env var)")
 	fmt.Println("  -port string")
 	fmt.Println("        Port to run the server on (default: 8080 or PORT env var)")
 	fmt.Println("  -log-level string")
@@ -21,16 +28,30 @@ func ShowHelp() {
 	fmt.Println("        Database file path (default: ./data.db or DB_PATH env var)")
 	fmt.Println("  -base-url, --base-url string")
 	fmt.Println("        Base URL for the server (default: empty or BASE_URL env var)")
+	fmt.Println("  -<setting> string")
+	fmt.Println("        Every setting has a flag named after its environment variable,")
+	fmt.Println("        e.g. -max-concurrent-installs for MAX_CONCURRENT_INSTALLS")
+	fmt.Println("        (STATION_HOST is -host)")
 	fmt.Println("  -help")
 	fmt.Println("        Show this help message")
 	fmt.Println("  -version")
 	fmt.Println("        Show version information")
 	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  catalog lint [files...]")
+	fmt.Println("        Validate catalog and destinations files (default: files in CATALOG_DIR).")
+	fmt.Println("        Exits with 1 if issues are found")
+	fmt.Println("")
 	fmt.Println("Environment Variables:")
-	fmt.Println("  PORT        Port to run the server on")
-	fmt.Println("  LOG_LEVEL   Log level (debug, info, warn, error)")
-	fmt.Println("  DB_PATH     Database file path")
-	fmt.Println("  BASE_URL    Base URL for the server")
+	fmt.Println("  STATION_CONFIG  Config file path")
+	fmt.Println("  STATION_HOST    Address to listen on")
+	fmt.Println("  PORT            Port to run the server on")
+	fmt.Println("  LOG_LEVEL       Log level (debug, info, warn, error)")
+	fmt.Println("  DB_PATH         Database file path")
+	fmt.Println("  BASE_URL        Base URL for the server")
+	fmt.Println("")
+	fmt.Println("Settings are taken from flags, then environment variables, then the")
+	fmt.Println("config file, then defaults. Run with -print-config to see them all.")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  server -port 3000")
@@ -38,6 +59,7 @@ func ShowHelp() {
 	fmt.Println("  server --base-url /myapp")
 	fmt.Println("  server -base-url /myapp")
 	fmt.Println("  PORT=3000 BASE_URL=/myapp server")
+	fmt.Println("  server --config /storage/station/config.yaml --print-config")
 }
 
 // ShowVersion displays version information
@@ -1,30 +1,76 @@
 package handlers
 
 import (
-	"strings"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"paperspace-stable-diffusion-station/internal/config"
 )
 
-// ProcessConfig processes command line arguments and applies them to the config
-func ProcessConfig(cfg *config.Config, port, logLevel, dbPath, baseURL *string) {
-	// コマンドライン引数で上書き
-	if *port != "" {
-		cfg.Port = *port
+// RegisterSettingFlags defines a command line flag for every setting with an
+// environment variable. The returned function gives the values of the flags
+// set on the command line by setting key, for config.Load.
+func RegisterSettingFlags(fs *flag.FlagSet) func() map[string]string {
+	keys := make(map[string]string)
+	for _, setting := range config.Settings {
+		if setting.Env == "" {
+			continue
+		}
+		fs.String(setting.Flag(), "", fmt.Sprintf("%s (default: %q or %s env var)", setting.Usage, setting.Default, setting.Env))
+		keys[setting.Flag()] = setting.Key
 	}
-	if *logLevel != "" {
-		cfg.LogLevel = *logLevel
+
+	return func() map[string]string {
+		values := make(map[string]string)
+		fs.Visit(func(f *flag.Flag) {
+			if key, exists := keys[f.Name]; exists {
+				values[key] = f.Value.String()
+			}
+		})
+		return values
 	}
-	if *dbPath != "" {
-		cfg.DBPath = *dbPath
+}
+
+// PrintConfig shows the effective settings and where each one came from
+func PrintConfig(cfg *config.Config) {
+	// Credential files may use path variables of the config file
+	config.SetPathVariables(cfg.PathVariables)
+
+	if cfg.File != "" {
+		fmt.Printf("Config file: %s\n\n", cfg.File)
+	} else {
+		fmt.Printf("Config file: none (set --config or %s)\n\n", config.ConfigFileEnv)
 	}
-	if *baseURL != "" {
-		// Windowsのパス変換を防ぐため、BaseURLを正規化
-		normalizedBaseURL := *baseURL
-		normalizedBaseURL = strings.TrimPrefix(normalizedBaseURL, "C:/Program Files/Git")
-		if !strings.HasPrefix(normalizedBaseURL, "/") {
-			normalizedBaseURL = "/" + normalizedBaseURL
-		}
-		cfg.BaseURL = normalizedBaseURL
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	for _, setting := range config.Settings {
+		value := settingValue(cfg, setting)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, value, cfg.Sources[setting.Key])
+	}
+
+	for _, variable := range config.GetPathVariables() {
+		fmt.Fprintf(w, "variables.%s\t%s\t%s\n", variable.Name, variable.Value, variable.Source)
+	}
+	w.Flush()
+}
+
+// settingValue formats the effective value of a setting. Credentials show
+// their reference and whether it resolves to a token, never the token itself.
+func settingValue(cfg *config.Config, setting config.Setting) string {
+	value := cfg.Value(setting.Key)
+	if !setting.Credential || value == "" {
+		return value
+	}
+
+	token, err := config.ResolveCredential(value)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("%s (%v)", value, err)
+	case token == "":
+		return fmt.Sprintf("%s (not set)", value)
 	}
+	return fmt.Sprintf("%s (set)", value)
 }
@@ -0,0 +1,273 @@
+package postinstall
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsArchive reports whether a path looks like an archive that can be extracted
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits on what a single archive may unpack, so that a hostile or broken
+// archive cannot fill the disk
+var (
+	maxExtractedBytes   int64 = 64 << 30
+	maxExtractedEntries       = 100000
+)
+
+// extractBudget tracks how much of the limits an extraction has used
+type extractBudget struct {
+	bytes   int64
+	entries int
+}
+
+func newExtractBudget() *extractBudget {
+	return &extractBudget{bytes: maxExtractedBytes, entries: maxExtractedEntries}
+}
+
+// addEntry counts one archive entry against the entry limit
+func (b *extractBudget) addEntry() error {
+	b.entries--
+	if b.entries < 0 {
+		return fmt.Errorf("archive has more than %d entries", maxExtractedEntries)
+	}
+	return nil
+}
+
+// ExtractArchive unpacks a zip or tar(.gz) archive into destDir and returns
+// the top-level entries it created. The archive is unpacked into a staging
+// directory first and its entries are moved into destDir only if none of
+// them exists there yet, so files that were already present are never
+// overwritten and a failed extraction leaves nothing behind.
+func ExtractArchive(archivePath, destDir string) ([]string, error) {
+	var extract func(archivePath, destDir string, budget *extractBudget) ([]string, error)
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		extract = extractZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		extract = func(archivePath, destDir string, budget *extractBudget) ([]string, error) {
+			return extractTar(archivePath, destDir, true, budget)
+		}
+	case strings.HasSuffix(lower, ".tar"):
+		extract = func(archivePath, destDir string, budget *extractBudget) ([]string, error) {
+			return extractTar(archivePath, destDir, false, budget)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", filepath.Base(archivePath))
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, err
+	}
+	// The staging directory sits next to the destination so entries can be renamed into place
+	staging, err := os.MkdirTemp(destDir, ".extract-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	defer os.RemoveAll(staging)
+
+	staged, err := extract(archivePath, staging, newExtractBudget())
+	if err != nil {
+		return nil, err
+	}
+	return moveIntoPlace(staged, staging, destDir)
+}
+
+// moveIntoPlace renames the staged top-level entries into destDir. Nothing is
+// moved if any of them already exists, and entries moved before a failed
+// rename are moved back.
+func moveIntoPlace(staged []string, staging, destDir string) ([]string, error) {
+	targets := make([]string, len(staged))
+	for i, path := range staged {
+		targets[i] = filepath.Join(destDir, filepath.Base(path))
+		if _, err := os.Lstat(targets[i]); err == nil {
+			return nil, fmt.Errorf("%s already exists, refusing to overwrite it", targets[i])
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	for i, path := range staged {
+		if err := os.Rename(path, targets[i]); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				os.Rename(targets[j], staged[j])
+			}
+			return nil, fmt.Errorf("failed to move %s into place: %v", filepath.Base(path), err)
+		}
+	}
+	return targets, nil
+}
+
+func extractZip(archivePath, destDir string, budget *extractBudget) ([]string, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer reader.Close()
+
+	created := newTopLevelSet(destDir)
+	for _, file := range reader.File {
+		if err := budget.addEntry(); err != nil {
+			return created.list(), err
+		}
+		target, err := safeJoin(destDir, file.Name)
+		if err != nil {
+			return created.list(), err
+		}
+		created.add(target)
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return created.list(), err
+			}
+			continue
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return created.list(), fmt.Errorf("failed to read %s: %v", file.Name, err)
+		}
+		err = writeFile(target, src, file.Mode(), budget)
+		src.Close()
+		if err != nil {
+			return created.list(), err
+		}
+	}
+	return created.list(), nil
+}
+
+func extractTar(archivePath, destDir string, gzipped bool, budget *extractBudget) ([]string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	var stream io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+		}
+		defer gz.Close()
+		stream = gz
+	}
+
+	created := newTopLevelSet(destDir)
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return created.list(), fmt.Errorf("failed to read archive: %v", err)
+		}
+		if err := budget.addEntry(); err != nil {
+			return created.list(), err
+		}
+
+		target, err := safeJoin(destDir, header.Name)
+		if err != nil {
+			return created.list(), err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			created.add(target)
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return created.list(), err
+			}
+		case tar.TypeReg:
+			created.add(target)
+			if err := writeFile(target, reader, os.FileMode(header.Mode), budget); err != nil {
+				return created.list(), err
+			}
+		default:
+			// Links and special files are not extracted
+		}
+	}
+	return created.list(), nil
+}
+
+// safeJoin joins an archive entry name to destDir and rejects entries that
+// would escape it
+func safeJoin(destDir, name string) (string, error) {
+	target := filepath.Join(destDir, name)
+	if target != filepath.Clean(destDir) && !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry escapes destination: %s", name)
+	}
+	return target, nil
+}
+
+// writeFile writes a staged file from src, using up the byte budget
+func writeFile(target string, src io.Reader, mode os.FileMode, budget *extractBudget) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if mode&0600 == 0 {
+		mode = 0644
+	}
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", target, err)
+	}
+	defer dst.Close()
+
+	// Copy one byte more than allowed to tell a full budget from an exceeded one
+	written, err := io.Copy(dst, io.LimitReader(src, budget.bytes+1))
+	budget.bytes -= written
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", target, err)
+	}
+	if budget.bytes < 0 {
+		return fmt.Errorf("archive unpacks to more than %d bytes", maxExtractedBytes)
+	}
+	return nil
+}
+
+// topLevelSet collects the distinct top-level entries created under a directory.
+// Entries that already existed before extraction are not reported.
+type topLevelSet struct {
+	root  string
+	seen  map[string]bool
+	order []string
+}
+
+func newTopLevelSet(root string) *topLevelSet {
+	return &topLevelSet{root: filepath.Clean(root), seen: make(map[string]bool)}
+}
+
+func (s *topLevelSet) add(path string) {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil || rel == "." {
+		return
+	}
+	top := filepath.Join(s.root, strings.Split(rel, string(os.PathSeparator))[0])
+	if s.seen[top] {
+		return
+	}
+	s.seen[top] = true
+	if _, err := os.Lstat(top); os.IsNotExist(err) {
+		s.order = append(s.order, top)
+	}
+}
+
+func (s *topLevelSet) list() []string {
+	return s.order
+}
@@ -0,0 +1,172 @@
+package postinstall
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "models")
+
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{name: "file", entry: "model.safetensors", want: filepath.Join(dest, "model.safetensors")},
+		{name: "nested file", entry: "node/nodes.py", want: filepath.Join(dest, "node", "nodes.py")},
+		{name: "directory", entry: "node/", want: filepath.Join(dest, "node")},
+		{name: "destination itself", entry: "./", want: dest},
+		{name: "dot segments inside", entry: "node/../model.bin", want: filepath.Join(dest, "model.bin")},
+		{name: "absolute path stays inside", entry: "/etc/passwd", want: filepath.Join(dest, "etc", "passwd")},
+		{name: "parent directory", entry: "../escape.txt", wantErr: true},
+		{name: "nested escape", entry: "node/../../escape.txt", wantErr: true},
+		{name: "sibling with the same prefix", entry: "../models-other/file", wantErr: true},
+		{name: "parent only", entry: "..", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := safeJoin(dest, tt.entry)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("safeJoin(%q) = %q, want an error", tt.entry, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("safeJoin(%q) error = %v", tt.entry, err)
+			}
+			if got != tt.want {
+				t.Fatalf("safeJoin(%q) = %q, want %q", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
+
+// writeZip creates a zip archive with the given files and contents
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	writer := zip.NewWriter(out)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		existing   map[string]string // files in the destination before extraction
+		maxBytes   int64
+		maxEntries int
+		wantErr    string
+		wantPaths  []string
+	}{
+		{
+			name:      "new entries are moved into place",
+			files:     map[string]string{"node/nodes.py": "print()"},
+			wantPaths: []string{"node"},
+		},
+		{
+			name:     "existing files are not overwritten",
+			files:    map[string]string{"node/nodes.py": "print()", "other.txt": "new"},
+			existing: map[string]string{"other.txt": "mine"},
+			wantErr:  "refusing to overwrite",
+		},
+		{
+			name:     "size limit",
+			files:    map[string]string{"big.bin": strings.Repeat("x", 100)},
+			maxBytes: 99,
+			wantErr:  "more than 99 bytes",
+		},
+		{
+			name:      "exactly at the size limit",
+			files:     map[string]string{"big.bin": strings.Repeat("x", 100)},
+			maxBytes:  100,
+			wantPaths: []string{"big.bin"},
+		},
+		{
+			name:       "entry limit",
+			files:      map[string]string{"a": "", "b": "", "c": ""},
+			maxEntries: 2,
+			wantErr:    "more than 2 entries",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savedBytes, savedEntries := maxExtractedBytes, maxExtractedEntries
+			t.Cleanup(func() { maxExtractedBytes, maxExtractedEntries = savedBytes, savedEntries })
+			if tt.maxBytes > 0 {
+				maxExtractedBytes = tt.maxBytes
+			}
+			if tt.maxEntries > 0 {
+				maxExtractedEntries = tt.maxEntries
+			}
+
+			dest := t.TempDir()
+			for name, content := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dest, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			archive := filepath.Join(t.TempDir(), "node.zip")
+			writeZip(t, archive, tt.files)
+
+			created, err := ExtractArchive(archive, dest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				// Nothing is left behind and existing files keep their content
+				entries, _ := os.ReadDir(dest)
+				if len(entries) != len(tt.existing) {
+					t.Fatalf("destination has %d entries after a failed extraction, want %d", len(entries), len(tt.existing))
+				}
+				for name, content := range tt.existing {
+					if data, _ := os.ReadFile(filepath.Join(dest, name)); string(data) != content {
+						t.Fatalf("%s = %q, want %q", name, data, content)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(created) != len(tt.wantPaths) {
+				t.Fatalf("created = %v, want %v", created, tt.wantPaths)
+			}
+			for i, name := range tt.wantPaths {
+				if created[i] != filepath.Join(dest, name) {
+					t.Fatalf("created = %v, want %v", created, tt.wantPaths)
+				}
+				if _, err := os.Stat(created[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			entries, _ := os.ReadDir(dest)
+			if len(entries) != len(tt.wantPaths) {
+				t.Fatalf("destination has %d entries, want %d (staging left behind?)", len(entries), len(tt.wantPaths))
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package postinstall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"paperspace-stable-diffusion-station/internal/config"
+)
+
+// DefaultTimeout is used for steps without a timeout
+const DefaultTimeout = 10 * time.Minute
+
+// commandStepsAllowed enables the command action, which runs arbitrary shell code
+var commandStepsAllowed atomic.Bool
+
+// SetCommandStepsAllowed enables or disables steps with action command
+func SetCommandStepsAllowed(allowed bool) {
+	commandStepsAllowed.Store(allowed)
+}
+
+// Environment describes the installed resource a step runs against
+type Environment struct {
+	TaskID     string
+	URL        string
+	InstallDir string // destination directory
+	OutputPath string // downloaded file, cloned repository or extracted directory
+}
+
+// Result describes what a step did
+type Result struct {
+	// Skipped is true when the step had nothing to do (e.g. no requirements.txt)
+	Skipped bool
+	// CreatedPaths lists files and directories created by the step
+	CreatedPaths []string
+}
+
+// LogFunc receives the output of a step
+type LogFunc func(format string, args ...interface{})
+
+// Timeout returns the configured timeout of a step
+func Timeout(step config.PostInstallStep) (time.Duration, error) {
+	if step.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(step.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", step.Timeout)
+	}
+	return timeout, nil
+}
+
+// Run executes a single post-install step with its timeout
+func Run(ctx context.Context, step config.PostInstallStep, env Environment, logf LogFunc) (Result, error) {
+	timeout, err := Timeout(step)
+	if err != nil {
+		return Result{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := runAction(ctx, step, env, logf)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("timed out after %s", timeout)
+	}
+	return result, err
+}
+
+func runAction(ctx context.Context, step config.PostInstallStep, env Environment, logf LogFunc) (Result, error) {
+	workDir := workingDirectory(env)
+
+	switch step.Action {
+	case "extract":
+		if !IsArchive(env.OutputPath) {
+			logf("%s is not an archive, skipping", env.OutputPath)
+			return Result{Skipped: true}, nil
+		}
+		created, err := ExtractArchive(env.OutputPath, env.InstallDir)
+		if err != nil {
+			return Result{}, err
+		}
+		logf("Extracted %d entries into %s", len(created), env.InstallDir)
+		if step.RemoveArchive {
+			if err := os.Remove(env.OutputPath); err != nil {
+				return Result{CreatedPaths: created}, fmt.Errorf("failed to remove archive: %v", err)
+			}
+		}
+		return Result{CreatedPaths: created}, nil
+
+	case "pip_requirements":
+		file := filepath.Join(workDir, defaultString(step.File, "requirements.txt"))
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			logf("%s not found, skipping", file)
+			return Result{Skipped: true}, nil
+		}
+		python := defaultString(step.Python, "python3")
+		return Result{}, runCommand(ctx, workDir, env, logf, python, "-m", "pip", "install", "-r", file)
+
+	case "python_script":
+		file := filepath.Join(workDir, defaultString(step.File, "install.py"))
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			logf("%s not found, skipping", file)
+			return Result{Skipped: true}, nil
+		}
+		python := defaultString(step.Python, "python3")
+		return Result{}, runCommand(ctx, workDir, env, logf, python, file)
+
+	case "chmod":
+		mode, err := strconv.ParseUint(step.Mode, 8, 32)
+		if err != nil {
+			return Result{}, fmt.Errorf("invalid mode %q", step.Mode)
+		}
+		if err := os.Chmod(env.OutputPath, os.FileMode(mode)); err != nil {
+			return Result{}, fmt.Errorf("chmod failed: %v", err)
+		}
+		logf("Changed mode of %s to %s", env.OutputPath, step.Mode)
+		return Result{}, nil
+
+	case "command":
+		if step.Command == "" {
+			return Result{}, fmt.Errorf("command is empty")
+		}
+		if !commandStepsAllowed.Load() {
+			return Result{}, fmt.Errorf("command steps are disabled (set ALLOW_COMMAND_STEPS=true to enable them)")
+		}
+		return Result{}, runCommand(ctx, workDir, env, logf, "sh", "-c", step.Command)
+
+	case "http":
+		return Result{}, callURL(ctx, step, env, logf)
+	}
+
+	return Result{}, fmt.Errorf("unknown action %q", step.Action)
+}
+
+// Validate checks that a step has a known action and valid options
+func Validate(step config.PostInstallStep) error {
+	if _, err := Timeout(step); err != nil {
+		return err
+	}
+
+	switch step.Action {
+	case "extract", "pip_requirements", "python_script":
+	case "chmod":
+		if _, err := strconv.ParseUint(step.Mode, 8, 32); err != nil {
+			return fmt.Errorf("invalid mode %q", step.Mode)
+		}
+	case "command":
+		if step.Command == "" {
+			return fmt.Errorf("command is empty")
+		}
+	case "http":
+		if step.URL == "" {
+			return fmt.Errorf("url is empty")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", step.Action)
+	}
+	return nil
+}
+
+// workingDirectory returns the installed directory, or the destination
+// directory when a single file was installed
+func workingDirectory(env Environment) string {
+	if info, err := os.Stat(env.OutputPath); err == nil && info.IsDir() {
+		return env.OutputPath
+	}
+	return env.InstallDir
+}
+
+// runCommand runs a command and forwards its output line by line
+func runCommand(ctx context.Context, workDir string, env Environment, logf LogFunc, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(),
+		"STATION_TASK_ID="+env.TaskID,
+		"STATION_URL="+env.URL,
+		"STATION_INSTALL_DIR="+env.InstallDir,
+		"STATION_OUTPUT_PATH="+env.OutputPath,
+	)
+
+	output := &lineWriter{logf: logf}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	logf("$ %s %s", name, strings.Join(args, " "))
+	err := cmd.Run()
+	output.Flush()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+	return nil
+}
+
+// callURL notifies a URL about the installed resource
+func callURL(ctx context.Context, step config.PostInstallStep, env Environment, logf LogFunc) error {
+	if step.URL == "" {
+		return fmt.Errorf("url is empty")
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"taskId":     env.TaskID,
+		"url":        env.URL,
+		"outputPath": env.OutputPath,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, defaultString(step.Method, "POST"), step.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	logf("%s %s -> %s", req.Method, step.URL, resp.Status)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("request failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func defaultString(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// lineWriter forwards written output to a LogFunc one line at a time
+type lineWriter struct {
+	logf    LogFunc
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		index := bytes.IndexAny(w.partial, "\r\n")
+		if index < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(w.partial[:index])); line != "" {
+			w.logf("%s", line)
+		}
+		w.partial = w.partial[index+1:]
+	}
+	return len(p), nil
+}
+
+// Flush forwards the trailing incomplete line, if any
+func (w *lineWriter) Flush() {
+	if line := strings.TrimSpace(string(w.partial)); line != "" {
+		w.logf("%s", line)
+	}
+	w.partial = nil
+}
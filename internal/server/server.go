@@ -3,11 +3,13 @@ package server
 import (
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 
 	"paperspace-stable-diffusion-station/internal/api"
 	"paperspace-stable-diffusion-station/internal/config"
+	"paperspace-stable-diffusion-station/internal/handler"
 	"paperspace-stable-diffusion-station/web"
 )
 
@@ -46,6 +48,9 @@ func (s *Server) SetupRoutes() {
 
 	log.Printf("API Path: %s, Root Path: %s, Strip Prefix: %s", apiPath, rootPath, stripPrefix)
 
+	// インストーラーの初期化
+	handler.Init(s.config)
+
 	// APIハンドラーの登録
 	s.mux.Handle(apiPath, http.StripPrefix(stripPrefix, api.NewRouter()))
 
@@ -57,8 +62,9 @@ func (s *Server) SetupRoutes() {
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	log.Printf("Starting server on port %s", s.config.Port)
-	return http.ListenAndServe(":"+s.config.Port, s.mux)
+	address := net.JoinHostPort(s.config.Host, s.config.Port)
+	log.Printf("Starting server on %s", address)
+	return http.ListenAndServe(address, s.mux)
 }
 
 // normalizeBaseURL normalizes the BaseURL by removing Windows path conversion
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockFileName is the name of the receipt export in each profile root
+const LockFileName = "station.lock"
+
+// lockFileVersion is bumped when the lock file layout changes
+const lockFileVersion = 1
+
+// LockFile lists the receipts of everything installed into a profile
+type LockFile struct {
+	Version     int                 `json:"version"`
+	GeneratedAt time.Time           `json:"generatedAt"`
+	Resources   []InstalledResource `json:"resources"`
+}
+
+// ExportLockFile writes the receipts selected by include to the station.lock
+// in dir, removing the file when no receipt is selected anymore
+func (s *Store) ExportLockFile(dir string, include func(InstalledResource) bool) error {
+	lock := LockFile{Version: lockFileVersion, GeneratedAt: time.Now(), Resources: make([]InstalledResource, 0)}
+	for _, resource := range s.Installed() {
+		if include(resource) {
+			lock.Resources = append(lock.Resources, resource)
+		}
+	}
+
+	path := filepath.Join(dir, LockFileName)
+	if len(lock.Resources) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
+		}
+		return nil
+	}
+
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportLockFile(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open("")
+	for _, resource := range []InstalledResource{
+		{ID: "a@comfyui", Profile: "comfyui"},
+		{ID: "b@comfyui", Profile: "comfyui"},
+		{ID: "c@forge", Profile: "forge"},
+	} {
+		if err := s.PutInstalled(resource); err != nil {
+			t.Fatal(err)
+		}
+	}
+	comfyui := func(r InstalledResource) bool { return r.Profile == "comfyui" }
+
+	if err := s.ExportLockFile(dir, comfyui); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, LockFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock LockFile
+	if err := json.Unmarshal(content, &lock); err != nil {
+		t.Fatal(err)
+	}
+	if lock.Version != lockFileVersion || len(lock.Resources) != 2 {
+		t.Fatalf("lock file = version %d with %d resources, want version %d with 2", lock.Version, len(lock.Resources), lockFileVersion)
+	}
+	for _, resource := range lock.Resources {
+		if resource.Profile != "comfyui" {
+			t.Fatalf("lock file lists %s of another profile", resource.ID)
+		}
+	}
+
+	// The file goes away with the last receipt
+	for _, id := range []string{"a@comfyui", "b@comfyui"} {
+		if err := s.DeleteInstalled(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.ExportLockFile(dir, comfyui); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("lock file kept without receipts: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFileName+".tmp")); !os.IsNotExist(err) {
+		t.Fatal("temporary file left behind")
+	}
+}
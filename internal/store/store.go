@@ -0,0 +1,300 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InstalledResource is the receipt of a completed install: what was installed
+// from where, and everything that has to be removed to uninstall it
+type InstalledResource struct {
+	ID               string    `json:"id"` // preset ID, or task ID for ad-hoc installs
+	PresetID         string    `json:"presetId,omitempty"`
+	Profile          string    `json:"profile,omitempty"` // destination profile of a preset install
+	TaskID           string    `json:"taskId"`
+	Name             string    `json:"name"`
+	Type             string    `json:"type,omitempty"`
+	URL              string    `json:"url"`
+	Revision         string    `json:"revision,omitempty"`     // git commit or repository commit of the file
+	ETag             string    `json:"etag,omitempty"`         // ETag of the downloaded file
+	LastModified     string    `json:"lastModified,omitempty"` // Last-Modified of the downloaded file
+	Path             string    `json:"path"`                   // destination directory
+	Paths            []string  `json:"paths"`                  // files and directories created by the install
+	Size             int64     `json:"size"`                   // total size of Paths in bytes
+	SHA256           string    `json:"sha256,omitempty"`       // digest of a single installed file
+	InstallerVersion string    `json:"installerVersion"`
+	InstalledAt      time.Time `json:"installedAt"`
+	// Update is the result of the last update check
+	Update *UpdateStatus `json:"update,omitempty"`
+	// Previous is the version replaced by the last update, kept for rollback
+	Previous *InstalledResource `json:"previous,omitempty"`
+}
+
+// UpdateStatus describes what the remote offers compared to an installed resource
+type UpdateStatus struct {
+	Available      bool      `json:"available"`
+	LatestRevision string    `json:"latestRevision,omitempty"`
+	ETag           string    `json:"etag,omitempty"`
+	LastModified   string    `json:"lastModified,omitempty"`
+	URL            string    `json:"url,omitempty"` // download URL of the new version when it differs
+	CheckedAt      time.Time `json:"checkedAt"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// data is the on-disk layout of the store
+type data struct {
+	Installed map[string]InstalledResource `json:"installed"`
+	// Tasks holds snapshots of unfinished tasks so they survive a restart
+	Tasks map[string]json.RawMessage `json:"tasks,omitempty"`
+	// Presets holds user-defined presets and overrides of built-in presets
+	Presets map[string]json.RawMessage `json:"presets,omitempty"`
+}
+
+// Store keeps installer state in a JSON file
+type Store struct {
+	path string
+	mu   sync.RWMutex
+	data data
+}
+
+// Open loads the store at path, starting empty if the file does not exist.
+// An empty path keeps the store in memory only.
+//
+// The returned store is always usable, but never overwrites records it could
+// not load: a file that cannot be parsed is moved aside to
+// path.corrupt-<timestamp> first, and a file that cannot be read or moved
+// leaves the store in memory only. Both cases return an error.
+func Open(path string) (*Store, error) {
+	s := newStore(path)
+	if path == "" {
+		return s, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return newStore(""), fmt.Errorf("failed to read store %s, keeping records in memory only: %v", path, err)
+	}
+	if err := json.Unmarshal(content, &s.data); err != nil {
+		corrupt := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+		if renameErr := os.Rename(path, corrupt); renameErr != nil {
+			return newStore(""), fmt.Errorf("failed to parse store %s (%v) and to move it aside (%v), keeping records in memory only", path, err, renameErr)
+		}
+		return newStore(path), fmt.Errorf("failed to parse store %s, moved it to %s and started empty: %v", path, corrupt, err)
+	}
+	if s.data.Installed == nil {
+		s.data.Installed = make(map[string]InstalledResource)
+	}
+	if s.data.Tasks == nil {
+		s.data.Tasks = make(map[string]json.RawMessage)
+	}
+	if s.data.Presets == nil {
+		s.data.Presets = make(map[string]json.RawMessage)
+	}
+	return s, nil
+}
+
+func newStore(path string) *Store {
+	return &Store{path: path, data: data{
+		Installed: make(map[string]InstalledResource),
+		Tasks:     make(map[string]json.RawMessage),
+		Presets:   make(map[string]json.RawMessage),
+	}}
+}
+
+// Installed returns all installed resources ordered by install time
+func (s *Store) Installed() []InstalledResource {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resources := make([]InstalledResource, 0, len(s.data.Installed))
+	for _, resource := range s.data.Installed {
+		resources = append(resources, resource)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].InstalledAt.Before(resources[j].InstalledAt)
+	})
+	return resources
+}
+
+// GetInstalled returns the installed resource with the given ID
+func (s *Store) GetInstalled(id string) (InstalledResource, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resource, exists := s.data.Installed[id]
+	return resource, exists
+}
+
+// PutInstalled records an installed resource, replacing one with the same ID
+func (s *Store) PutInstalled(resource InstalledResource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Installed[resource.ID] = resource
+	return s.saveLocked()
+}
+
+// RenameInstalled moves the record oldID to the ID of resource. Neither record
+// changes if the move could not be written.
+func (s *Store) RenameInstalled(oldID string, resource InstalledResource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.data.Installed[oldID]
+	replaced, replacing := s.data.Installed[resource.ID]
+	delete(s.data.Installed, oldID)
+	s.data.Installed[resource.ID] = resource
+	if err := s.saveLocked(); err != nil {
+		delete(s.data.Installed, resource.ID)
+		if replacing {
+			s.data.Installed[resource.ID] = replaced
+		}
+		if existed {
+			s.data.Installed[oldID] = previous
+		}
+		return err
+	}
+	return nil
+}
+
+// SetUpdateStatus records the result of an update check of an installed resource
+func (s *Store) SetUpdateStatus(id string, status UpdateStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource, exists := s.data.Installed[id]
+	if !exists {
+		return nil
+	}
+	resource.Update = &status
+	s.data.Installed[id] = resource
+	return s.saveLocked()
+}
+
+// DeleteInstalled removes the record of an installed resource
+func (s *Store) DeleteInstalled(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data.Installed, id)
+	return s.saveLocked()
+}
+
+// Tasks returns the stored task snapshots by task ID
+func (s *Store) Tasks() map[string]json.RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks := make(map[string]json.RawMessage, len(s.data.Tasks))
+	for id, task := range s.data.Tasks {
+		tasks[id] = task
+	}
+	return tasks
+}
+
+// PutTask stores a snapshot of an unfinished task
+func (s *Store) PutTask(id string, task interface{}) error {
+	content, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Tasks[id] = content
+	return s.saveLocked()
+}
+
+// DeleteTask removes the snapshot of a task
+func (s *Store) DeleteTask(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.data.Tasks[id]; !exists {
+		return nil
+	}
+	delete(s.data.Tasks, id)
+	return s.saveLocked()
+}
+
+// Presets returns the stored user-defined presets by ID
+func (s *Store) Presets() map[string]json.RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	presets := make(map[string]json.RawMessage, len(s.data.Presets))
+	for id, preset := range s.data.Presets {
+		presets[id] = preset
+	}
+	return presets
+}
+
+// PutPreset stores a user-defined preset or override. The preset is only
+// kept if it could be written.
+func (s *Store) PutPreset(id string, preset json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.data.Presets[id]
+	s.data.Presets[id] = preset
+	if err := s.saveLocked(); err != nil {
+		if existed {
+			s.data.Presets[id] = previous
+		} else {
+			delete(s.data.Presets, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeletePreset removes a user-defined preset or override. The preset is
+// kept if the removal could not be written.
+func (s *Store) DeletePreset(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, exists := s.data.Presets[id]
+	if !exists {
+		return nil
+	}
+	delete(s.data.Presets, id)
+	if err := s.saveLocked(); err != nil {
+		s.data.Presets[id] = previous
+		return err
+	}
+	return nil
+}
+
+// saveLocked writes the store atomically so a crash never leaves a truncated file
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create store directory: %v", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	return nil
+}
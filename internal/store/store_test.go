@@ -0,0 +1,95 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenNeverOverwritesUnreadableRecords(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantErr     bool
+		wantCorrupt bool
+	}{
+		{name: "missing file"},
+		{name: "valid file", content: `{"installed":{"a":{"id":"a"}}}`},
+		{name: "corrupt file", content: `{"installed":`, wantErr: true, wantCorrupt: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "data.db")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s, err := Open(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := s.PutInstalled(InstalledResource{ID: "b"}); err != nil {
+				t.Fatal(err)
+			}
+
+			corrupt, _ := filepath.Glob(path + ".corrupt-*")
+			if (len(corrupt) == 1) != tt.wantCorrupt {
+				t.Fatalf("corrupt copies = %v, want one: %v", corrupt, tt.wantCorrupt)
+			}
+			if tt.wantCorrupt {
+				content, err := os.ReadFile(corrupt[0])
+				if err != nil || string(content) != tt.content {
+					t.Fatalf("corrupt copy = %q (%v), want the original content", content, err)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenKeepsUnreadableFileInMemoryOnly(t *testing.T) {
+	// A directory cannot be read as a file
+	path := t.TempDir()
+
+	s, err := Open(path)
+	if err == nil {
+		t.Fatal("Open() of a directory succeeded")
+	}
+	if s.path != "" {
+		t.Fatalf("store is bound to %q, want memory only", s.path)
+	}
+	if err := s.PutInstalled(InstalledResource{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Fatalf("%s was replaced", path)
+	}
+}
+
+func TestRenameInstalledKeepsRecordsWhenSaveFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutInstalled(InstalledResource{ID: "lora", PresetID: "lora"}); err != nil {
+		t.Fatal(err)
+	}
+	// A directory in place of the temporary file makes every save fail
+	if err := os.MkdirAll(filepath.Join(path+".tmp", "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RenameInstalled("lora", InstalledResource{ID: "lora@comfyui", PresetID: "lora", Profile: "comfyui"}); err == nil {
+		t.Fatal("RenameInstalled() succeeded without writing the store")
+	}
+	if _, exists := s.GetInstalled("lora"); !exists {
+		t.Fatal("old record was removed")
+	}
+	if _, exists := s.GetInstalled("lora@comfyui"); exists {
+		t.Fatal("new record was kept")
+	}
+}
@@ -86,7 +86,7 @@ const ResourceInstaller = () => {
         name: params.name,
         path: params.path,
         type: params.type,
-        status: 'pending',
+        status: 'queued',
         progress: 0,
         startTime: new Date()
       }
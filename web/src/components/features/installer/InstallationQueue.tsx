@@ -33,6 +33,11 @@ const InstallationQueue = ({ installTasks, onCancelTask }: InstallationQueueProp
         return `${remainingSeconds}s`
     }
 
+    // Every state between probing and post-install is shown as in progress
+    const isRunning = (status: InstallTask['status']) =>
+        status === 'probing' || status === 'downloading' || status === 'verifying' ||
+        status === 'extracting' || status === 'post-install'
+
     const handleCancelTask = async (taskId: string) => {
         try {
             await onCancelTask(taskId)
@@ -88,14 +93,17 @@ const InstallationQueue = ({ installTasks, onCancelTask }: InstallationQueueProp
                                                 task.status === 'cancelled' ? 'bg-gray-500/20 text-gray-400' :
                                                     'bg-blue-500/20 text-blue-400'
                                             }`}>
-                                            {task.status === 'pending' && '待機中'}
-                                            {task.status === 'downloading' && 'ダウンロード中'}
-                                            {task.status === 'installing' && 'インストール中'}
+                                            {task.status === 'scheduled' && '予約済み'}
+                                            {task.status === 'queued' && '待機中'}
+                                            {task.status === 'paused' && '一時停止'}
+                                            {task.status === 'interrupted' && '中断'}
+                                            {(task.status === 'probing' || task.status === 'downloading') && 'ダウンロード中'}
+                                            {(task.status === 'verifying' || task.status === 'extracting' || task.status === 'post-install') && 'インストール中'}
                                             {task.status === 'completed' && '完了'}
                                             {task.status === 'failed' && '失敗'}
                                             {task.status === 'cancelled' && 'キャンセル'}
                                         </div>
-                                        {task.status === 'downloading' || task.status === 'installing' ? (
+                                        {isRunning(task.status) ? (
                                             <button
                                                 onClick={() => handleCancelTask(task.id)}
                                                 className="p-1 hover:bg-red-500/20 rounded transition-colors"
@@ -106,7 +114,7 @@ const InstallationQueue = ({ installTasks, onCancelTask }: InstallationQueueProp
                                     </div>
                                 </div>
 
-                                {task.status === 'downloading' || task.status === 'installing' ? (
+                                {isRunning(task.status) ? (
                                     <div className="space-y-3">
                                         {/* Progress Bar */}
                                         <div className="space-y-2">
@@ -80,7 +80,7 @@ const ResourceSelection = ({
 
         // Auto-select destination based on preset
         const matchingDestination = installDestinations.find(dest =>
-            dest.path === preset.destination_path
+            preset.destination ? dest.type === preset.destination : dest.path === preset.destination_path
         )
         if (matchingDestination) {
             setSelectedDestination(matchingDestination)
@@ -21,8 +21,12 @@ export interface InstallTask {
     name: string
     path: string
     type?: string
-    status: 'pending' | 'downloading' | 'installing' | 'completed' | 'failed' | 'cancelled'
+    status: 'scheduled' | 'queued' | 'probing' | 'downloading' | 'verifying' | 'extracting' | 'post-install' | 'completed' | 'failed' | 'cancelled' | 'paused' | 'interrupted'
     progress: number
+    downloadedBytes?: number
+    totalBytes?: number
+    bytesPerSecond?: number
+    etaSeconds?: number
     error?: string
     startTime?: Date
     endTime?: Date
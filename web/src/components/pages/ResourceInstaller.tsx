@@ -68,7 +68,7 @@ const ResourceInstaller = () => {
       id: taskId,
       resource: resource,
       destination: destination,
-      status: 'pending',
+      status: 'queued',
       progress: 0,
       startTime: new Date()
     }
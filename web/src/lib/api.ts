@@ -84,7 +84,9 @@ export interface PresetResource {
     author?: string
     license?: string
     requirements?: string[]
+    destination?: string
     destination_path?: string
+    allow_type_mismatch?: boolean
     url?: string
 }
 
@@ -98,10 +100,19 @@ export interface PresetResourcesResponse {
 export interface InstallationDestination {
     type: string
     path: string
+    template?: string
+}
+
+export interface PathVariable {
+    name: string
+    value: string
+    source: 'env' | 'file' | 'default'
 }
 
 export interface InstallationDestinationsResponse {
+    profile: string
     destinations: InstallationDestination[]
+    variables: PathVariable[]
 }
 
 /**